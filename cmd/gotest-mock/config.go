@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	gotesthttp "github.com/rnojiri/gotest/http"
+	"github.com/rnojiri/gotest/tcpudp"
+)
+
+/**
+* The configuration file used by the mock command.
+* @author rnojiri
+**/
+
+const (
+	defaultReadBufferSize     int = 1024
+	defaultMessageChannelSize int = 100
+	defaultReadTimeout            = time.Second
+	defaultWriteTimeout           = time.Second
+	defaultMaxMessages        int = 1000
+	defaultStatus             int = http.StatusOK
+)
+
+// Duration - a time.Duration read from a string like "1s" or "500ms"
+type Duration time.Duration
+
+// UnmarshalJSON - parses the duration string
+func (d *Duration) UnmarshalJSON(data []byte) error {
+
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("expected a duration string: %w", err)
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+
+	return nil
+}
+
+// Config - the root of the configuration file
+type Config struct {
+	// Admin - the address to expose the recorded traffic (disabled when empty)
+	Admin string `json:"admin"`
+	// MaxMessages - the tcp and udp messages kept by server in the journal, the oldest are dropped (1000 when zero)
+	MaxMessages int             `json:"maxMessages"`
+	HTTP        []HTTPConfig    `json:"http"`
+	TCP         []TCPConfig     `json:"tcp"`
+	UDP         []NetworkConfig `json:"udp"`
+}
+
+// ResponseConfig - the response of a method
+type ResponseConfig struct {
+	Body    interface{} `json:"body"`
+	Headers http.Header `json:"headers"`
+	Status  int         `json:"status"`
+	Wait    Duration    `json:"wait"`
+}
+
+// EndpointConfig - an endpoint
+type EndpointConfig struct {
+	URI     string                    `json:"uri"`
+	Regexp  bool                      `json:"regexp"`
+	Methods map[string]ResponseConfig `json:"methods"`
+}
+
+// HTTPConfig - a http mock
+type HTTPConfig struct {
+	Name      string                      `json:"name"`
+	Host      string                      `json:"host"`
+	Port      int                         `json:"port"`
+	Socket    string                      `json:"socketPath"`
+	Mode      string                      `json:"mode"`
+	Responses map[string][]EndpointConfig `json:"responses"`
+}
+
+// NetworkConfig - a udp mock and the common part of a tcp mock
+type NetworkConfig struct {
+	Name               string `json:"name"`
+	Host               string `json:"host"`
+	Port               int    `json:"port"`
+	Socket             string `json:"socketPath"`
+	MessageChannelSize int    `json:"messageChannelSize"`
+	ReadBufferSize     int    `json:"readBufferSize"`
+}
+
+// TCPConfig - a tcp mock
+type TCPConfig struct {
+	NetworkConfig
+	ReadTimeout  Duration `json:"readTimeout"`
+	WriteTimeout Duration `json:"writeTimeout"`
+	Response     string   `json:"response"`
+}
+
+// LoadConfig - reads and validates the configuration file
+func LoadConfig(path string) (*Config, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+
+	err = json.Unmarshal(data, config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+
+	if len(config.HTTP)+len(config.TCP)+len(config.UDP) == 0 {
+		return nil, fmt.Errorf("no servers configured in %s", path)
+	}
+
+	if config.MaxMessages < 0 {
+		return nil, fmt.Errorf("invalid max messages: %d", config.MaxMessages)
+	}
+
+	if config.MaxMessages == 0 {
+		config.MaxMessages = defaultMaxMessages
+	}
+
+	names := map[string]bool{}
+
+	for i, c := range config.HTTP {
+
+		if len(c.Responses) == 0 {
+			return nil, fmt.Errorf("http server %q: expected at least one response", c.Name)
+		}
+
+		if c.Mode == "" && len(c.Responses) > 1 {
+			return nil, fmt.Errorf("http server %q: a mode must be chosen when there are multiple modes", c.Name)
+		}
+
+		if c.Mode != "" {
+			if _, ok := c.Responses[c.Mode]; !ok {
+				return nil, fmt.Errorf("http server %q: mode %q not found in responses", c.Name, c.Mode)
+			}
+		}
+
+		config.HTTP[i].Name, err = checkName(names, "http", c.Name, i)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range config.TCP {
+
+		config.TCP[i].NetworkConfig.setDefaults()
+
+		if config.TCP[i].ReadTimeout == 0 {
+			config.TCP[i].ReadTimeout = Duration(defaultReadTimeout)
+		}
+
+		if config.TCP[i].WriteTimeout == 0 {
+			config.TCP[i].WriteTimeout = Duration(defaultWriteTimeout)
+		}
+
+		config.TCP[i].Name, err = checkName(names, "tcp", config.TCP[i].Name, i)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range config.UDP {
+
+		config.UDP[i].setDefaults()
+
+		config.UDP[i].Name, err = checkName(names, "udp", config.UDP[i].Name, i)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return config, nil
+}
+
+// checkName - generates a name when empty and checks for duplicates
+func checkName(names map[string]bool, kind, name string, index int) (string, error) {
+
+	if name == "" {
+		name = fmt.Sprintf("%s-%d", kind, index)
+	}
+
+	if names[name] {
+		return "", fmt.Errorf("duplicated server name: %s", name)
+	}
+
+	names[name] = true
+
+	return name, nil
+}
+
+// setDefaults - fills the zero values
+func (c *NetworkConfig) setDefaults() {
+
+	if c.ReadBufferSize == 0 {
+		c.ReadBufferSize = defaultReadBufferSize
+	}
+
+	if c.MessageChannelSize == 0 {
+		c.MessageChannelSize = defaultMessageChannelSize
+	}
+}
+
+// toConfiguration - converts to the http package configuration
+func (c *HTTPConfig) toConfiguration() *gotesthttp.Configuration {
+
+	responses := map[string][]gotesthttp.Endpoint{}
+
+	for mode, endpoints := range c.Responses {
+
+		for _, e := range endpoints {
+
+			methods := map[string]gotesthttp.Response{}
+			for method, r := range e.Methods {
+
+				status := r.Status
+				if status == 0 {
+					status = defaultStatus
+				}
+
+				methods[method] = gotesthttp.Response{
+					Body:    r.Body,
+					Headers: r.Headers,
+					Status:  status,
+					Wait:    time.Duration(r.Wait),
+				}
+			}
+
+			responses[mode] = append(responses[mode], gotesthttp.Endpoint{
+				URI:     e.URI,
+				Regexp:  e.Regexp,
+				Methods: methods,
+			})
+		}
+	}
+
+	return &gotesthttp.Configuration{
+		Host:       c.Host,
+		Port:       c.Port,
+		SocketPath: c.Socket,
+		Responses:  responses,
+	}
+}
+
+// toServerConfiguration - converts to the tcpudp package configuration
+func (c *NetworkConfig) toServerConfiguration() tcpudp.ServerConfiguration {
+
+	return tcpudp.ServerConfiguration{
+		Host:               c.Host,
+		Port:               c.Port,
+		SocketPath:         c.Socket,
+		MessageChannelSize: c.MessageChannelSize,
+		ReadBufferSize:     c.ReadBufferSize,
+	}
+}
+
+// listenAddress - returns the unix socket path or the host and port
+func (c *NetworkConfig) listenAddress(port int) string {
+
+	if c.Socket != "" {
+		return c.Socket
+	}
+
+	return net.JoinHostPort(c.Host, strconv.Itoa(port))
+}
+
+// toTCPConfiguration - converts to the tcpudp package configuration
+func (c *TCPConfig) toTCPConfiguration() *tcpudp.TCPConfiguration {
+
+	return &tcpudp.TCPConfiguration{
+		ReadTimeout:         time.Duration(c.ReadTimeout),
+		WriteTimeout:        time.Duration(c.WriteTimeout),
+		ResponseString:      c.Response,
+		ServerConfiguration: c.NetworkConfig.toServerConfiguration(),
+	}
+}
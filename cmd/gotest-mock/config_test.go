@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* The tests for the mock command configuration.
+* @author rnojiri
+**/
+
+// writeConfig - writes a configuration file in a temporary dir
+func writeConfig(t *testing.T, content string) string {
+
+	path := filepath.Join(t.TempDir(), "config.json")
+
+	err := os.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("error writing config: %v", err)
+	}
+
+	return path
+}
+
+// TestLoadExampleConfig - tests loading the example configuration
+func TestLoadExampleConfig(t *testing.T) {
+
+	config, err := LoadConfig("gotest-mock.example.json")
+	if !assert.NoError(t, err, "expected no error loading the example") {
+		return
+	}
+
+	if !assert.Len(t, config.HTTP, 1, "expected one http server") {
+		return
+	}
+
+	httpConf := config.HTTP[0].toConfiguration()
+	endpoints := httpConf.Responses["default"]
+	if !assert.Len(t, endpoints, 2, "expected two endpoints") {
+		return
+	}
+
+	assert.Equal(t, 500*time.Millisecond, endpoints[1].Methods[http.MethodPost].Wait, "expected the parsed wait")
+	assert.Equal(t, time.Second, config.TCP[0].toTCPConfiguration().ReadTimeout, "expected the parsed read timeout")
+	assert.Equal(t, defaultWriteTimeout, time.Duration(config.TCP[0].WriteTimeout), "expected the default write timeout")
+	assert.Equal(t, defaultReadBufferSize, config.UDP[0].ReadBufferSize, "expected the default buffer size")
+
+	config, err = LoadConfig(writeConfig(t, `{"http": [{"responses": {"default": [{"uri": "/a", "methods": {"GET": {"body": "a"}}}]}}]}`))
+	if assert.NoError(t, err, "expected no error loading the config") {
+		endpoints := config.HTTP[0].toConfiguration().Responses["default"]
+		assert.Equal(t, http.StatusOK, endpoints[0].Methods[http.MethodGet].Status, "expected the default status")
+	}
+}
+
+// TestLoadInvalidConfig - tests the configuration validations
+func TestLoadInvalidConfig(t *testing.T) {
+
+	invalid := map[string]string{
+		"empty":          `{}`,
+		"bad duration":   `{"tcp": [{"readTimeout": "soon"}]}`,
+		"no responses":   `{"http": [{"name": "a"}]}`,
+		"no mode chosen": `{"http": [{"responses": {"a": [], "b": []}}]}`,
+		"unknown mode":   `{"http": [{"mode": "c", "responses": {"a": []}}]}`,
+		"duplicated":     `{"tcp": [{"name": "x"}], "udp": [{"name": "x"}]}`,
+	}
+
+	for name, content := range invalid {
+		_, err := LoadConfig(writeConfig(t, content))
+		assert.Errorf(t, err, "expected error for config: %s", name)
+	}
+}
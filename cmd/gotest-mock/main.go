@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	gotesthttp "github.com/rnojiri/gotest/http"
+	"github.com/rnojiri/gotest/tcpudp"
+)
+
+/**
+* Starts the http, tcp and udp mocks described in a configuration file,
+* so the same stubs can be used outside the go tests.
+* @author rnojiri
+**/
+
+// messageSource - the tcp and udp servers
+type messageSource interface {
+	MessageChannel() <-chan tcpudp.MessageData
+	GetErrors() []error
+}
+
+// httpRequest - the json view of a recorded http request
+type httpRequest struct {
+	URI     string      `json:"uri"`
+	Method  string      `json:"method"`
+	Headers http.Header `json:"headers"`
+	Body    string      `json:"body"`
+	Mode    string      `json:"mode"`
+	Tag     string      `json:"tag,omitempty"`
+}
+
+// journal - the traffic received by all servers
+type journal struct {
+	HTTP   map[string][]httpRequest        `json:"http"`
+	TCP    map[string][]tcpudp.MessageData `json:"tcp"`
+	UDP    map[string][]tcpudp.MessageData `json:"udp"`
+	Errors map[string][]string             `json:"errors"`
+}
+
+// mock - all running servers
+type mock struct {
+	httpServers map[string]*gotesthttp.Server
+	tcpServers  map[string]*tcpudp.TCPServer
+	udpServers  map[string]*tcpudp.UDPServer
+	messages    map[string]map[string][]tcpudp.MessageData
+	maxMessages int
+	mutex       sync.Mutex
+}
+
+func main() {
+
+	configPath := flag.String("config", "gotest-mock.json", "the configuration file")
+	flag.Parse()
+
+	if err := run(*configPath, nil); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run - starts the servers and blocks until SIGINT or SIGTERM, ready is called after
+// all servers are started (when not nil)
+func run(configPath string, ready func(m *mock, admin *http.Server)) error {
+
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	m := newMock(config.MaxMessages)
+
+	err = m.start(ctx, config)
+	if err != nil {
+		m.stop()
+		return err
+	}
+
+	var admin *http.Server
+	if config.Admin != "" {
+		admin, err = m.startAdmin(config.Admin)
+		if err != nil {
+			m.stop()
+			return err
+		}
+	}
+
+	if ready != nil {
+		ready(m, admin)
+	}
+
+	<-ctx.Done()
+
+	log.Println("shutting down...")
+
+	if admin != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := admin.Shutdown(shutdownCtx); err != nil {
+			log.Printf("error shutting down the admin server: %v", err)
+		}
+	}
+
+	m.stop()
+
+	return nil
+}
+
+// newMock - creates an empty mock keeping the last messages of each tcp and udp server
+func newMock(maxMessages int) *mock {
+
+	return &mock{
+		httpServers: map[string]*gotesthttp.Server{},
+		tcpServers:  map[string]*tcpudp.TCPServer{},
+		udpServers:  map[string]*tcpudp.UDPServer{},
+		messages: map[string]map[string][]tcpudp.MessageData{
+			"tcp": {},
+			"udp": {},
+		},
+		maxMessages: maxMessages,
+	}
+}
+
+// start - starts all configured servers
+func (m *mock) start(ctx context.Context, config *Config) error {
+
+	for _, c := range config.HTTP {
+
+		s, err := gotesthttp.NewServerE(c.toConfiguration())
+		if err != nil {
+			return fmt.Errorf("error starting the http server %q: %w", c.Name, err)
+		}
+
+		m.httpServers[c.Name] = s
+
+		if c.Mode != "" {
+			s.SetMode(c.Mode)
+		}
+
+		log.Printf("http server %q listening on %s", c.Name, s.Address())
+	}
+
+	for _, c := range config.TCP {
+
+		s, port, err := tcpudp.NewTCPServerE(nil, c.toTCPConfiguration(), true)
+		if err != nil {
+			return fmt.Errorf("error starting the tcp server %q: %w", c.Name, err)
+		}
+
+		m.tcpServers[c.Name] = s
+
+		log.Printf("tcp server %q listening on %s", c.Name, c.listenAddress(port))
+
+		go m.record(ctx, "tcp", c.Name, s)
+	}
+
+	for _, c := range config.UDP {
+
+		serverConf := c.toServerConfiguration()
+		s, port, err := tcpudp.NewUDPServerE(nil, &serverConf, true)
+		if err != nil {
+			return fmt.Errorf("error starting the udp server %q: %w", c.Name, err)
+		}
+
+		m.udpServers[c.Name] = s
+
+		log.Printf("udp server %q listening on %s", c.Name, c.listenAddress(port))
+
+		go m.record(ctx, "udp", c.Name, s)
+	}
+
+	return nil
+}
+
+// record - stores the messages received by a tcp or udp server
+func (m *mock) record(ctx context.Context, kind, name string, source messageSource) {
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message := <-source.MessageChannel():
+			m.mutex.Lock()
+			messages := append(m.messages[kind][name], message)
+			if len(messages) > m.maxMessages {
+				messages = messages[len(messages)-m.maxMessages:]
+			}
+			m.messages[kind][name] = messages
+			m.mutex.Unlock()
+		}
+	}
+}
+
+// stop - stops all servers
+func (m *mock) stop() {
+
+	for name, s := range m.httpServers {
+		s.Close()
+		log.Printf("http server %q closed", name)
+	}
+
+	for name, s := range m.tcpServers {
+		if err := s.Stop(); err != nil {
+			log.Printf("error stopping tcp server %q: %v", name, err)
+		}
+	}
+
+	for name, s := range m.udpServers {
+		if err := s.Stop(); err != nil {
+			log.Printf("error stopping udp server %q: %v", name, err)
+		}
+	}
+}
+
+// journal - returns a snapshot of the recorded traffic
+func (m *mock) journal() *journal {
+
+	j := &journal{
+		HTTP:   map[string][]httpRequest{},
+		TCP:    map[string][]tcpudp.MessageData{},
+		UDP:    map[string][]tcpudp.MessageData{},
+		Errors: map[string][]string{},
+	}
+
+	for name, s := range m.httpServers {
+
+		requests := []httpRequest{}
+		for _, r := range s.RequestChannel() {
+			requests = append(requests, httpRequest{
+				URI:     r.URI,
+				Method:  r.Method,
+				Headers: r.Headers,
+				Body:    string(r.Body),
+				Mode:    r.Mode,
+				Tag:     r.Tag,
+			})
+		}
+
+		j.HTTP[name] = requests
+		j.Errors[name] = errorStrings(s.GetErrors())
+	}
+
+	for name, s := range m.tcpServers {
+		j.Errors[name] = errorStrings(s.GetErrors())
+	}
+
+	for name, s := range m.udpServers {
+		j.Errors[name] = errorStrings(s.GetErrors())
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for name, messages := range m.messages["tcp"] {
+		j.TCP[name] = append([]tcpudp.MessageData{}, messages...)
+	}
+
+	for name, messages := range m.messages["udp"] {
+		j.UDP[name] = append([]tcpudp.MessageData{}, messages...)
+	}
+
+	return j
+}
+
+// startAdmin - exposes the recorded traffic as json
+func (m *mock) startAdmin(address string) (*http.Server, error) {
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /journal", func(res http.ResponseWriter, req *http.Request) {
+
+		res.Header().Set("Content-Type", "application/json")
+
+		encoder := json.NewEncoder(res)
+		encoder.SetIndent("", "  ")
+
+		if err := encoder.Encode(m.journal()); err != nil {
+			log.Printf("error writing the journal: %v", err)
+		}
+	})
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	admin := &http.Server{Addr: listener.Addr().String(), Handler: mux}
+
+	go func() {
+		if err := admin.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("admin server error: %v", err)
+		}
+	}()
+
+	log.Printf("journal available at http://%s/journal", listener.Addr())
+
+	return admin, nil
+}
+
+// errorStrings - converts the errors to strings
+func errorStrings(errs []error) []string {
+
+	result := make([]string, len(errs))
+	for i, err := range errs {
+		result[i] = err.Error()
+	}
+
+	return result
+}
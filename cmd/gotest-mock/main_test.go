@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rnojiri/gotest/tcpudp"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* The end to end tests of the mock command.
+* @author rnojiri
+**/
+
+// TestRun - starts the command, sends traffic, reads the journal and stops it with SIGTERM
+func TestRun(t *testing.T) {
+
+	path := writeConfig(t, `{
+		"admin": "127.0.0.1:0",
+		"maxMessages": 2,
+		"http": [{"name": "api", "host": "127.0.0.1", "responses": {"default": [
+			{"uri": "/health", "methods": {"GET": {"status": 200, "body": "ok"}}}
+		]}}],
+		"tcp": [{"name": "telnet", "host": "127.0.0.1", "readTimeout": "50ms"}]
+	}`)
+
+	type started struct {
+		m     *mock
+		admin *http.Server
+	}
+
+	readyChan := make(chan started, 1)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- run(path, func(m *mock, admin *http.Server) {
+			readyChan <- started{m: m, admin: admin}
+		})
+	}()
+
+	var s started
+
+	select {
+	case s = <-readyChan:
+	case err := <-done:
+		t.Fatalf("the command returned before starting: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout starting the command")
+	}
+
+	res, err := http.Get("http://" + s.m.httpServers["api"].Address() + "/health")
+	if !assert.NoError(t, err, "expected no error calling the http server") {
+		return
+	}
+	res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected the configured status")
+
+	for _, message := range []string{"a", "b", "c"} {
+
+		conn, err := tcpudp.ConnectTCP("127.0.0.1", s.m.tcpServers["telnet"].Port(), time.Second)
+		if !assert.NoError(t, err, "expected no error connecting to the tcp server") {
+			return
+		}
+
+		assert.NoError(t, tcpudp.WriteTCP(conn, message, true), "expected no error writing")
+	}
+
+	var j journal
+
+	// the messages are recorded in the background
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(20 * time.Millisecond) {
+
+		res, err := http.Get("http://" + s.admin.Addr + "/journal")
+		if !assert.NoError(t, err, "expected no error reading the journal") {
+			return
+		}
+
+		data, err := io.ReadAll(res.Body)
+		res.Body.Close()
+
+		if !assert.NoError(t, err, "expected no error reading the body") || !assert.NoError(t, json.Unmarshal(data, &j), "expected a json journal") {
+			return
+		}
+
+		if len(j.TCP["telnet"]) == 2 && j.TCP["telnet"][1].Message == "c" {
+			break
+		}
+	}
+
+	if assert.Len(t, j.HTTP["api"], 1, "expected the http request") {
+		assert.Equal(t, "/health", j.HTTP["api"][0].URI, "expected the uri")
+	}
+
+	if assert.Len(t, j.TCP["telnet"], 2, "expected only the last messages") {
+		assert.Equal(t, "b", j.TCP["telnet"][0].Message, "expected the oldest message dropped")
+		assert.Equal(t, "c", j.TCP["telnet"][1].Message, "expected the last message")
+	}
+
+	if !assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM), "expected the signal sent") {
+		return
+	}
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err, "expected a clean shutdown")
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout waiting the shutdown")
+	}
+
+	_, err = http.Get("http://" + s.admin.Addr + "/journal")
+	assert.Error(t, err, "expected the admin server closed")
+}
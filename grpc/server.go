@@ -0,0 +1,505 @@
+package grpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/copier"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+/**
+* Mocks a gRPC server answering any service using stubs by method.
+* @author rnojiri
+**/
+
+// Request - a call received by the server
+type Request struct {
+	// Method - the full method name, like /package.Service/Method
+	Method string
+	// Metadata - the received metadata
+	Metadata metadata.MD
+	// Messages - the received messages (the registered go types or dynamic messages)
+	Messages []proto.Message
+	// Code - the status code answered
+	Code codes.Code
+}
+
+// Response - the answer of a stub
+type Response struct {
+	// Messages - the messages sent, a single one for unary calls, each one can be a proto.Message
+	// or the message as protojson (string, []byte or a value encoded as json)
+	Messages []interface{}
+	// Code - the status code (codes.OK by default)
+	Code codes.Code
+	// Message - the status message
+	Message string
+	// Details - the status details
+	Details []proto.Message
+	// Header - the header metadata
+	Header metadata.MD
+	// Trailer - the trailer metadata
+	Trailer metadata.MD
+	// Wait - a time to wait until responds
+	Wait time.Duration
+	// Func - generates the response from the request (the returned Func is ignored)
+	Func ResponseFunc
+}
+
+// ResponseFunc - generates a response from the received request
+type ResponseFunc func(request *Request) Response
+
+// Stub - answers the calls of a method
+type Stub struct {
+	// Method - the full method name, like /package.Service/Method
+	Method string
+	// Metadata - the metadata values expected in the call (no matching when empty)
+	Metadata map[string]string
+	// Response - the response
+	Response Response
+}
+
+// Configuration - the server configuration
+type Configuration struct {
+	// Host - the host to listen
+	Host string
+	// Port - the port to listen (0 chooses a free port)
+	Port int
+	// Stubs - the stubs, the first one matching the method and metadata answers
+	Stubs []Stub
+	// Files - the descriptors of the services not registered by generated go code
+	Files *descriptorpb.FileDescriptorSet
+	// T - the test, benchmark or fuzz test, when nil the failures are stored as errors (see GetErrors)
+	T testing.TB
+}
+
+// Server - the mocked gRPC server
+type Server struct {
+	server        *grpclib.Server
+	listener      net.Listener
+	configuration *Configuration
+	files         *protoregistry.Files
+	types         *protoregistry.Types
+	stubs         []Stub
+	requests      []Request
+	errors        []error
+	mutex         sync.Mutex
+}
+
+// NewServer - creates a new gRPC server listening for calls, panics on errors (see NewServerE)
+func NewServer(configuration *Configuration) *Server {
+
+	gs, err := NewServerE(configuration)
+	if err != nil {
+		panic(err)
+	}
+
+	return gs
+}
+
+// NewServerE - creates a new gRPC server listening for calls,
+// the server is closed by the test cleanup when a test is configured
+func NewServerE(configuration *Configuration) (*Server, error) {
+
+	if configuration == nil {
+		return nil, fmt.Errorf("null configuration")
+	}
+
+	for i, stub := range configuration.Stubs {
+		if strings.Count(CleanMethod(stub.Method), "/") != 2 {
+			return nil, fmt.Errorf("invalid method in the stub %d, expected /package.Service/Method: %q", i, stub.Method)
+		}
+	}
+
+	confCopy := Configuration{}
+	copier.Copy(&confCopy, configuration)
+
+	gs := &Server{
+		configuration: &confCopy,
+		stubs:         append([]Stub{}, configuration.Stubs...),
+		requests:      []Request{},
+		types:         protoregistry.GlobalTypes,
+	}
+
+	if configuration.Files != nil {
+
+		files, err := protodesc.NewFiles(configuration.Files)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file descriptor set: %w", err)
+		}
+
+		gs.files = files
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", configuration.Host, configuration.Port))
+	if err != nil {
+		return nil, fmt.Errorf("error listening on %s:%d: %w", configuration.Host, configuration.Port, err)
+	}
+
+	if confCopy.Port == 0 {
+		confCopy.Port = listener.Addr().(*net.TCPAddr).Port
+	}
+
+	gs.listener = listener
+	gs.server = grpclib.NewServer(grpclib.UnknownServiceHandler(gs.handler))
+
+	go func() {
+		if err := gs.server.Serve(listener); err != nil && !errors.Is(err, grpclib.ErrServerStopped) {
+			gs.addError(err)
+		}
+	}()
+
+	if confCopy.T != nil {
+		confCopy.T.Cleanup(gs.Close)
+	}
+
+	return gs, nil
+}
+
+// handler - handles all calls
+func (gs *Server) handler(srv interface{}, stream grpclib.ServerStream) error {
+
+	fullMethod, ok := grpclib.MethodFromServerStream(stream)
+	if !ok {
+		return gs.fail(codes.Internal, "no method found in the stream")
+	}
+
+	method, err := gs.findMethod(fullMethod)
+	if err != nil {
+		return gs.fail(codes.Unimplemented, "%v", err)
+	}
+
+	md, _ := metadata.FromIncomingContext(stream.Context())
+
+	request := Request{
+		Method:   fullMethod,
+		Metadata: md.Copy(),
+		Messages: []proto.Message{},
+	}
+
+	// the client streams are read until the client closes its side, then the response is sent
+	for {
+		message := gs.newMessage(method.Input())
+
+		err := stream.RecvMsg(message)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		request.Messages = append(request.Messages, message)
+
+		if !method.IsStreamingClient() {
+			break
+		}
+	}
+
+	stub, found := gs.findStub(fullMethod, md)
+	if !found {
+		request.Code = codes.Unimplemented
+		gs.addRequest(request)
+		return gs.fail(codes.Unimplemented, "no stub configured for the method: %s", fullMethod)
+	}
+
+	response := stub.Response
+	if response.Func != nil {
+		response = response.Func(&request)
+	}
+
+	request.Code = response.Code
+	gs.addRequest(request)
+
+	if response.Wait != 0 {
+		select {
+		case <-time.After(response.Wait):
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		}
+	}
+
+	return gs.respond(stream, method, &response)
+}
+
+// respond - sends the metadata, the messages and the status
+func (gs *Server) respond(stream grpclib.ServerStream, method protoreflect.MethodDescriptor, response *Response) error {
+
+	if len(response.Header) > 0 {
+		if err := stream.SendHeader(response.Header); err != nil {
+			return err
+		}
+	}
+
+	if len(response.Trailer) > 0 {
+		stream.SetTrailer(response.Trailer)
+	}
+
+	if response.Code == codes.OK && !method.IsStreamingServer() && len(response.Messages) != 1 {
+		return gs.fail(codes.Internal, "expected one message for the unary method %s, found %d", method.FullName(), len(response.Messages))
+	}
+
+	for _, value := range response.Messages {
+
+		message, err := gs.toMessage(method.Output(), value)
+		if err != nil {
+			return gs.fail(codes.Internal, "invalid %s message: %v", method.Output().FullName(), err)
+		}
+
+		if err := stream.SendMsg(message); err != nil {
+			return err
+		}
+	}
+
+	if response.Code == codes.OK {
+		return nil
+	}
+
+	st := status.New(response.Code, response.Message)
+
+	if len(response.Details) > 0 {
+
+		details := make([]protoadapt.MessageV1, len(response.Details))
+		for i, detail := range response.Details {
+			details[i] = protoadapt.MessageV1Of(detail)
+		}
+
+		withDetails, err := st.WithDetails(details...)
+		if err != nil {
+			return gs.fail(codes.Internal, "invalid status details: %v", err)
+		}
+
+		st = withDetails
+	}
+
+	return st.Err()
+}
+
+// findMethod - finds the method descriptor in the configured files or in the registered go types
+func (gs *Server) findMethod(fullMethod string) (protoreflect.MethodDescriptor, error) {
+
+	service, name, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid method name: %s", fullMethod)
+	}
+
+	var descriptor protoreflect.Descriptor
+	var err error
+
+	if gs.files != nil {
+		descriptor, err = gs.files.FindDescriptorByName(protoreflect.FullName(service))
+	}
+
+	if descriptor == nil {
+		descriptor, err = protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(service))
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unknown service %s: %w", service, err)
+	}
+
+	serviceDescriptor, ok := descriptor.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service", service)
+	}
+
+	method := serviceDescriptor.Methods().ByName(protoreflect.Name(name))
+	if method == nil {
+		return nil, fmt.Errorf("unknown method: %s", fullMethod)
+	}
+
+	return method, nil
+}
+
+// findStub - finds the first stub matching the method and the metadata
+func (gs *Server) findStub(fullMethod string, md metadata.MD) (Stub, bool) {
+
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	for _, stub := range gs.stubs {
+
+		if CleanMethod(stub.Method) != fullMethod {
+			continue
+		}
+
+		if matchMetadata(stub.Metadata, md) {
+			return stub, true
+		}
+	}
+
+	return Stub{}, false
+}
+
+// matchMetadata - checks if all expected values were received
+func matchMetadata(expected map[string]string, md metadata.MD) bool {
+
+	for key, value := range expected {
+
+		found := false
+		for _, received := range md.Get(key) {
+			if received == value {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// newMessage - creates a message of the registered go type or a dynamic one
+func (gs *Server) newMessage(descriptor protoreflect.MessageDescriptor) proto.Message {
+
+	if messageType, err := gs.types.FindMessageByName(descriptor.FullName()); err == nil {
+		return messageType.New().Interface()
+	}
+
+	return dynamicpb.NewMessage(descriptor)
+}
+
+// toMessage - converts the response value to a message
+func (gs *Server) toMessage(descriptor protoreflect.MessageDescriptor, value interface{}) (proto.Message, error) {
+
+	if message, ok := value.(proto.Message); ok {
+		return message, nil
+	}
+
+	var data []byte
+
+	switch v := value.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		data = encoded
+	}
+
+	message := gs.newMessage(descriptor)
+	if err := protojson.Unmarshal(data, message); err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// AddStubs - adds stubs after the configured ones
+func (gs *Server) AddStubs(stubs ...Stub) {
+
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	gs.stubs = append(gs.stubs, stubs...)
+}
+
+// SetStubs - replaces all stubs
+func (gs *Server) SetStubs(stubs ...Stub) {
+
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	gs.stubs = append([]Stub{}, stubs...)
+}
+
+// CleanMethod - adds the leading bar to the full method name
+func CleanMethod(method string) string {
+
+	if !strings.HasPrefix(method, "/") {
+		return "/" + method
+	}
+
+	return method
+}
+
+// fail - fails the test or, when there is no test configured, stores the error,
+// returns the error status sent to the client
+func (gs *Server) fail(code codes.Code, format string, args ...interface{}) error {
+
+	err := fmt.Errorf(format, args...)
+
+	if gs.configuration.T != nil {
+		gs.configuration.T.Error(err)
+	} else {
+		gs.addError(err)
+	}
+
+	return status.Error(code, err.Error())
+}
+
+// addError - stores an asynchronous error
+func (gs *Server) addError(err error) {
+
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	gs.errors = append(gs.errors, err)
+}
+
+// addRequest - adds the request to the journal
+func (gs *Server) addRequest(request Request) {
+
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	gs.requests = append(gs.requests, request)
+}
+
+// GetErrors - get asynchronous errors
+func (gs *Server) GetErrors() []error {
+
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	return append([]error{}, gs.errors...)
+}
+
+// Address - returns the address the server is listening
+func (gs *Server) Address() string {
+
+	return gs.listener.Addr().String()
+}
+
+// Dial - creates a client connection to the server without transport security
+func (gs *Server) Dial(options ...grpclib.DialOption) (*grpclib.ClientConn, error) {
+
+	options = append([]grpclib.DialOption{grpclib.WithTransportCredentials(insecure.NewCredentials())}, options...)
+
+	return grpclib.NewClient(gs.Address(), options...)
+}
+
+// Close - stops the server closing all connections
+func (gs *Server) Close() {
+
+	if gs.server != nil {
+		gs.server.Stop()
+	}
+}
@@ -0,0 +1,109 @@
+package grpc
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+/**
+* Functions to query the calls received by the server.
+* @author rnojiri
+**/
+
+// RequestFilter - selects requests from the journal
+type RequestFilter func(request *Request) bool
+
+// ByMethod - selects the calls of the method
+func ByMethod(method string) RequestFilter {
+
+	method = CleanMethod(method)
+
+	return func(request *Request) bool {
+		return request.Method == method
+	}
+}
+
+// ByCode - selects the calls answered with the status code
+func ByCode(code codes.Code) RequestFilter {
+
+	return func(request *Request) bool {
+		return request.Code == code
+	}
+}
+
+// ByMetadata - selects the calls having the metadata value
+func ByMetadata(key, value string) RequestFilter {
+
+	expected := map[string]string{key: value}
+
+	return func(request *Request) bool {
+		return matchMetadata(expected, request.Metadata)
+	}
+}
+
+// matchFilters - checks if the request matches all filters
+func matchFilters(request *Request, filters []RequestFilter) bool {
+
+	for _, filter := range filters {
+		if !filter(request) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Requests - returns a copy of the received calls matching all filters
+func (gs *Server) Requests(filters ...RequestFilter) []Request {
+
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	result := []Request{}
+	for i := range gs.requests {
+		if matchFilters(&gs.requests[i], filters) {
+			result = append(result, gs.requests[i])
+		}
+	}
+
+	return result
+}
+
+// TakeRequest - removes and returns the first received call matching all filters
+func (gs *Server) TakeRequest(filters ...RequestFilter) *Request {
+
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	for i := range gs.requests {
+
+		if !matchFilters(&gs.requests[i], filters) {
+			continue
+		}
+
+		req := gs.requests[i]
+		gs.requests = append(gs.requests[:i:i], gs.requests[i+1:]...)
+
+		return &req
+	}
+
+	return nil
+}
+
+// MessagesJSON - returns the received messages encoded as protojson
+func (r *Request) MessagesJSON() ([]string, error) {
+
+	result := make([]string, len(r.Messages))
+
+	for i, message := range r.Messages {
+
+		data, err := protojson.Marshal(message)
+		if err != nil {
+			return nil, err
+		}
+
+		result[i] = string(data)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,321 @@
+package grpc_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	gotestgrpc "github.com/rnojiri/gotest/grpc"
+	"github.com/stretchr/testify/assert"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+/**
+* The tests for the gRPC server mock.
+* @author rnojiri
+**/
+
+const (
+	sayMethod     string = "/gotest.test.Greeter/Say"
+	countMethod   string = "/gotest.test.Greeter/Count"
+	collectMethod string = "/gotest.test.Greeter/Collect"
+	personMethod  string = "/gotest.test.Greeter/Person"
+)
+
+// testFiles - a service using registered go types (wrappers) and a message only known by the descriptor
+func testFiles() *descriptorpb.FileDescriptorSet {
+
+	stringValue := ".google.protobuf.StringValue"
+	person := ".gotest.test.Person"
+
+	file := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("gotest/test/greeter.proto"),
+		Package:    proto.String("gotest.test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/wrappers.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Person"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{
+						Name:     proto.String("name"),
+						JsonName: proto.String("name"),
+						Number:   proto.Int32(1),
+						Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+						Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					},
+					{
+						Name:     proto.String("age"),
+						JsonName: proto.String("age"),
+						Number:   proto.Int32(2),
+						Type:     descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(),
+						Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					},
+				},
+			},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{
+			{
+				Name: proto.String("Greeter"),
+				Method: []*descriptorpb.MethodDescriptorProto{
+					{Name: proto.String("Say"), InputType: &stringValue, OutputType: &stringValue},
+					{Name: proto.String("Count"), InputType: &stringValue, OutputType: &stringValue, ServerStreaming: proto.Bool(true)},
+					{Name: proto.String("Collect"), InputType: &stringValue, OutputType: &stringValue, ClientStreaming: proto.Bool(true)},
+					{Name: proto.String("Person"), InputType: &stringValue, OutputType: &person},
+				},
+			},
+		},
+	}
+
+	return &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{
+			protodesc.ToFileDescriptorProto(wrapperspb.File_google_protobuf_wrappers_proto),
+			file,
+		},
+	}
+}
+
+// newServer - creates the server and a client connection
+func newServer(t *testing.T, stubs ...gotestgrpc.Stub) (*gotestgrpc.Server, *grpclib.ClientConn) {
+
+	server := gotestgrpc.NewServer(&gotestgrpc.Configuration{
+		Host:  "localhost",
+		Stubs: stubs,
+		Files: testFiles(),
+	})
+
+	conn, err := server.Dial()
+	if !assert.NoError(t, err, "expected no error connecting") {
+		t.FailNow()
+	}
+
+	return server, conn
+}
+
+// TestUnary - tests the unary calls matching the metadata
+func TestUnary(t *testing.T) {
+
+	server, conn := newServer(t,
+		gotestgrpc.Stub{
+			Method:   sayMethod,
+			Metadata: map[string]string{"tenant": "acme"},
+			Response: gotestgrpc.Response{
+				Messages: []interface{}{wrapperspb.String("hello acme")},
+				Header:   metadata.Pairs("x-served-by", "mock"),
+			},
+		},
+		gotestgrpc.Stub{
+			Method: sayMethod,
+			Response: gotestgrpc.Response{
+				Messages: []interface{}{`"hello"`},
+			},
+		},
+	)
+	defer server.Close()
+	defer conn.Close()
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "tenant", "acme")
+
+	out := &wrapperspb.StringValue{}
+	header := metadata.MD{}
+
+	err := conn.Invoke(ctx, sayMethod, wrapperspb.String("alice"), out, grpclib.Header(&header))
+	if !assert.NoError(t, err, "expected no error") {
+		return
+	}
+
+	assert.Equal(t, "hello acme", out.Value, "expected the metadata stub")
+	assert.Equal(t, []string{"mock"}, header.Get("x-served-by"), "expected the header")
+
+	err = conn.Invoke(context.Background(), sayMethod, wrapperspb.String("bob"), out)
+	if !assert.NoError(t, err, "expected no error") {
+		return
+	}
+
+	assert.Equal(t, "hello", out.Value, "expected the protojson stub")
+
+	requests := server.Requests(gotestgrpc.ByMethod(sayMethod))
+	if !assert.Len(t, requests, 2, "expected the calls in the journal") {
+		return
+	}
+
+	assert.Equal(t, []string{"acme"}, requests[0].Metadata.Get("tenant"), "expected the metadata")
+	assert.True(t, proto.Equal(wrapperspb.String("alice"), requests[0].Messages[0]), "expected the typed message")
+
+	messages, err := requests[1].MessagesJSON()
+	assert.NoError(t, err, "expected no error encoding")
+	assert.Equal(t, []string{`"bob"`}, messages, "expected the json messages")
+
+	assert.Len(t, server.Requests(gotestgrpc.ByMetadata("tenant", "acme")), 1, "expected the metadata filter")
+	assert.NotNil(t, server.TakeRequest(gotestgrpc.ByMethod(sayMethod)), "expected to take the request")
+	assert.Len(t, server.Requests(), 1, "expected the request removed")
+}
+
+// TestStatusDetails - tests the error status with details
+func TestStatusDetails(t *testing.T) {
+
+	server, conn := newServer(t, gotestgrpc.Stub{
+		Method: sayMethod,
+		Response: gotestgrpc.Response{
+			Code:    codes.NotFound,
+			Message: "no greeting",
+			Details: []proto.Message{wrapperspb.String("detail")},
+			Trailer: metadata.Pairs("x-reason", "missing"),
+		},
+	})
+	defer server.Close()
+	defer conn.Close()
+
+	trailer := metadata.MD{}
+
+	err := conn.Invoke(context.Background(), sayMethod, wrapperspb.String("alice"), &wrapperspb.StringValue{}, grpclib.Trailer(&trailer))
+
+	st := status.Convert(err)
+	assert.Equal(t, codes.NotFound, st.Code(), "expected the code")
+	assert.Equal(t, "no greeting", st.Message(), "expected the message")
+	assert.Equal(t, []string{"missing"}, trailer.Get("x-reason"), "expected the trailer")
+
+	if details := st.Details(); assert.Len(t, details, 1, "expected the details") {
+		assert.Equal(t, "detail", details[0].(*wrapperspb.StringValue).Value, "expected the detail")
+	}
+
+	assert.Len(t, server.Requests(gotestgrpc.ByCode(codes.NotFound)), 1, "expected the answered code in the journal")
+}
+
+// TestStreaming - tests the server and client streaming calls
+func TestStreaming(t *testing.T) {
+
+	server, conn := newServer(t,
+		gotestgrpc.Stub{
+			Method: countMethod,
+			Response: gotestgrpc.Response{
+				Messages: []interface{}{wrapperspb.String("1"), wrapperspb.String("2"), wrapperspb.String("3")},
+			},
+		},
+		gotestgrpc.Stub{
+			Method: collectMethod,
+			Response: gotestgrpc.Response{
+				Func: func(request *gotestgrpc.Request) gotestgrpc.Response {
+					joined := ""
+					for _, message := range request.Messages {
+						joined += message.(*wrapperspb.StringValue).Value
+					}
+					return gotestgrpc.Response{Messages: []interface{}{wrapperspb.String(joined)}}
+				},
+			},
+		},
+	)
+	defer server.Close()
+	defer conn.Close()
+
+	stream, err := conn.NewStream(context.Background(), &grpclib.StreamDesc{ServerStreams: true}, countMethod)
+	if !assert.NoError(t, err, "expected no error") {
+		return
+	}
+
+	assert.NoError(t, stream.SendMsg(wrapperspb.String("count")), "expected no error sending")
+	assert.NoError(t, stream.CloseSend(), "expected no error closing")
+
+	received := []string{}
+	for {
+		out := &wrapperspb.StringValue{}
+		if err := stream.RecvMsg(out); err == io.EOF {
+			break
+		} else if !assert.NoError(t, err, "expected no error receiving") {
+			return
+		}
+		received = append(received, out.Value)
+	}
+
+	assert.Equal(t, []string{"1", "2", "3"}, received, "expected the streamed messages")
+
+	stream, err = conn.NewStream(context.Background(), &grpclib.StreamDesc{ClientStreams: true}, collectMethod)
+	if !assert.NoError(t, err, "expected no error") {
+		return
+	}
+
+	for _, value := range []string{"a", "b", "c"} {
+		assert.NoError(t, stream.SendMsg(wrapperspb.String(value)), "expected no error sending")
+	}
+
+	assert.NoError(t, stream.CloseSend(), "expected no error closing")
+
+	out := &wrapperspb.StringValue{}
+	if assert.NoError(t, stream.RecvMsg(out), "expected no error receiving") {
+		assert.Equal(t, "abc", out.Value, "expected the collected messages")
+	}
+
+	requests := server.Requests(gotestgrpc.ByMethod(collectMethod))
+	if assert.Len(t, requests, 1, "expected the call in the journal") {
+		assert.Len(t, requests[0].Messages, 3, "expected all streamed messages")
+	}
+}
+
+// TestDynamicMessages - tests the messages only known by the file descriptor set
+func TestDynamicMessages(t *testing.T) {
+
+	server, conn := newServer(t, gotestgrpc.Stub{
+		Method: personMethod,
+		Response: gotestgrpc.Response{
+			Messages: []interface{}{map[string]interface{}{"name": "alice", "age": 30}},
+		},
+	})
+	defer server.Close()
+	defer conn.Close()
+
+	files, err := protodesc.NewFiles(testFiles())
+	if !assert.NoError(t, err, "expected valid files") {
+		return
+	}
+
+	descriptor, err := files.FindDescriptorByName("gotest.test.Person")
+	if !assert.NoError(t, err, "expected the message descriptor") {
+		return
+	}
+
+	out := dynamicpb.NewMessage(descriptor.(protoreflect.MessageDescriptor))
+
+	err = conn.Invoke(context.Background(), personMethod, wrapperspb.String("alice"), out)
+	if !assert.NoError(t, err, "expected no error") {
+		return
+	}
+
+	fields := out.Descriptor().Fields()
+	assert.Equal(t, "alice", out.Get(fields.ByName("name")).String(), "expected the name")
+	assert.Equal(t, int64(30), out.Get(fields.ByName("age")).Int(), "expected the age")
+}
+
+// TestUnknownMethods - tests the calls without stubs or descriptors
+func TestUnknownMethods(t *testing.T) {
+
+	server, conn := newServer(t, gotestgrpc.Stub{
+		Method:   sayMethod,
+		Response: gotestgrpc.Response{Messages: []interface{}{wrapperspb.String("slow")}, Wait: time.Second},
+	})
+	defer server.Close()
+	defer conn.Close()
+
+	err := conn.Invoke(context.Background(), countMethod, wrapperspb.String("x"), &wrapperspb.StringValue{})
+	assert.Equal(t, codes.Unimplemented, status.Code(err), "expected no stub")
+
+	err = conn.Invoke(context.Background(), "/gotest.test.Unknown/Call", wrapperspb.String("x"), &wrapperspb.StringValue{})
+	assert.Equal(t, codes.Unimplemented, status.Code(err), "expected an unknown service")
+
+	assert.Len(t, server.GetErrors(), 2, "expected the errors")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = conn.Invoke(ctx, sayMethod, wrapperspb.String("x"), &wrapperspb.StringValue{})
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err), "expected the deadline before the wait")
+}
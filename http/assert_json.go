@@ -0,0 +1,407 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+/**
+* Semantic json assertions for the received requests.
+* @author rnojiri
+**/
+
+const (
+	colorRed   string = "\033[31m"
+	colorGreen string = "\033[32m"
+	colorCyan  string = "\033[36m"
+	colorReset string = "\033[0m"
+)
+
+// JSONMatcher - matches a value of the actual document, can be used anywhere inside the expected document
+type JSONMatcher interface {
+	// MatchJSON - checks the decoded json value (string, float64, bool, nil, map or slice)
+	MatchJSON(value interface{}) bool
+	// String - describes the matcher in the diff
+	String() string
+}
+
+// JSONOption - changes the json comparison
+type JSONOption func(comparison *jsonComparison)
+
+// jsonComparison - the comparison options
+type jsonComparison struct {
+	ignoredPaths []*regexp.Regexp
+	color        bool
+}
+
+// jsonMatcherFunc - a matcher built from a function
+type jsonMatcherFunc struct {
+	match       func(value interface{}) bool
+	description string
+}
+
+// MatchJSON - checks the value
+func (m *jsonMatcherFunc) MatchJSON(value interface{}) bool {
+
+	return m.match(value)
+}
+
+// String - describes the matcher
+func (m *jsonMatcherFunc) String() string {
+
+	return m.description
+}
+
+// AnyValue - matches any value, including null
+func AnyValue() JSONMatcher {
+
+	return &jsonMatcherFunc{
+		match:       func(value interface{}) bool { return true },
+		description: "<any value>",
+	}
+}
+
+// AnyString - matches any string
+func AnyString() JSONMatcher {
+
+	return &jsonMatcherFunc{
+		match: func(value interface{}) bool {
+			_, ok := value.(string)
+			return ok
+		},
+		description: "<any string>",
+	}
+}
+
+// MatchRegexp - matches the strings matching the regular expression
+func MatchRegexp(pattern string) JSONMatcher {
+
+	compiled := regexp.MustCompile(pattern)
+
+	return &jsonMatcherFunc{
+		match: func(value interface{}) bool {
+			text, ok := value.(string)
+			return ok && compiled.MatchString(text)
+		},
+		description: fmt.Sprintf("<string matching %s>", pattern),
+	}
+}
+
+// NumberWithin - matches the numbers in the interval [expected-delta, expected+delta]
+func NumberWithin(expected, delta float64) JSONMatcher {
+
+	return &jsonMatcherFunc{
+		match: func(value interface{}) bool {
+			number, ok := value.(float64)
+			return ok && math.Abs(number-expected) <= delta
+		},
+		description: fmt.Sprintf("<number within %v±%v>", expected, delta),
+	}
+}
+
+// IgnorePaths - ignores the paths in both documents, like "$.timestamp", "$.items[*].id" or "$.*.name"
+func IgnorePaths(paths ...string) JSONOption {
+
+	return func(comparison *jsonComparison) {
+		for _, path := range paths {
+			comparison.ignoredPaths = append(comparison.ignoredPaths, compileJSONPath(path))
+		}
+	}
+}
+
+// WithoutColors - disables the diff colors (by default only enabled when the standard output
+// is a terminal and the NO_COLOR environment variable is not set)
+func WithoutColors() JSONOption {
+
+	return func(comparison *jsonComparison) {
+		comparison.color = false
+	}
+}
+
+// WithColors - enables the diff colors even when the output is not a terminal
+func WithColors() JSONOption {
+
+	return func(comparison *jsonComparison) {
+		comparison.color = true
+	}
+}
+
+// colorByDefault - checks if the standard output is a terminal accepting colors
+func colorByDefault() bool {
+
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+
+	info, err := os.Stdout.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// compileJSONPath - converts the path to a regular expression matching the path and its children
+func compileJSONPath(path string) *regexp.Regexp {
+
+	pattern := regexp.QuoteMeta(path)
+	pattern = strings.ReplaceAll(pattern, `\[\*\]`, `\[\d+\]`)
+	pattern = strings.ReplaceAll(pattern, `\.\*`, `\.[^.\[]+`)
+
+	return regexp.MustCompile("^" + pattern + `($|[.\[])`)
+}
+
+// AssertJSONBody - asserts the request body is semantically equal to the expected document, it can be a
+// json string or []byte or a go value (matchers are only found inside maps and slices)
+func AssertJSONBody(t testing.TB, request *Request, expected interface{}, options ...JSONOption) bool {
+
+	t.Helper()
+
+	if request == nil {
+		t.Errorf("expected a request, but it is nil")
+		return false
+	}
+
+	diff, err := DiffJSON(expected, request.Body, options...)
+	if err != nil {
+		t.Errorf("error comparing the json body of %s %s: %v", request.Method, request.URI, err)
+		return false
+	}
+
+	if diff != "" {
+		t.Errorf("the json body of %s %s is not the expected:\n%s", request.Method, request.URI, diff)
+		return false
+	}
+
+	return true
+}
+
+// DiffJSON - compares the documents returning an empty string when they are equal or an unified diff
+func DiffJSON(expected interface{}, actual []byte, options ...JSONOption) (string, error) {
+
+	comparison := &jsonComparison{
+		color: colorByDefault(),
+	}
+
+	for _, option := range options {
+		option(comparison)
+	}
+
+	expectedDocument, err := normalizeExpectedJSON(expected)
+	if err != nil {
+		return "", fmt.Errorf("invalid expected document: %w", err)
+	}
+
+	var actualDocument interface{}
+	if err := json.Unmarshal(actual, &actualDocument); err != nil {
+		return "", fmt.Errorf("invalid json body: %w", err)
+	}
+
+	reconciled := comparison.reconcile("$", expectedDocument, actualDocument, true)
+	if reflect.DeepEqual(reconciled, actualDocument) {
+		return "", nil
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(indentJSON(reconciled)),
+		B:        difflib.SplitLines(indentJSON(actualDocument)),
+		FromFile: "expected",
+		ToFile:   "actual",
+		Context:  3,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if comparison.color {
+		diff = colorizeDiff(diff)
+	}
+
+	return diff, nil
+}
+
+// normalizeExpectedJSON - converts the expected document to the decoded json types keeping the matchers
+func normalizeExpectedJSON(expected interface{}) (interface{}, error) {
+
+	switch value := expected.(type) {
+
+	case string:
+		return normalizeExpectedJSON([]byte(value))
+
+	case []byte:
+		var document interface{}
+		err := json.Unmarshal(value, &document)
+		return document, err
+
+	case JSONMatcher:
+		return value, nil
+
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			normalized, err := normalizeExpectedValue(v)
+			if err != nil {
+				return nil, err
+			}
+			result[k] = normalized
+		}
+		return result, nil
+
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, v := range value {
+			normalized, err := normalizeExpectedValue(v)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = normalized
+		}
+		return result, nil
+
+	default:
+		return roundTripJSON(value)
+	}
+}
+
+// normalizeExpectedValue - normalizes a value inside the expected document (strings are not parsed)
+func normalizeExpectedValue(value interface{}) (interface{}, error) {
+
+	switch value.(type) {
+	case string, []byte:
+		return roundTripJSON(value)
+	default:
+		return normalizeExpectedJSON(value)
+	}
+}
+
+// roundTripJSON - converts a go value to the decoded json types
+func roundTripJSON(value interface{}) (interface{}, error) {
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var document interface{}
+	err = json.Unmarshal(encoded, &document)
+
+	return document, err
+}
+
+// reconcile - returns the expected document with the matched matchers and ignored paths replaced by the actual
+// values, so the documents are deeply equal only when they match and the diff shows only the real differences
+func (c *jsonComparison) reconcile(path string, expected, actual interface{}, actualExists bool) interface{} {
+
+	if c.ignored(path) {
+		return actual
+	}
+
+	if matcher, ok := expected.(JSONMatcher); ok {
+		if actualExists && matcher.MatchJSON(actual) {
+			return actual
+		}
+		return matcher.String()
+	}
+
+	switch expectedValue := expected.(type) {
+
+	case map[string]interface{}:
+		actualMap, _ := actual.(map[string]interface{})
+
+		result := make(map[string]interface{}, len(expectedValue))
+		for k, v := range expectedValue {
+
+			childPath := path + "." + k
+			actualChild, exists := actualMap[k]
+
+			if !exists && c.ignored(childPath) {
+				continue
+			}
+
+			result[k] = c.reconcile(childPath, v, actualChild, exists)
+		}
+
+		for k, v := range actualMap {
+			if _, exists := expectedValue[k]; !exists && c.ignored(path+"."+k) {
+				result[k] = v
+			}
+		}
+
+		return result
+
+	case []interface{}:
+		actualSlice, _ := actual.([]interface{})
+
+		result := make([]interface{}, len(expectedValue))
+		for i, v := range expectedValue {
+
+			var actualChild interface{}
+			exists := i < len(actualSlice)
+			if exists {
+				actualChild = actualSlice[i]
+			}
+
+			result[i] = c.reconcile(path+"["+strconv.Itoa(i)+"]", v, actualChild, exists)
+		}
+
+		return result
+
+	default:
+		return expected
+	}
+}
+
+// ignored - checks if the path is ignored
+func (c *jsonComparison) ignored(path string) bool {
+
+	for _, ignoredPath := range c.ignoredPaths {
+		if ignoredPath.MatchString(path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// indentJSON - formats the document with sorted keys
+func indentJSON(document interface{}) string {
+
+	buffer := bytes.Buffer{}
+
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(document); err != nil {
+		return fmt.Sprintf("%v\n", document)
+	}
+
+	return buffer.String()
+}
+
+// colorizeDiff - adds terminal colors to the unified diff
+func colorizeDiff(diff string) string {
+
+	lines := strings.SplitAfter(diff, "\n")
+
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"):
+		case strings.HasPrefix(line, "-"):
+			lines[i] = colorRed + strings.TrimSuffix(line, "\n") + colorReset + "\n"
+		case strings.HasPrefix(line, "+"):
+			lines[i] = colorGreen + strings.TrimSuffix(line, "\n") + colorReset + "\n"
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = colorCyan + strings.TrimSuffix(line, "\n") + colorReset + "\n"
+		}
+	}
+
+	return strings.Join(lines, "")
+}
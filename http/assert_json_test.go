@@ -0,0 +1,82 @@
+package http_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	gotesthttp "github.com/rnojiri/gotest/http"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* The tests for the json assertions.
+* @author rnojiri
+**/
+
+// TestAssertJSONBody - tests the assertion using a request from the journal
+func TestAssertJSONBody(t *testing.T) {
+
+	endpoint := newTextEndpoint("/metrics", "ok")
+	endpoint.Methods[http.MethodPost] = gotesthttp.Response{Status: http.StatusOK}
+
+	defaultConf.T = t
+	defaultConf.Responses = map[string][]gotesthttp.Endpoint{"default": {endpoint}}
+
+	server := gotesthttp.NewServer(&defaultConf)
+	defer server.Close()
+
+	res := server.DoRequest(&gotesthttp.Request{
+		URI:    "/metrics",
+		Method: http.MethodPost,
+		Body:   []byte(`{"value": 1.0001, "metric":"cpu",  "id": "req-42", "timestamp": 1700000000, "tags": [{"host": "a", "seq": 1}, {"host": "b", "seq": 2}]}`),
+	})
+	res.Body.Close()
+
+	request := gotesthttp.WaitForServerRequest(server, 10*time.Millisecond, time.Second)
+
+	gotesthttp.AssertJSONBody(t, request, map[string]interface{}{
+		"metric": "cpu",
+		"value":  gotesthttp.NumberWithin(1, 0.01),
+		"id":     gotesthttp.MatchRegexp(`^req-\d+$`),
+		"tags": []interface{}{
+			map[string]interface{}{"host": gotesthttp.AnyString()},
+			map[string]interface{}{"host": "b"},
+		},
+	}, gotesthttp.IgnorePaths("$.timestamp", "$.tags[*].seq"))
+
+	gotesthttp.AssertJSONBody(t, request, `{
+		"tags": [{"host": "a", "seq": 1}, {"host": "b", "seq": 2}],
+		"metric": "cpu", "value": 1.0001, "id": "req-42", "timestamp": 1700000000
+	}`)
+}
+
+// TestDiffJSON - tests the diff of different documents
+func TestDiffJSON(t *testing.T) {
+
+	diff, err := gotesthttp.DiffJSON(
+		map[string]interface{}{"name": "x", "count": gotesthttp.NumberWithin(10, 1), "ignored": 1},
+		[]byte(`{"name": "y", "count": 10.5, "extra": true}`),
+		gotesthttp.IgnorePaths("$.ignored"),
+		gotesthttp.WithoutColors(),
+	)
+
+	if !assert.NoError(t, err, "expected no error comparing") {
+		return
+	}
+
+	assert.Contains(t, diff, `-  "name": "x"`, "expected the expected value in the diff")
+	assert.Contains(t, diff, `+  "name": "y"`, "expected the actual value in the diff")
+	assert.Contains(t, diff, `+  "extra": true`, "expected the extra key in the diff")
+	assert.NotContains(t, diff, `-  "count"`, "expected the matched value to be equal")
+	assert.NotContains(t, diff, "ignored", "expected the ignored path to be hidden")
+	assert.NotContains(t, diff, "\033[", "expected no colors")
+
+	diff, err = gotesthttp.DiffJSON(`{"a": "x"}`, []byte(`{"a": 1}`), gotesthttp.WithColors())
+	if assert.NoError(t, err, "expected no error comparing") {
+		assert.Equal(t, "--- expected\n+++ actual\n\033[36m@@ -1,4 +1,4 @@\033[0m\n {\n\033[31m-  \"a\": \"x\"\033[0m\n\033[32m+  \"a\": 1\033[0m\n }\n \n", diff, "expected the colored diff")
+	}
+
+	_, err = gotesthttp.DiffJSON(`{}`, []byte(`not json`))
+	assert.Error(t, err, "expected an invalid body error")
+}
@@ -0,0 +1,318 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+/**
+* Encodes the response bodies and infers their content types.
+* @author rnojiri
+**/
+
+// The inferred content types.
+const (
+	ContentTypeJSON     string = "application/json"
+	ContentTypeXML      string = "application/xml"
+	ContentTypeProtobuf string = "application/x-protobuf"
+	ContentTypeBinary   string = "application/octet-stream"
+	contentTypeHeader   string = "Content-Type"
+)
+
+// readerBodyChunkSize - the size of the chunks streamed from the reader bodies
+const readerBodyChunkSize int = 32 * 1024
+
+// BodyGenerator - streams the body, each write is flushed to the client
+type BodyGenerator func(w io.Writer) error
+
+// BodyFile - a file used as body, read from the FS or from the disk when FS is nil
+type BodyFile struct {
+	Path string
+	FS   fs.FS
+}
+
+// XMLBody - a value encoded as xml
+type XMLBody struct {
+	Value interface{}
+}
+
+// ProtoJSONBody - a protobuf message encoded as json (a proto.Message body is encoded as binary)
+type ProtoJSONBody struct {
+	Message proto.Message
+}
+
+// bodyWriter - writes an encoded body
+type bodyWriter func(w io.Writer) error
+
+// encodedBody - the encoded body and its inferred content type (empty to let the server detect it)
+type encodedBody struct {
+	contentType string
+	write       bodyWriter
+	// stream - flushes each write to the client
+	stream bool
+}
+
+// flushWriter - flushes after each write
+type flushWriter struct {
+	writer  io.Writer
+	flusher http.Flusher
+}
+
+// Write - writes and flushes
+func (fw *flushWriter) Write(p []byte) (int, error) {
+
+	n, err := fw.writer.Write(p)
+	if err == nil && fw.flusher != nil {
+		fw.flusher.Flush()
+	}
+
+	return n, err
+}
+
+// bytesBody - a body with all bytes already encoded
+func bytesBody(contentType string, data []byte) *encodedBody {
+
+	return &encodedBody{
+		contentType: contentType,
+		write: func(w io.Writer) error {
+			_, err := w.Write(data)
+			return err
+		},
+	}
+}
+
+// readerBody - a configured reader body, streamed by the first response while it is buffered
+// for the next ones (which wait the first one to finish)
+type readerBody struct {
+	reader io.Reader
+	data   []byte
+	err    error
+	read   bool
+	mutex  sync.Mutex
+}
+
+// write - streams the reader on the first call and the buffered bytes on the next ones
+func (rb *readerBody) write(w io.Writer) error {
+
+	rb.mutex.Lock()
+	defer rb.mutex.Unlock()
+
+	if rb.read {
+		if rb.err != nil {
+			return rb.err
+		}
+		_, err := w.Write(rb.data)
+		return err
+	}
+
+	rb.read = true
+
+	// the reader is consumed to the end even if the client goes away
+	buffer := bytes.Buffer{}
+	chunk := make([]byte, readerBodyChunkSize)
+
+	var writeErr error
+
+	for {
+		n, err := rb.reader.Read(chunk)
+		if n > 0 {
+			buffer.Write(chunk[:n])
+			if writeErr == nil {
+				_, writeErr = w.Write(chunk[:n])
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			rb.err = fmt.Errorf("error reading the body: %w", err)
+			return rb.err
+		}
+	}
+
+	rb.data = buffer.Bytes()
+
+	return writeErr
+}
+
+// bufferReaders - returns the endpoint with the reader bodies of its responses and webhooks
+// shared by all responses, the configured methods are not changed
+func bufferReaders(endpoint Endpoint) Endpoint {
+
+	methods := make(map[string]Response, len(endpoint.Methods))
+	changed := false
+
+	for method, response := range endpoint.Methods {
+
+		if reader, ok := response.Body.(io.Reader); ok {
+			response.Body = &readerBody{reader: reader}
+			changed = true
+		}
+
+		copied := false
+
+		for i, webhook := range response.Webhooks {
+
+			reader, ok := webhook.Body.(io.Reader)
+			if !ok {
+				continue
+			}
+
+			if !copied {
+				response.Webhooks = append([]Webhook(nil), response.Webhooks...)
+				copied = true
+			}
+
+			response.Webhooks[i].Body = &readerBody{reader: reader}
+			changed = true
+		}
+
+		methods[method] = response
+	}
+
+	if changed {
+		endpoint.Methods = methods
+	}
+
+	return endpoint
+}
+
+// encodeBody - encodes the body, the configured reader bodies are shared by bufferReaders
+// and the ones returned by a Func are consumed by its response
+func encodeBody(body interface{}) (*encodedBody, error) {
+
+	switch value := body.(type) {
+
+	case *readerBody:
+		return &encodedBody{write: value.write, stream: true}, nil
+
+	case int:
+		return bytesBody("", []byte(strconv.FormatInt(int64(value), 10))), nil
+
+	case string:
+		return bytesBody("", []byte(value)), nil
+
+	case bool:
+		return bytesBody("", []byte(strconv.FormatBool(value))), nil
+
+	case []byte:
+		return bytesBody(ContentTypeBinary, value), nil
+
+	case BodyGenerator:
+		return &encodedBody{write: bodyWriter(value), stream: true}, nil
+
+	case func(w io.Writer) error:
+		return &encodedBody{write: value, stream: true}, nil
+
+	case BodyFile:
+		return encodeFile(&value)
+
+	case *BodyFile:
+		return encodeFile(value)
+
+	case XMLBody:
+		data, err := xml.Marshal(value.Value)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling xml: %w", err)
+		}
+		return bytesBody(ContentTypeXML, append([]byte(xml.Header), data...)), nil
+
+	case ProtoJSONBody:
+		data, err := protojson.Marshal(value.Message)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling protojson: %w", err)
+		}
+		return bytesBody(ContentTypeJSON, data), nil
+
+	case proto.Message:
+		data, err := proto.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling protobuf: %w", err)
+		}
+		return bytesBody(ContentTypeProtobuf, data), nil
+
+	case io.Reader:
+		return &encodedBody{
+			write: func(w io.Writer) error {
+				_, err := io.Copy(w, value)
+				return err
+			},
+			stream: true,
+		}, nil
+
+	default:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling json: %w", err)
+		}
+		return bytesBody(ContentTypeJSON, data), nil
+	}
+}
+
+// encodeFile - opens the file and infers the content type by the extension
+func encodeFile(file *BodyFile) (*encodedBody, error) {
+
+	var data []byte
+	var err error
+
+	if file.FS != nil {
+		data, err = fs.ReadFile(file.FS, file.Path)
+	} else {
+		data, err = os.ReadFile(file.Path)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading the body file: %w", err)
+	}
+
+	return bytesBody(mime.TypeByExtension(filepath.Ext(file.Path)), data), nil
+}
+
+// writeResponse - writes the headers, status and body
+func (hs *Server) writeResponse(res http.ResponseWriter, response *Response) {
+
+	AddHeaders(res.Header(), response.Headers)
+
+	if response.Body == nil {
+		res.WriteHeader(response.Status)
+		return
+	}
+
+	body, err := encodeBody(response.Body)
+	if err != nil {
+		hs.fail(res, http.StatusInternalServerError, "%v", err)
+		return
+	}
+
+	if body.contentType != "" && res.Header().Get(contentTypeHeader) == "" {
+		res.Header().Set(contentTypeHeader, body.contentType)
+	}
+
+	res.WriteHeader(response.Status)
+
+	var writer io.Writer = res
+	if body.stream {
+		flusher, _ := res.(http.Flusher)
+		writer = &flushWriter{writer: res, flusher: flusher}
+	}
+
+	err = body.write(writer)
+	if err != nil {
+		hs.addError(err)
+	}
+}
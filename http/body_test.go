@@ -0,0 +1,228 @@
+package http_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	gotesthttp "github.com/rnojiri/gotest/http"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+/**
+* The tests for the response body types.
+* @author rnojiri
+**/
+
+// xmlItem - a xml marshalled struct
+type xmlItem struct {
+	XMLName xml.Name `xml:"item"`
+	Name    string   `xml:"name"`
+}
+
+// TestResponseBodyTypes - tests the encoding and the inferred content type of each body type
+func TestResponseBodyTypes(t *testing.T) {
+
+	message := wrapperspb.String("protobuf")
+	protoBytes, err := proto.Marshal(message)
+	if !assert.NoError(t, err, "expected no error marshaling") {
+		return
+	}
+
+	files := fstest.MapFS{"static/page.html": {Data: []byte("<p>hi</p>")}}
+
+	testCases := []struct {
+		name        string
+		body        interface{}
+		headers     http.Header
+		expected    []byte
+		contentType string
+	}{
+		{"bytes", []byte{0x01, 0x02}, nil, []byte{0x01, 0x02}, gotesthttp.ContentTypeBinary},
+		{"json", map[string]int{"a": 1}, nil, []byte(`{"a":1}`), gotesthttp.ContentTypeJSON},
+		{"overridden", map[string]int{"a": 1}, http.Header{"Content-Type": {"application/vnd.test+json"}}, []byte(`{"a":1}`), "application/vnd.test+json"},
+		{"xml", gotesthttp.XMLBody{Value: xmlItem{Name: "x"}}, nil, []byte(xml.Header + "<item><name>x</name></item>"), gotesthttp.ContentTypeXML},
+		{"protobuf", message, nil, protoBytes, gotesthttp.ContentTypeProtobuf},
+		{"protojson", gotesthttp.ProtoJSONBody{Message: message}, nil, []byte(`"protobuf"`), gotesthttp.ContentTypeJSON},
+		{"fs file", gotesthttp.BodyFile{Path: "static/page.html", FS: files}, nil, []byte("<p>hi</p>"), "text/html; charset=utf-8"},
+		{"disk file", gotesthttp.BodyFile{Path: "testdata/body.txt"}, nil, []byte("disk body\n"), "text/plain; charset=utf-8"},
+		{"reader", bytes.NewReader([]byte("read")), nil, []byte("read"), "text/plain; charset=utf-8"},
+		{"non seekable reader", io.MultiReader(strings.NewReader("multi"), strings.NewReader("reader")), nil, []byte("multireader"), "text/plain; charset=utf-8"},
+		{"generator", gotesthttp.BodyGenerator(func(w io.Writer) error {
+			for i := 0; i < 3; i++ {
+				if _, err := fmt.Fprintf(w, "chunk%d;", i); err != nil {
+					return err
+				}
+			}
+			return nil
+		}), nil, []byte("chunk0;chunk1;chunk2;"), "text/plain; charset=utf-8"},
+	}
+
+	for _, testCase := range testCases {
+
+		t.Run(testCase.name, func(t *testing.T) {
+
+			defaultConf.T = t
+			defaultConf.Responses = map[string][]gotesthttp.Endpoint{
+				"default": {
+					{
+						URI: "/body",
+						Methods: map[string]gotesthttp.Response{
+							http.MethodGet: {Body: testCase.body, Headers: testCase.headers, Status: http.StatusOK},
+						},
+					},
+				},
+			}
+
+			server := gotesthttp.NewServer(&defaultConf)
+			defer server.Close()
+
+			for i := 0; i < 2; i++ {
+
+				res := server.DoRequest(&gotesthttp.Request{URI: "/body", Method: http.MethodGet})
+
+				body, err := io.ReadAll(res.Body)
+				res.Body.Close()
+
+				if !assert.NoError(t, err, "expected no error reading") {
+					return
+				}
+
+				assert.Equal(t, testCase.expected, body, "expected the encoded body")
+				assert.Equal(t, testCase.contentType, res.Header.Get("Content-Type"), "expected the content type")
+			}
+		})
+	}
+}
+
+// TestReaderBodyConcurrentResponses - tests the reader body read once and shared by concurrent responses
+func TestReaderBodyConcurrentResponses(t *testing.T) {
+
+	server, err := gotesthttp.NewServerWithOptions(t, gotesthttp.WithEndpoints("default", gotesthttp.Endpoint{
+		URI:     "/reader",
+		Methods: map[string]gotesthttp.Response{http.MethodGet: {Body: io.MultiReader(strings.NewReader("shared"), strings.NewReader(" body")), Status: http.StatusOK}},
+	}))
+	if err != nil {
+		t.Fatalf("error creating the server: %v", err)
+	}
+
+	const numRequests int = 10
+
+	bodies := make(chan string, numRequests)
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < numRequests; i++ {
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			res := server.DoRequest(&gotesthttp.Request{URI: "/reader", Method: http.MethodGet})
+			defer res.Body.Close()
+
+			body, _ := io.ReadAll(res.Body)
+			bodies <- string(body)
+		}()
+	}
+
+	wg.Wait()
+	close(bodies)
+
+	for body := range bodies {
+		assert.Equal(t, "shared body", body, "expected the whole body in every response")
+	}
+}
+
+// TestReaderBodyStreaming - tests the reader body streamed by the first response and not read
+// when the endpoint is configured
+func TestReaderBodyStreaming(t *testing.T) {
+
+	reader, writer := io.Pipe()
+
+	server, err := gotesthttp.NewServerWithOptions(t, gotesthttp.WithEndpoints("default", gotesthttp.Endpoint{
+		URI:     "/pipe",
+		Methods: map[string]gotesthttp.Response{http.MethodGet: {Body: reader, Status: http.StatusOK}},
+	}))
+	if err != nil {
+		t.Fatalf("error creating the server: %v", err)
+	}
+
+	next := make(chan struct{})
+
+	go func() {
+		writer.Write([]byte("first chunk"))
+		<-next
+		writer.Write([]byte(";last"))
+		writer.Close()
+	}()
+
+	res := server.DoRequest(&gotesthttp.Request{URI: "/pipe", Method: http.MethodGet})
+	defer res.Body.Close()
+
+	chunk := make([]byte, len("first chunk"))
+	if _, err := io.ReadFull(res.Body, chunk); assert.NoError(t, err, "expected the chunk before the end") {
+		assert.Equal(t, "first chunk", string(chunk), "expected the streamed chunk")
+	}
+
+	close(next)
+
+	rest, err := io.ReadAll(res.Body)
+	if assert.NoError(t, err, "expected no error reading") {
+		assert.Equal(t, ";last", string(rest), "expected the end of the stream")
+	}
+
+	res = server.DoRequest(&gotesthttp.Request{URI: "/pipe", Method: http.MethodGet})
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if assert.NoError(t, err, "expected no error reading") {
+		assert.Equal(t, "first chunk;last", string(body), "expected the buffered body")
+	}
+}
+
+// TestReaderWebhookBody - tests the reader body of a webhook sent by every trigger
+func TestReaderWebhookBody(t *testing.T) {
+
+	receiver, err := gotesthttp.NewServerWithOptions(t, gotesthttp.WithEndpoints("default", gotesthttp.Endpoint{
+		URI:     "/hook",
+		Methods: map[string]gotesthttp.Response{http.MethodPost: {Status: http.StatusOK}},
+	}))
+	if err != nil {
+		t.Fatalf("error creating the server: %v", err)
+	}
+
+	sender, err := gotesthttp.NewServerWithOptions(t, gotesthttp.WithEndpoints("default", gotesthttp.Endpoint{
+		URI: "/trigger",
+		Methods: map[string]gotesthttp.Response{
+			http.MethodPost: {
+				Status:   http.StatusAccepted,
+				Webhooks: []gotesthttp.Webhook{{URL: "http://" + receiver.Address() + "/hook", Body: strings.NewReader("event")}},
+			},
+		},
+	}))
+	if err != nil {
+		t.Fatalf("error creating the server: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		res := sender.DoRequest(&gotesthttp.Request{URI: "/trigger", Method: http.MethodPost})
+		res.Body.Close()
+	}
+
+	deliveries := sender.WaitForWebhookDeliveries(2, time.Second)
+	if assert.Len(t, deliveries, 2, "expected the deliveries") {
+		for i, delivery := range deliveries {
+			assert.Equal(t, "event", string(delivery.Body), "expected the body of the delivery %d", i)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package http
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/**
+* Seeded fault injection for the server and the endpoints.
+* @author rnojiri
+**/
+
+// ChaosFault - a kind of failure injected by the chaos policy
+type ChaosFault string
+
+const (
+	// ChaosError - answers with a random status of the policy
+	ChaosError ChaosFault = "error"
+	// ChaosLatency - adds a random latency to the response
+	ChaosLatency ChaosFault = "latency"
+	// ChaosReset - resets the connection without answering
+	ChaosReset ChaosFault = "reset"
+	// ChaosTruncate - sends only the first half of the body and closes the connection
+	// (responses without body are answered with a reset)
+	ChaosTruncate ChaosFault = "truncate"
+
+	defaultChaosMaxLatency = time.Second
+	chaosErrorBody         = "chaos: injected error"
+)
+
+var (
+	allChaosFaults     = []ChaosFault{ChaosError, ChaosLatency, ChaosReset, ChaosTruncate}
+	defaultChaosStatus = []int{
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	}
+)
+
+// Chaos - the fault injection policy, the same seed injects the same faults in the same
+// sequence of requests (a policy shared by many endpoints uses a single sequence)
+type Chaos struct {
+	// Seed - the seed of the random sequence
+	Seed int64
+	// Probability - the share of requests receiving a fault (between 0 and 1)
+	Probability float64
+	// Faults - the faults chosen randomly (all by default)
+	Faults []ChaosFault
+	// Statuses - the statuses chosen randomly by ChaosError (500, 502, 503 and 504 by default)
+	Statuses []int
+	// MaxLatency - the maximum latency added by ChaosLatency (one second by default)
+	MaxLatency time.Duration
+}
+
+// ChaosInjection - the fault injected in a request
+type ChaosInjection struct {
+	Fault ChaosFault
+	// Status - the status answered by ChaosError
+	Status int
+	// Latency - the latency added by ChaosLatency
+	Latency time.Duration
+}
+
+// String - describes the fault
+func (ci *ChaosInjection) String() string {
+
+	switch ci.Fault {
+	case ChaosError:
+		return string(ci.Fault) + " " + strconv.Itoa(ci.Status)
+	case ChaosLatency:
+		return string(ci.Fault) + " " + ci.Latency.String()
+	default:
+		return string(ci.Fault)
+	}
+}
+
+// chaosState - the random sequences of the policies
+type chaosState struct {
+	sources map[*Chaos]*rand.Rand
+	mutex   sync.Mutex
+}
+
+// ByChaosFault - selects the requests receiving the fault
+func ByChaosFault(fault ChaosFault) RequestFilter {
+
+	return func(request *Request) bool {
+		return request.Chaos != nil && request.Chaos.Fault == fault
+	}
+}
+
+// roll - decides if the request receives a fault, returns nil when it does not
+func (cs *chaosState) roll(policy *Chaos) *ChaosInjection {
+
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	if cs.sources == nil {
+		cs.sources = map[*Chaos]*rand.Rand{}
+	}
+
+	source, ok := cs.sources[policy]
+	if !ok {
+		source = rand.New(rand.NewSource(policy.Seed))
+		cs.sources[policy] = source
+	}
+
+	if source.Float64() >= policy.Probability {
+		return nil
+	}
+
+	faults := policy.Faults
+	if len(faults) == 0 {
+		faults = allChaosFaults
+	}
+
+	injection := &ChaosInjection{Fault: faults[source.Intn(len(faults))]}
+
+	switch injection.Fault {
+	case ChaosError:
+		statuses := policy.Statuses
+		if len(statuses) == 0 {
+			statuses = defaultChaosStatus
+		}
+		injection.Status = statuses[source.Intn(len(statuses))]
+
+	case ChaosLatency:
+		maxLatency := policy.MaxLatency
+		if maxLatency <= 0 {
+			maxLatency = defaultChaosMaxLatency
+		}
+		injection.Latency = time.Duration(source.Int63n(int64(maxLatency))) + 1
+	}
+
+	return injection
+}
+
+// reset - restarts the random sequence of the policy
+func (cs *chaosState) reset(policy *Chaos) {
+
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	delete(cs.sources, policy)
+}
+
+// SetChaos - replaces the server chaos policy restarting its random sequence (nil disables it),
+// the endpoint policies have precedence
+func (hs *Server) SetChaos(policy *Chaos) {
+
+	hs.configMutex.Lock()
+	defer hs.configMutex.Unlock()
+
+	if policy != nil {
+		hs.chaosState.reset(policy)
+	}
+
+	hs.chaos = policy
+}
+
+// injectChaos - rolls the endpoint or server policy, changes the response of the
+// error and latency faults and returns the injected fault
+func (hs *Server) injectChaos(endpoint *Endpoint, response *Response) *ChaosInjection {
+
+	policy := endpoint.Chaos
+	if policy == nil {
+		hs.configMutex.RLock()
+		policy = hs.chaos
+		hs.configMutex.RUnlock()
+	}
+
+	if policy == nil {
+		return nil
+	}
+
+	injection := hs.chaosState.roll(policy)
+	if injection == nil {
+		return nil
+	}
+
+	switch injection.Fault {
+	case ChaosError:
+		*response = Response{Status: injection.Status, Body: chaosErrorBody}
+	case ChaosLatency:
+		response.Wait += injection.Latency
+	}
+
+	return injection
+}
+
+// breakConnection - resets the connection or writes the truncated response before closing it
+func (hs *Server) breakConnection(res http.ResponseWriter, response *Response, fault ChaosFault) {
+
+	if fault == ChaosTruncate && response.Body != nil {
+
+		body, err := encodeBody(response.Body)
+		if err != nil {
+			hs.fail(res, http.StatusInternalServerError, "%v", err)
+			return
+		}
+
+		buffer := bytes.Buffer{}
+		if err := body.write(&buffer); err != nil {
+			hs.fail(res, http.StatusInternalServerError, "%v", err)
+			return
+		}
+
+		AddHeaders(res.Header(), response.Headers)
+
+		if body.contentType != "" && res.Header().Get(contentTypeHeader) == "" {
+			res.Header().Set(contentTypeHeader, body.contentType)
+		}
+
+		res.Header().Set("Content-Length", strconv.Itoa(buffer.Len()))
+		res.WriteHeader(response.Status)
+		res.Write(buffer.Bytes()[:buffer.Len()/2])
+
+		if flusher, ok := res.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+
+	hijacker, ok := res.(http.Hijacker)
+	if !ok {
+		hs.addError(fmt.Errorf("chaos: the connection can not be hijacked"))
+		return
+	}
+
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		hs.addError(fmt.Errorf("chaos: error hijacking the connection: %w", err))
+		return
+	}
+
+	// the reset is sent by the tcp connection under the tls one
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+
+	if tcpConn, ok := conn.(*net.TCPConn); ok && fault == ChaosReset {
+		tcpConn.SetLinger(0)
+	}
+
+	conn.Close()
+}
+
+// validateChaos - checks the policy, nil policies are valid
+func validateChaos(policy *Chaos) error {
+
+	if policy == nil {
+		return nil
+	}
+
+	if policy.Probability < 0 || policy.Probability > 1 {
+		return fmt.Errorf("expected a probability between 0 and 1, found %v", policy.Probability)
+	}
+
+	for _, fault := range policy.Faults {
+		switch fault {
+		case ChaosError, ChaosLatency, ChaosReset, ChaosTruncate:
+		default:
+			return fmt.Errorf("unknown fault: %q", fault)
+		}
+	}
+
+	for _, status := range policy.Statuses {
+		if status < 100 || status > 999 {
+			return fmt.Errorf("invalid status: %d", status)
+		}
+	}
+
+	if policy.MaxLatency < 0 {
+		return fmt.Errorf("invalid maximum latency: %s", policy.MaxLatency)
+	}
+
+	return nil
+}
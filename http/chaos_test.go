@@ -0,0 +1,192 @@
+package http_test
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	gotesthttp "github.com/rnojiri/gotest/http"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* The tests for the seeded fault injection.
+* @author rnojiri
+**/
+
+// chaosRun - sends the requests returning the injected faults and the statuses
+func chaosRun(t *testing.T, policy *gotesthttp.Chaos, numRequests int) ([]string, []int) {
+
+	server, err := gotesthttp.NewServerWithOptions(t,
+		gotesthttp.WithEndpoints("default", newTextEndpoint("/x", "ok")),
+		gotesthttp.WithChaos(policy),
+	)
+	if !assert.NoError(t, err, "expected no error") {
+		return nil, nil
+	}
+
+	statuses := []int{}
+	for i := 0; i < numRequests; i++ {
+		res := server.DoRequest(&gotesthttp.Request{URI: "/x", Method: http.MethodGet})
+		res.Body.Close()
+		statuses = append(statuses, res.StatusCode)
+	}
+
+	faults := []string{}
+	for _, request := range server.Requests() {
+		if request.Chaos != nil {
+			faults = append(faults, request.Chaos.String())
+		} else {
+			faults = append(faults, "")
+		}
+	}
+
+	return faults, statuses
+}
+
+// TestChaosReplay - tests the same seed injects the same faults
+func TestChaosReplay(t *testing.T) {
+
+	policy := func(seed int64) *gotesthttp.Chaos {
+		return &gotesthttp.Chaos{
+			Seed:        seed,
+			Probability: 0.5,
+			Faults:      []gotesthttp.ChaosFault{gotesthttp.ChaosError, gotesthttp.ChaosLatency},
+			MaxLatency:  time.Millisecond,
+		}
+	}
+
+	faults, statuses := chaosRun(t, policy(42), 30)
+	replayedFaults, replayedStatuses := chaosRun(t, policy(42), 30)
+	otherFaults, _ := chaosRun(t, policy(7), 30)
+
+	assert.Equal(t, faults, replayedFaults, "expected the same faults")
+	assert.Equal(t, statuses, replayedStatuses, "expected the same statuses")
+	assert.NotEqual(t, faults, otherFaults, "expected other faults using another seed")
+
+	injected := 0
+	for i, fault := range faults {
+		if fault == "" {
+			assert.Equal(t, http.StatusOK, statuses[i], "expected the configured response")
+			continue
+		}
+		injected++
+	}
+
+	assert.Greater(t, injected, 0, "expected some faults")
+	assert.Less(t, injected, len(faults), "expected some requests without faults")
+}
+
+// TestChaosConnectionFaults - tests the reset and truncated responses recorded in the journal
+func TestChaosConnectionFaults(t *testing.T) {
+
+	reset := func(t *testing.T, res *http.Response, err error) {
+		assert.ErrorContains(t, err, "connection reset", "expected the connection reset")
+	}
+
+	testCases := []struct {
+		name   string
+		fault  gotesthttp.ChaosFault
+		tls    bool
+		expect func(t *testing.T, res *http.Response, err error)
+	}{
+		{
+			name:   "reset",
+			fault:  gotesthttp.ChaosReset,
+			expect: reset,
+		},
+		{
+			name:   "tls reset",
+			fault:  gotesthttp.ChaosReset,
+			tls:    true,
+			expect: reset,
+		},
+		{
+			name:  "truncate",
+			fault: gotesthttp.ChaosTruncate,
+			expect: func(t *testing.T, res *http.Response, err error) {
+				if !assert.NoError(t, err, "expected the headers") {
+					return
+				}
+				defer res.Body.Close()
+				body, err := io.ReadAll(res.Body)
+				assert.ErrorIs(t, err, io.ErrUnexpectedEOF, "expected the truncated body")
+				assert.Equal(t, "0123", string(body), "expected half of the body")
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+
+		t.Run(testCase.name, func(t *testing.T) {
+
+			options := []gotesthttp.Option{
+				gotesthttp.WithEndpoints("default", newTextEndpoint("/x", "01234567")),
+				gotesthttp.WithChaos(&gotesthttp.Chaos{Probability: 1, Faults: []gotesthttp.ChaosFault{testCase.fault}}),
+			}
+
+			scheme := "http"
+			if testCase.tls {
+				options = append(options, gotesthttp.WithTLS())
+				scheme = "https"
+			}
+
+			server, err := gotesthttp.NewServerWithOptions(t, options...)
+			if !assert.NoError(t, err, "expected no error") {
+				return
+			}
+
+			client := &http.Client{Transport: &http.Transport{
+				DisableKeepAlives: true,
+				TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			}}
+
+			res, err := client.Get(scheme + "://" + server.Address() + "/x")
+			testCase.expect(t, res, err)
+
+			requests := server.Requests(gotesthttp.ByChaosFault(testCase.fault))
+			assert.Len(t, requests, 1, "expected the fault in the journal")
+		})
+	}
+}
+
+// TestChaosEndpointPolicy - tests the endpoint policy precedence and disabling the server policy
+func TestChaosEndpointPolicy(t *testing.T) {
+
+	failing := newTextEndpoint("/failing", "ok")
+	failing.Chaos = &gotesthttp.Chaos{Probability: 1, Faults: []gotesthttp.ChaosFault{gotesthttp.ChaosError}, Statuses: []int{http.StatusBadGateway}}
+
+	stable := newTextEndpoint("/stable", "ok")
+	stable.Chaos = &gotesthttp.Chaos{}
+
+	server, err := gotesthttp.NewServerWithOptions(t,
+		gotesthttp.WithEndpoints("default", failing, stable, newTextEndpoint("/other", "ok")),
+		gotesthttp.WithChaos(&gotesthttp.Chaos{Probability: 1, Faults: []gotesthttp.ChaosFault{gotesthttp.ChaosError}, Statuses: []int{http.StatusServiceUnavailable}}),
+	)
+	if !assert.NoError(t, err, "expected no error") {
+		return
+	}
+
+	status, body := doGet(t, server, "/failing")
+	assert.Equal(t, http.StatusBadGateway, status, "expected the endpoint fault")
+	assert.Equal(t, "chaos: injected error", body, "expected the fault body")
+
+	status, _ = doGet(t, server, "/stable")
+	assert.Equal(t, http.StatusOK, status, "expected the endpoint policy without faults")
+
+	status, _ = doGet(t, server, "/other")
+	assert.Equal(t, http.StatusServiceUnavailable, status, "expected the server fault")
+
+	server.SetChaos(nil)
+
+	status, _ = doGet(t, server, "/other")
+	assert.Equal(t, http.StatusOK, status, "expected the server policy disabled")
+
+	_, err = gotesthttp.NewServerWithOptions(t,
+		gotesthttp.WithEndpoints("default", newTextEndpoint("/x", "ok")),
+		gotesthttp.WithChaos(&gotesthttp.Chaos{Probability: 2}),
+	)
+	assert.Error(t, err, "expected the invalid probability")
+}
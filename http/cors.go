@@ -0,0 +1,237 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+* Automatic HEAD and OPTIONS responses and the CORS policy.
+* @author rnojiri
+**/
+
+// The CORS headers.
+const (
+	originHeader               string = "Origin"
+	allowHeader                string = "Allow"
+	corsAllowOriginHeader      string = "Access-Control-Allow-Origin"
+	corsAllowMethodsHeader     string = "Access-Control-Allow-Methods"
+	corsAllowHeadersHeader     string = "Access-Control-Allow-Headers"
+	corsAllowCredentialsHeader string = "Access-Control-Allow-Credentials"
+	corsExposeHeadersHeader    string = "Access-Control-Expose-Headers"
+	corsMaxAgeHeader           string = "Access-Control-Max-Age"
+	corsRequestMethodHeader    string = "Access-Control-Request-Method"
+	corsRequestHeadersHeader   string = "Access-Control-Request-Headers"
+	corsAnyOrigin              string = "*"
+	corsRejectedBody           string = "cors: origin or method not allowed"
+)
+
+// CORS - the cross origin policy answering the preflights and adding the headers to the responses
+type CORS struct {
+	// AllowedOrigins - the allowed origins, "*" allows any
+	AllowedOrigins []string
+	// AllowedMethods - the allowed methods (the endpoint methods by default)
+	AllowedMethods []string
+	// AllowedHeaders - the allowed request headers (the requested ones by default)
+	AllowedHeaders []string
+	// ExposedHeaders - the response headers exposed to the browser
+	ExposedHeaders []string
+	// AllowCredentials - allows cookies and authorization, the origin is echoed instead of "*"
+	AllowCredentials bool
+	// MaxAge - the time the preflight can be cached
+	MaxAge time.Duration
+}
+
+// ByPreflight - selects the requests by the CORS preflight flag
+func ByPreflight(preflight bool) RequestFilter {
+
+	return func(request *Request) bool {
+		return request.Preflight == preflight
+	}
+}
+
+// isPreflight - checks if the request is a CORS preflight
+func isPreflight(req *http.Request) bool {
+
+	return req.Method == http.MethodOptions && req.Header.Get(originHeader) != "" && req.Header.Get(corsRequestMethodHeader) != ""
+}
+
+// allowedMethods - returns the sorted methods of the endpoint including the automatic ones
+func allowedMethods(endpoint *Endpoint) []string {
+
+	methods := make([]string, 0, len(endpoint.Methods)+2)
+	for method := range endpoint.Methods {
+		methods = append(methods, method)
+	}
+
+	if _, ok := endpoint.Methods[http.MethodGet]; ok {
+		if _, ok := endpoint.Methods[http.MethodHead]; !ok {
+			methods = append(methods, http.MethodHead)
+		}
+	}
+
+	if _, ok := endpoint.Methods[http.MethodOptions]; !ok {
+		methods = append(methods, http.MethodOptions)
+	}
+
+	sort.Strings(methods)
+
+	return methods
+}
+
+// corsPolicy - returns the endpoint policy or the server one
+func (hs *Server) corsPolicy(endpoint *Endpoint) *CORS {
+
+	if endpoint.CORS != nil {
+		return endpoint.CORS
+	}
+
+	return hs.configuration.CORS
+}
+
+// automaticResponse - answers HEAD using the GET response and OPTIONS with the Allow header or
+// the CORS preflight, returns the response, if it is a preflight and if the method is supported
+func automaticResponse(policy *CORS, endpoint *Endpoint, req *http.Request) (Response, bool, bool) {
+
+	switch req.Method {
+
+	case http.MethodHead:
+		response, ok := endpoint.Methods[http.MethodGet]
+		return response, false, ok
+
+	case http.MethodOptions:
+		methods := allowedMethods(endpoint)
+
+		if !isPreflight(req) || policy == nil {
+			return Response{
+				Status:  http.StatusNoContent,
+				Headers: http.Header{allowHeader: {strings.Join(methods, ", ")}},
+			}, isPreflight(req), true
+		}
+
+		return policy.preflight(methods, req), true, true
+	}
+
+	return Response{}, false, false
+}
+
+// allowOrigin - returns the Access-Control-Allow-Origin value or empty if the origin is not allowed
+func (c *CORS) allowOrigin(origin string) string {
+
+	for _, allowed := range c.AllowedOrigins {
+
+		if allowed == corsAnyOrigin {
+			if c.AllowCredentials {
+				return origin
+			}
+			return corsAnyOrigin
+		}
+
+		if strings.EqualFold(allowed, origin) {
+			return origin
+		}
+	}
+
+	return ""
+}
+
+// preflight - answers the preflight, rejected ones are answered with 403
+func (c *CORS) preflight(endpointMethods []string, req *http.Request) Response {
+
+	methods := c.AllowedMethods
+	if len(methods) == 0 {
+		methods = endpointMethods
+	}
+
+	origin := c.allowOrigin(req.Header.Get(originHeader))
+	requestedMethod := req.Header.Get(corsRequestMethodHeader)
+
+	methodAllowed := false
+	for _, method := range methods {
+		if strings.EqualFold(method, requestedMethod) {
+			methodAllowed = true
+			break
+		}
+	}
+
+	if origin == "" || !methodAllowed {
+		return Response{Status: http.StatusForbidden, Body: corsRejectedBody}
+	}
+
+	headers := http.Header{}
+	c.addOriginHeaders(origin, headers)
+	headers.Set(corsAllowMethodsHeader, strings.Join(methods, ", "))
+
+	if len(c.AllowedHeaders) > 0 {
+		headers.Set(corsAllowHeadersHeader, strings.Join(c.AllowedHeaders, ", "))
+	} else if requested := req.Header.Get(corsRequestHeadersHeader); requested != "" {
+		headers.Set(corsAllowHeadersHeader, requested)
+	}
+
+	if c.MaxAge > 0 {
+		headers.Set(corsMaxAgeHeader, strconv.FormatInt(int64(c.MaxAge.Seconds()), 10))
+	}
+
+	return Response{Status: http.StatusNoContent, Headers: headers}
+}
+
+// addOriginHeaders - adds the allowed origin and the credentials headers
+func (c *CORS) addOriginHeaders(origin string, headers http.Header) {
+
+	headers.Set(corsAllowOriginHeader, origin)
+
+	if origin != corsAnyOrigin {
+		headers.Add(varyHeader, originHeader)
+	}
+
+	if c.AllowCredentials {
+		headers.Set(corsAllowCredentialsHeader, "true")
+	}
+}
+
+// addCORSHeaders - adds the CORS headers to the response of an allowed cross origin request
+func addCORSHeaders(policy *CORS, req *http.Request, headers http.Header) {
+
+	if policy == nil || req.Header.Get(originHeader) == "" {
+		return
+	}
+
+	origin := policy.allowOrigin(req.Header.Get(originHeader))
+	if origin == "" {
+		return
+	}
+
+	policy.addOriginHeaders(origin, headers)
+
+	if len(policy.ExposedHeaders) > 0 {
+		headers.Set(corsExposeHeadersHeader, strings.Join(policy.ExposedHeaders, ", "))
+	}
+}
+
+// validateCORS - checks the policy, nil policies are valid
+func validateCORS(policy *CORS) error {
+
+	if policy == nil {
+		return nil
+	}
+
+	if len(policy.AllowedOrigins) == 0 {
+		return fmt.Errorf("expected at least one allowed origin")
+	}
+
+	for _, method := range policy.AllowedMethods {
+		if method == "" || strings.ContainsAny(method, " \t\r\n") {
+			return fmt.Errorf("invalid method %q", method)
+		}
+	}
+
+	if policy.MaxAge < 0 {
+		return fmt.Errorf("invalid max age: %s", policy.MaxAge)
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package http_test
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	gotesthttp "github.com/rnojiri/gotest/http"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* The tests for the automatic HEAD and OPTIONS and the CORS policy.
+* @author rnojiri
+**/
+
+// newCORSServer - creates a server with a resource answering GET and POST
+func newCORSServer(t *testing.T, options ...gotesthttp.Option) *gotesthttp.Server {
+
+	options = append([]gotesthttp.Option{
+		gotesthttp.WithEndpoints("default", gotesthttp.Endpoint{
+			URI: "/items",
+			Methods: map[string]gotesthttp.Response{
+				http.MethodGet:  {Status: http.StatusOK, Body: "items", Headers: http.Header{"X-Total": {"1"}}},
+				http.MethodPost: {Status: http.StatusCreated},
+			},
+		}),
+	}, options...)
+
+	server, err := gotesthttp.NewServerWithOptions(t, options...)
+	if err != nil {
+		t.Fatalf("error creating the server: %v", err)
+	}
+
+	return server
+}
+
+// TestAutomaticMethods - tests the HEAD using the GET response and the OPTIONS with the Allow header
+func TestAutomaticMethods(t *testing.T) {
+
+	server := newCORSServer(t)
+
+	res := server.DoRequest(&gotesthttp.Request{URI: "/items", Method: http.MethodHead})
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+
+	assert.NoError(t, err, "expected no error reading the body")
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected the GET status")
+	assert.Equal(t, "1", res.Header.Get("X-Total"), "expected the GET headers")
+	assert.Empty(t, body, "expected no body")
+
+	res = server.DoRequest(&gotesthttp.Request{URI: "/items", Method: http.MethodOptions})
+	res.Body.Close()
+
+	assert.Equal(t, http.StatusNoContent, res.StatusCode, "expected no content")
+	assert.Equal(t, "GET, HEAD, OPTIONS, POST", res.Header.Get("Allow"), "expected the allowed methods")
+
+	requests := server.Requests()
+	if assert.Len(t, requests, 2, "expected the automatic requests recorded") {
+		assert.Equal(t, http.MethodHead, requests[0].Method, "expected the HEAD")
+		assert.Equal(t, http.MethodOptions, requests[1].Method, "expected the OPTIONS")
+		assert.False(t, requests[1].Preflight, "expected no preflight")
+	}
+}
+
+// TestCORSPreflight - tests the preflights answered by the policy
+func TestCORSPreflight(t *testing.T) {
+
+	server := newCORSServer(t, gotesthttp.WithCORS(&gotesthttp.CORS{
+		AllowedOrigins:   []string{"https://app.test"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}))
+
+	testCases := []struct {
+		name    string
+		origin  string
+		method  string
+		status  int
+		headers map[string]string
+	}{
+		{
+			name:   "allowed",
+			origin: "https://app.test",
+			method: http.MethodPost,
+			status: http.StatusNoContent,
+			headers: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.test",
+				"Access-Control-Allow-Methods":     "GET, HEAD, OPTIONS, POST",
+				"Access-Control-Allow-Headers":     "Authorization, Content-Type",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Max-Age":           "600",
+				"Vary":                             "Origin",
+			},
+		},
+		{
+			name:   "origin not allowed",
+			origin: "https://evil.test",
+			method: http.MethodPost,
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "method not allowed",
+			origin: "https://app.test",
+			method: http.MethodDelete,
+			status: http.StatusForbidden,
+		},
+	}
+
+	for _, testCase := range testCases {
+
+		t.Run(testCase.name, func(t *testing.T) {
+
+			res := server.DoRequest(&gotesthttp.Request{
+				URI:    "/items",
+				Method: http.MethodOptions,
+				Headers: http.Header{
+					"Origin":                        {testCase.origin},
+					"Access-Control-Request-Method": {testCase.method},
+				},
+				Tag: t.Name(),
+			})
+			res.Body.Close()
+
+			assert.Equal(t, testCase.status, res.StatusCode, "expected the preflight status")
+
+			for name, value := range testCase.headers {
+				assert.Equal(t, value, res.Header.Get(name), "expected the header: %s", name)
+			}
+
+			preflights := server.Requests(gotesthttp.ByPreflight(true), gotesthttp.ByTag(t.Name()))
+			assert.Len(t, preflights, 1, "expected the preflight recorded")
+		})
+	}
+}
+
+// TestCORSResponses - tests the headers of the cross origin responses and the endpoint policy
+func TestCORSResponses(t *testing.T) {
+
+	server := newCORSServer(t, gotesthttp.WithCORS(&gotesthttp.CORS{
+		AllowedOrigins: []string{"*"},
+		ExposedHeaders: []string{"X-Total"},
+	}))
+
+	res := server.DoRequest(&gotesthttp.Request{URI: "/items", Method: http.MethodGet, Headers: http.Header{"Origin": {"https://any.test"}}})
+	res.Body.Close()
+
+	assert.Equal(t, "*", res.Header.Get("Access-Control-Allow-Origin"), "expected any origin")
+	assert.Equal(t, "X-Total", res.Header.Get("Access-Control-Expose-Headers"), "expected the exposed headers")
+	assert.Empty(t, res.Header.Get("Vary"), "expected no vary for any origin")
+
+	res = server.DoRequest(&gotesthttp.Request{URI: "/items", Method: http.MethodGet})
+	res.Body.Close()
+
+	assert.Empty(t, res.Header.Get("Access-Control-Allow-Origin"), "expected no cors headers without origin")
+
+	restricted := newTextEndpoint("/restricted", "ok")
+	restricted.CORS = &gotesthttp.CORS{AllowedOrigins: []string{"https://admin.test"}}
+	server.AddEndpoint("default", restricted)
+
+	res = server.DoRequest(&gotesthttp.Request{URI: "/restricted", Method: http.MethodGet, Headers: http.Header{"Origin": {"https://any.test"}}})
+	res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected the response")
+	assert.Empty(t, res.Header.Get("Access-Control-Allow-Origin"), "expected the endpoint policy rejecting the origin")
+
+	_, err := gotesthttp.NewServerWithOptions(t,
+		gotesthttp.WithEndpoints("default", newTextEndpoint("/x", "ok")),
+		gotesthttp.WithCORS(&gotesthttp.CORS{}),
+	)
+	assert.Error(t, err, "expected the policy without origins")
+}
@@ -0,0 +1,306 @@
+package http
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+/**
+* Golden file snapshots of the requests received by the server.
+* @author rnojiri
+**/
+
+const (
+	// GoldenUpdateEnv - the environment variable rewriting the golden files when true
+	GoldenUpdateEnv  string = "GOTEST_UPDATE_GOLDEN"
+	updateFlagName   string = "update"
+	normalizedValue  string = "<normalized>"
+	goldenIndent     string = "  "
+	goldenFileMode          = 0644
+	goldenFolderMode        = 0755
+)
+
+var (
+	uuidRegexp      = regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`)
+	timestampRegexp = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?`)
+)
+
+// GoldenNormalizer - changes a copy of the request before the serialization
+type GoldenNormalizer func(request *Request)
+
+// NormalizeHeaders - replaces the values of the headers
+func NormalizeHeaders(names ...string) GoldenNormalizer {
+
+	return func(request *Request) {
+		for _, name := range names {
+			if _, ok := request.Headers[http.CanonicalHeaderKey(name)]; ok {
+				request.Headers.Set(name, normalizedValue)
+			}
+		}
+	}
+}
+
+// RemoveHeaders - removes the headers
+func RemoveHeaders(names ...string) GoldenNormalizer {
+
+	return func(request *Request) {
+		for _, name := range names {
+			request.Headers.Del(name)
+		}
+	}
+}
+
+// NormalizeRegexp - replaces the matches in the uri, header values and body
+func NormalizeRegexp(pattern *regexp.Regexp, replacement string) GoldenNormalizer {
+
+	return func(request *Request) {
+
+		request.URI = pattern.ReplaceAllString(request.URI, replacement)
+		request.Body = pattern.ReplaceAll(request.Body, []byte(replacement))
+
+		for _, values := range request.Headers {
+			for i := range values {
+				values[i] = pattern.ReplaceAllString(values[i], replacement)
+			}
+		}
+	}
+}
+
+// NormalizeUUIDs - replaces the uuids in the uri, header values and body
+func NormalizeUUIDs() GoldenNormalizer {
+
+	return NormalizeRegexp(uuidRegexp, "<uuid>")
+}
+
+// NormalizeTimestamps - replaces the RFC 3339 like timestamps in the uri, header values and body
+func NormalizeTimestamps() GoldenNormalizer {
+
+	return NormalizeRegexp(timestampRegexp, "<timestamp>")
+}
+
+// AddGoldenNormalizers - adds normalizers applied before comparing the golden files
+func (hs *Server) AddGoldenNormalizers(normalizers ...GoldenNormalizer) {
+
+	hs.mutex.Lock()
+	defer hs.mutex.Unlock()
+
+	hs.goldenNormalizers = append(hs.goldenNormalizers, normalizers...)
+}
+
+// updateGolden - checks the GOTEST_UPDATE_GOLDEN environment variable or the -update flag
+// when the test package defines it (the library does not register flags)
+func updateGolden() bool {
+
+	if update, err := strconv.ParseBool(os.Getenv(GoldenUpdateEnv)); err == nil {
+		return update
+	}
+
+	updateFlag := flag.Lookup(updateFlagName)
+
+	return updateFlag != nil && updateFlag.Value.String() == "true"
+}
+
+// AssertGolden - compares the received requests with the golden file, the file is rewritten when
+// the GOTEST_UPDATE_GOLDEN environment variable is true or the -update flag of the test package is set
+func (hs *Server) AssertGolden(t testing.TB, path string) bool {
+
+	t.Helper()
+
+	hs.mutex.Lock()
+	normalizers := append([]GoldenNormalizer{}, hs.goldenNormalizers...)
+	hs.mutex.Unlock()
+
+	actual := FormatGolden(hs.Requests(), normalizers...)
+
+	if updateGolden() {
+
+		if err := os.MkdirAll(filepath.Dir(path), goldenFolderMode); err != nil {
+			t.Errorf("error creating the golden file folder: %v", err)
+			return false
+		}
+
+		if err := os.WriteFile(path, []byte(actual), goldenFileMode); err != nil {
+			t.Errorf("error writing the golden file: %v", err)
+			return false
+		}
+
+		return true
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Errorf("error reading the golden file (run with %s=true to create it): %v", GoldenUpdateEnv, err)
+		return false
+	}
+
+	if string(expected) == actual {
+		return true
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(expected)),
+		B:        difflib.SplitLines(actual),
+		FromFile: path,
+		ToFile:   "actual",
+		Context:  3,
+	})
+	if err != nil {
+		diff = err.Error()
+	}
+
+	t.Errorf("the requests do not match the golden file (run with %s=true to rewrite it):\n%s", GoldenUpdateEnv, diff)
+
+	return false
+}
+
+// FormatGolden - serializes the requests in the stable golden file format
+func FormatGolden(requests []Request, normalizers ...GoldenNormalizer) string {
+
+	buffer := strings.Builder{}
+
+	for i := range requests {
+
+		request := copyRequest(&requests[i])
+		for _, normalizer := range normalizers {
+			normalizer(request)
+		}
+
+		if i > 0 {
+			buffer.WriteString("\n")
+		}
+
+		fmt.Fprintf(&buffer, "# request %d\n", i+1)
+		fmt.Fprintf(&buffer, "%s %s\n", request.Method, request.URI)
+
+		if request.Mode != "" {
+			fmt.Fprintf(&buffer, "mode: %s\n", request.Mode)
+		}
+
+		if request.Tag != "" {
+			fmt.Fprintf(&buffer, "tag: %s\n", request.Tag)
+		}
+
+		if request.VirtualHost != "" {
+			fmt.Fprintf(&buffer, "host: %s\n", request.VirtualHost)
+		}
+
+		if request.Throttled {
+			buffer.WriteString("throttled: true\n")
+		}
+
+		if request.Representation != "" {
+			fmt.Fprintf(&buffer, "representation: %s\n", request.Representation)
+		}
+
+		if request.GraphQL != nil {
+			fmt.Fprintf(&buffer, "graphql: %s %s\n", request.GraphQL.Type, request.GraphQL.Name)
+		}
+
+		if request.JSONRPC != nil {
+			fmt.Fprintf(&buffer, "jsonrpc: %s\n", request.JSONRPC.Method)
+		}
+
+		if request.Chaos != nil {
+			fmt.Fprintf(&buffer, "chaos: %s\n", request.Chaos)
+		}
+
+		if request.Passthrough {
+			buffer.WriteString("passthrough: true\n")
+		}
+
+		if request.Preflight {
+			buffer.WriteString("preflight: true\n")
+		}
+
+		if request.Signature != nil {
+			fmt.Fprintf(&buffer, "signature: %s\n", request.Signature)
+		}
+
+		if request.Aborted {
+			buffer.WriteString("aborted: true\n")
+		}
+
+		if len(request.Headers) > 0 {
+
+			buffer.WriteString("headers:\n")
+
+			names := make([]string, 0, len(request.Headers))
+			for name := range request.Headers {
+				names = append(names, name)
+			}
+
+			sort.Strings(names)
+
+			for _, name := range names {
+				for _, value := range request.Headers[name] {
+					fmt.Fprintf(&buffer, "%s%s: %s\n", goldenIndent, name, value)
+				}
+			}
+		}
+
+		if len(request.Body) > 0 {
+			buffer.WriteString("body:\n")
+			for _, line := range strings.Split(formatGoldenBody(request.Body), "\n") {
+				buffer.WriteString(goldenIndent + line + "\n")
+			}
+		}
+	}
+
+	return buffer.String()
+}
+
+// formatGoldenBody - indents json bodies (sorting the keys) and encodes binary bodies as base64
+func formatGoldenBody(body []byte) string {
+
+	var document interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	if decoder.Decode(&document) == nil && !decoder.More() {
+
+		indented := bytes.Buffer{}
+
+		encoder := json.NewEncoder(&indented)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", goldenIndent)
+
+		if err := encoder.Encode(document); err == nil {
+			return strings.TrimSuffix(indented.String(), "\n")
+		}
+	}
+
+	if !utf8.Valid(body) {
+		return "base64:" + base64.StdEncoding.EncodeToString(body)
+	}
+
+	return strings.TrimSuffix(string(body), "\n")
+}
+
+// copyRequest - copies the request, including the headers and the body
+func copyRequest(request *Request) *Request {
+
+	result := *request
+	result.Headers = request.Headers.Clone()
+	result.Body = append([]byte(nil), request.Body...)
+
+	if result.Headers == nil {
+		result.Headers = http.Header{}
+	}
+
+	return &result
+}
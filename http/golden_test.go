@@ -0,0 +1,106 @@
+package http_test
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gotesthttp "github.com/rnojiri/gotest/http"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* The tests for the golden file snapshots.
+* @author rnojiri
+**/
+
+// update - the flag defined by the test package is also used by AssertGolden
+var update = flag.Bool("update", false, "rewrites the golden files")
+
+// TestAssertGolden - tests the journal against a checked-in golden file
+func TestAssertGolden(t *testing.T) {
+
+	endpoint := newTextEndpoint("/events", "ok")
+	endpoint.Methods[http.MethodPost] = gotesthttp.Response{Status: http.StatusAccepted}
+
+	defaultConf.T = t
+	defaultConf.Responses = map[string][]gotesthttp.Endpoint{"default": {endpoint}}
+
+	server := gotesthttp.NewServer(&defaultConf)
+	defer server.Close()
+
+	server.AddGoldenNormalizers(
+		gotesthttp.NormalizeUUIDs(),
+		gotesthttp.NormalizeTimestamps(),
+		gotesthttp.NormalizeHeaders("X-Request-Id"),
+		gotesthttp.RemoveHeaders("User-Agent", "Accept-Encoding"),
+	)
+
+	res := server.DoRequest(&gotesthttp.Request{
+		URI:    "/events",
+		Method: http.MethodPost,
+		Body:   []byte(`{"when": "2024-05-01T10:20:30.123Z", "id": "0b9e8a52-3c1d-4c7e-9f4a-1f2e3d4c5b6a", "b": 1, "a": [true]}`),
+		Headers: http.Header{
+			"X-Request-Id": []string{"run-specific"},
+			"Content-Type": []string{"application/json"},
+		},
+	})
+	res.Body.Close()
+
+	res = server.DoRequest(&gotesthttp.Request{
+		URI:    "/events",
+		Method: http.MethodGet,
+		Tag:    "second",
+	})
+	res.Body.Close()
+
+	server.AssertGolden(t, "testdata/journal.golden")
+}
+
+// TestGoldenUpdate - tests rewriting the golden files by the environment variable and the test flag
+func TestGoldenUpdate(t *testing.T) {
+
+	server, err := gotesthttp.NewServerWithOptions(t, gotesthttp.WithEndpoints("default", newTextEndpoint("/a", "a")))
+	if err != nil {
+		t.Fatalf("error creating the server: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "testdata", "update.golden")
+
+	doGet(t, server, "/a")
+
+	t.Setenv(gotesthttp.GoldenUpdateEnv, "true")
+	assert.True(t, server.AssertGolden(t, path), "expected the golden file created")
+
+	t.Setenv(gotesthttp.GoldenUpdateEnv, "")
+	assert.True(t, server.AssertGolden(t, path), "expected the created golden file")
+
+	doGet(t, server, "/a")
+
+	assert.NoError(t, flag.Set("update", "true"), "expected the flag set")
+	defer flag.Set("update", "false")
+
+	assert.True(t, *update, "expected the flag of the test package")
+	assert.True(t, server.AssertGolden(t, path), "expected the golden file rewritten by the flag")
+
+	content, err := os.ReadFile(path)
+	if assert.NoError(t, err, "expected the golden file") {
+		assert.Equal(t, gotesthttp.FormatGolden(server.Requests()), string(content), "expected both requests")
+	}
+}
+
+// TestFormatGolden - tests the golden format of text and binary bodies
+func TestFormatGolden(t *testing.T) {
+
+	formatted := gotesthttp.FormatGolden([]gotesthttp.Request{
+		{Method: http.MethodPut, URI: "/text", Body: []byte("line1\nline2\n")},
+		{Method: http.MethodPut, URI: "/binary", Body: []byte{0xff, 0xfe}, Throttled: true},
+	})
+
+	expected := "# request 1\nPUT /text\nbody:\n  line1\n  line2\n\n" +
+		"# request 2\nPUT /binary\nthrottled: true\nbody:\n  base64://4=\n"
+
+	assert.Equal(t, expected, formatted, "expected the golden format")
+}
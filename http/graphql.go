@@ -0,0 +1,264 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/parser"
+	"github.com/vektah/gqlparser/v2/validator"
+)
+
+/**
+* Mocks a GraphQL endpoint matching stubs by operation.
+* @author rnojiri
+**/
+
+// The GraphQL operation types.
+const (
+	GraphQLQuery        string = "query"
+	GraphQLMutation     string = "mutation"
+	GraphQLSubscription string = "subscription"
+)
+
+const graphQLSchemaName string = "schema.graphql"
+
+// GraphQLConfiguration - the GraphQL endpoint configuration
+type GraphQLConfiguration struct {
+	// Schema - the SDL schema used to validate the operations (no validation when empty)
+	Schema string
+	// Stubs - the stubs, the first one matching the operation answers
+	Stubs []GraphQLStub
+}
+
+// GraphQLStub - the answer of the matching operations
+type GraphQLStub struct {
+	// OperationName - the operation name (empty matches any name)
+	OperationName string
+	// OperationType - GraphQLQuery, GraphQLMutation or GraphQLSubscription (empty matches any type)
+	OperationType string
+	// Variables - the expected variables, the ones not listed are not compared and the values can be JSONMatchers
+	Variables map[string]interface{}
+	// Data - the data payload
+	Data interface{}
+	// Errors - the errors payload
+	Errors []GraphQLError
+}
+
+// GraphQLError - a GraphQL error
+type GraphQLError struct {
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// GraphQLOperation - the parsed operation of a received request
+type GraphQLOperation struct {
+	Name      string
+	Type      string
+	Query     string
+	Variables map[string]interface{}
+}
+
+// graphQLRequest - the GraphQL request parameters
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// graphQLResponse - the GraphQL response payload
+type graphQLResponse struct {
+	Data   interface{}    `json:"data,omitempty"`
+	Errors []GraphQLError `json:"errors,omitempty"`
+}
+
+// graphQLHandler - answers the operations using the stubs
+type graphQLHandler struct {
+	schema *ast.Schema
+	stubs  []GraphQLStub
+}
+
+// GraphQLEndpoint - creates an endpoint answering the GraphQL operations posted as json, panics if the schema is invalid
+func GraphQLEndpoint(uri string, configuration *GraphQLConfiguration) Endpoint {
+
+	if configuration == nil {
+		panic(fmt.Errorf("null configuration"))
+	}
+
+	gh := &graphQLHandler{
+		stubs: append([]GraphQLStub{}, configuration.Stubs...),
+	}
+
+	if configuration.Schema != "" {
+
+		schema, err := gqlparser.LoadSchema(&ast.Source{Name: graphQLSchemaName, Input: configuration.Schema})
+		if err != nil {
+			panic(fmt.Errorf("invalid graphql schema: %w", err))
+		}
+
+		gh.schema = schema
+	}
+
+	for i := range gh.stubs {
+		if gh.stubs[i].Variables == nil {
+			continue
+		}
+
+		variables, err := normalizeExpectedJSON(gh.stubs[i].Variables)
+		if err != nil {
+			panic(fmt.Errorf("invalid variables in the stub %d: %w", i, err))
+		}
+
+		gh.stubs[i].Variables = variables.(map[string]interface{})
+	}
+
+	return Endpoint{
+		URI: uri,
+		Methods: map[string]Response{
+			http.MethodPost: {Func: gh.answer},
+		},
+	}
+}
+
+// ByGraphQLOperation - selects the GraphQL requests by the operation name
+func ByGraphQLOperation(name string) RequestFilter {
+
+	return func(request *Request) bool {
+		return request.GraphQL != nil && request.GraphQL.Name == name
+	}
+}
+
+// answer - parses the operation and answers using the first matching stub
+func (gh *graphQLHandler) answer(request *Request) Response {
+
+	params, err := readGraphQLRequest(request)
+	if err != nil {
+		return graphQLErrorResponse(http.StatusBadRequest, err.Error())
+	}
+
+	document, err := parser.ParseQuery(&ast.Source{Input: params.Query})
+	if err != nil {
+		return graphQLErrorResponse(http.StatusBadRequest, err.Error())
+	}
+
+	operation := document.Operations.ForName(params.OperationName)
+	if operation == nil {
+		if params.OperationName == "" {
+			return graphQLErrorResponse(http.StatusBadRequest, "the operation name is required when the document has many operations")
+		}
+		return graphQLErrorResponse(http.StatusBadRequest, fmt.Sprintf("unknown operation named: %s", params.OperationName))
+	}
+
+	if params.Variables == nil {
+		params.Variables = map[string]interface{}{}
+	}
+
+	request.GraphQL = &GraphQLOperation{
+		Name:      operation.Name,
+		Type:      string(operation.Operation),
+		Query:     params.Query,
+		Variables: params.Variables,
+	}
+
+	if gh.schema != nil {
+
+		validated, errs := gqlparser.LoadQuery(gh.schema, params.Query)
+		if len(errs) > 0 {
+
+			messages := make([]string, len(errs))
+			for i, e := range errs {
+				messages[i] = e.Message
+			}
+
+			return graphQLErrorResponse(http.StatusBadRequest, messages...)
+		}
+
+		// the validated document has the variable definitions bound to the schema types
+		operation = validated.Operations.ForName(params.OperationName)
+
+		if _, err := validator.VariableValues(gh.schema, operation, params.Variables); err != nil {
+			return graphQLErrorResponse(http.StatusBadRequest, err.Error())
+		}
+	}
+
+	for i := range gh.stubs {
+		if gh.stubs[i].matches(request.GraphQL) {
+			return graphQLResponseOf(http.StatusOK, gh.stubs[i].Data, gh.stubs[i].Errors)
+		}
+	}
+
+	return graphQLErrorResponse(http.StatusOK, fmt.Sprintf("no stub configured for the %s operation: %s", request.GraphQL.Type, request.GraphQL.Name))
+}
+
+// readGraphQLRequest - reads the parameters from the json body
+func readGraphQLRequest(request *Request) (*graphQLRequest, error) {
+
+	params := &graphQLRequest{}
+
+	if err := json.Unmarshal(request.Body, params); err != nil {
+		return nil, fmt.Errorf("invalid graphql request: %w", err)
+	}
+
+	if params.Query == "" {
+		return nil, fmt.Errorf("no query found in the request")
+	}
+
+	return params, nil
+}
+
+// matches - checks the operation name, type and variables
+func (s *GraphQLStub) matches(operation *GraphQLOperation) bool {
+
+	if s.OperationName != "" && s.OperationName != operation.Name {
+		return false
+	}
+
+	if s.OperationType != "" && s.OperationType != operation.Type {
+		return false
+	}
+
+	if s.Variables == nil {
+		return true
+	}
+
+	comparison := &jsonComparison{}
+
+	for name, expected := range s.Variables {
+
+		value, exists := operation.Variables[name]
+		if !exists {
+			return false
+		}
+
+		if !reflect.DeepEqual(comparison.reconcile("$."+name, expected, value, true), value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// graphQLResponseOf - builds the json response with the payload
+func graphQLResponseOf(status int, data interface{}, errors []GraphQLError) Response {
+
+	return Response{
+		Status:  status,
+		Body:    graphQLResponse{Data: data, Errors: errors},
+		Headers: http.Header{contentTypeHeader: []string{ContentTypeJSON}},
+	}
+}
+
+// graphQLErrorResponse - builds a response with only errors
+func graphQLErrorResponse(status int, messages ...string) Response {
+
+	errors := make([]GraphQLError, len(messages))
+	for i, message := range messages {
+		errors[i] = GraphQLError{Message: message}
+	}
+
+	return graphQLResponseOf(status, nil, errors)
+}
@@ -0,0 +1,184 @@
+package http_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	gotesthttp "github.com/rnojiri/gotest/http"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* The tests for the GraphQL endpoint.
+* @author rnojiri
+**/
+
+const graphQLTestSchema string = `
+type User {
+	id: ID!
+	name: String!
+}
+
+type Query {
+	user(id: ID!): User
+}
+
+type Mutation {
+	rename(id: ID!, name: String!): User
+}
+`
+
+// newGraphQLServer - creates a server with a GraphQL endpoint
+func newGraphQLServer(t *testing.T, schema string) *gotesthttp.Server {
+
+	defaultConf.T = t
+	defaultConf.Responses = map[string][]gotesthttp.Endpoint{
+		"default": {
+			gotesthttp.GraphQLEndpoint("/graphql", &gotesthttp.GraphQLConfiguration{
+				Schema: schema,
+				Stubs: []gotesthttp.GraphQLStub{
+					{
+						OperationName: "GetUser",
+						Variables:     map[string]interface{}{"id": "1"},
+						Data:          map[string]interface{}{"user": map[string]interface{}{"id": "1", "name": "alice"}},
+					},
+					{
+						OperationName: "GetUser",
+						Errors:        []gotesthttp.GraphQLError{{Message: "user not found", Path: []interface{}{"user"}}},
+					},
+					{
+						OperationType: gotesthttp.GraphQLMutation,
+						Variables:     map[string]interface{}{"name": gotesthttp.AnyString()},
+						Data:          map[string]interface{}{"rename": map[string]interface{}{"id": "1", "name": "bob"}},
+					},
+				},
+			}),
+		},
+	}
+
+	return gotesthttp.NewServer(&defaultConf)
+}
+
+// postGraphQL - posts the operation returning the status and the decoded payload
+func postGraphQL(t *testing.T, server *gotesthttp.Server, operationName, query string, variables map[string]interface{}) (int, map[string]interface{}) {
+
+	body, err := json.Marshal(map[string]interface{}{
+		"query":         query,
+		"operationName": operationName,
+		"variables":     variables,
+	})
+	if !assert.NoError(t, err, "expected no error marshaling") {
+		return 0, nil
+	}
+
+	res := server.DoRequest(&gotesthttp.Request{URI: "/graphql", Method: http.MethodPost, Body: body})
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if !assert.NoError(t, err, "expected no error reading") {
+		return 0, nil
+	}
+
+	payload := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(data, &payload), "expected a json payload: %s", data)
+
+	return res.StatusCode, payload
+}
+
+// TestGraphQLStubs - tests matching the stubs by operation name, type and variables
+func TestGraphQLStubs(t *testing.T) {
+
+	server := newGraphQLServer(t, "")
+	defer server.Close()
+
+	query := `query GetUser($id: ID!) { user(id: $id) { id name } }`
+
+	status, payload := postGraphQL(t, server, "", query, map[string]interface{}{"id": "1"})
+	assert.Equal(t, http.StatusOK, status, "expected ok")
+	assert.Equal(t, map[string]interface{}{"user": map[string]interface{}{"id": "1", "name": "alice"}}, payload["data"], "expected the data")
+	assert.Nil(t, payload["errors"], "expected no errors")
+
+	status, payload = postGraphQL(t, server, "GetUser", query, map[string]interface{}{"id": "2"})
+	assert.Equal(t, http.StatusOK, status, "expected ok")
+	assert.Nil(t, payload["data"], "expected no data")
+	assert.Equal(t, []interface{}{map[string]interface{}{"message": "user not found", "path": []interface{}{"user"}}}, payload["errors"], "expected the errors")
+
+	mutation := `mutation Rename($id: ID!, $name: String!) { rename(id: $id, name: $name) { id name } }`
+
+	status, payload = postGraphQL(t, server, "Rename", mutation, map[string]interface{}{"id": "1", "name": "bob"})
+	assert.Equal(t, http.StatusOK, status, "expected ok")
+	assert.Equal(t, "bob", payload["data"].(map[string]interface{})["rename"].(map[string]interface{})["name"], "expected the mutation data")
+
+	status, payload = postGraphQL(t, server, "", `query Other { user(id: "1") { id } }`, nil)
+	assert.Equal(t, http.StatusOK, status, "expected ok")
+	assert.Len(t, payload["errors"], 1, "expected an error when no stub matches")
+
+	requests := server.Requests(gotesthttp.ByGraphQLOperation("GetUser"))
+	if assert.Len(t, requests, 2, "expected the GetUser requests") {
+		assert.Equal(t, gotesthttp.GraphQLQuery, requests[0].GraphQL.Type, "expected the operation type")
+		assert.Equal(t, query, requests[0].GraphQL.Query, "expected the query")
+		assert.Equal(t, map[string]interface{}{"id": "1"}, requests[0].GraphQL.Variables, "expected the variables")
+		assert.Equal(t, map[string]interface{}{"id": "2"}, requests[1].GraphQL.Variables, "expected the variables")
+	}
+
+	requests = server.Requests(gotesthttp.ByGraphQLOperation("Rename"))
+	if assert.Len(t, requests, 1, "expected the Rename request") {
+		assert.Equal(t, gotesthttp.GraphQLMutation, requests[0].GraphQL.Type, "expected the operation type")
+	}
+}
+
+// TestGraphQLInvalidRequests - tests the documents that can not be parsed or have many operations
+func TestGraphQLInvalidRequests(t *testing.T) {
+
+	server := newGraphQLServer(t, "")
+	defer server.Close()
+
+	status, payload := postGraphQL(t, server, "", `query {`, nil)
+	assert.Equal(t, http.StatusBadRequest, status, "expected a bad request")
+	assert.Len(t, payload["errors"], 1, "expected the syntax error")
+
+	status, payload = postGraphQL(t, server, "", `query A { user(id: "1") { id } } query B { user(id: "2") { id } }`, nil)
+	assert.Equal(t, http.StatusBadRequest, status, "expected a bad request")
+	assert.Len(t, payload["errors"], 1, "expected the missing operation name error")
+
+	status, _ = postGraphQL(t, server, "C", `query A { user(id: "1") { id } }`, nil)
+	assert.Equal(t, http.StatusBadRequest, status, "expected a bad request for an unknown operation")
+
+	res := server.DoRequest(&gotesthttp.Request{URI: "/graphql", Method: http.MethodPost, Body: []byte("not json")})
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "expected a bad request for an invalid body")
+}
+
+// TestGraphQLSchemaValidation - tests validating the operations and variables against the schema
+func TestGraphQLSchemaValidation(t *testing.T) {
+
+	server := newGraphQLServer(t, graphQLTestSchema)
+	defer server.Close()
+
+	query := `query GetUser($id: ID!) { user(id: $id) { id name } }`
+
+	status, payload := postGraphQL(t, server, "", query, map[string]interface{}{"id": "1"})
+	assert.Equal(t, http.StatusOK, status, "expected a valid query")
+	assert.NotNil(t, payload["data"], "expected the data")
+
+	status, payload = postGraphQL(t, server, "", `query GetUser { user(id: "1") { email } }`, nil)
+	assert.Equal(t, http.StatusBadRequest, status, "expected an invalid query")
+	if assert.Len(t, payload["errors"], 1, "expected the validation error") {
+		assert.Contains(t, payload["errors"].([]interface{})[0].(map[string]interface{})["message"], "email", "expected the unknown field")
+	}
+
+	status, _ = postGraphQL(t, server, "", query, nil)
+	assert.Equal(t, http.StatusBadRequest, status, "expected the missing variable error")
+
+	assert.Len(t, server.Requests(gotesthttp.ByGraphQLOperation("GetUser")), 3, "expected the invalid operations in the journal")
+}
+
+// TestGraphQLInvalidSchema - tests creating an endpoint with an invalid schema
+func TestGraphQLInvalidSchema(t *testing.T) {
+
+	assert.Panics(t, func() {
+		gotesthttp.GraphQLEndpoint("/graphql", &gotesthttp.GraphQLConfiguration{Schema: "type {"})
+	}, "expected a panic")
+}
@@ -0,0 +1,305 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+/**
+* Mocks a JSON-RPC 2.0 endpoint matching stubs by method and params.
+* @author rnojiri
+**/
+
+// The JSON-RPC 2.0 error codes.
+const (
+	JSONRPCParseError     int = -32700
+	JSONRPCInvalidRequest int = -32600
+	JSONRPCMethodNotFound int = -32601
+	JSONRPCInvalidParams  int = -32602
+	JSONRPCInternalError  int = -32603
+)
+
+const jsonRPCVersion string = "2.0"
+
+// JSONRPCConfiguration - the JSON-RPC endpoint configuration
+type JSONRPCConfiguration struct {
+	// Stubs - the stubs, the first one matching the call answers
+	Stubs []JSONRPCStub
+}
+
+// JSONRPCStub - the answer of the matching calls
+type JSONRPCStub struct {
+	// Method - the method name
+	Method string
+	// Params - the expected params (nil matches any), the values can be JSONMatchers
+	Params interface{}
+	// Result - the result of the call
+	Result interface{}
+	// Error - the error returned instead of the result
+	Error *JSONRPCError
+}
+
+// JSONRPCError - the JSON-RPC error object
+type JSONRPCError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// JSONRPCCall - a call received by a JSON-RPC endpoint
+type JSONRPCCall struct {
+	// ID - the raw request id (empty for notifications)
+	ID json.RawMessage
+	// Method - the method name
+	Method string
+	// Params - the decoded params
+	Params interface{}
+	// Notification - the call has no id and no response was sent
+	Notification bool
+	// Error - the error answered, nil on success
+	Error *JSONRPCError
+}
+
+// jsonRPCRequest - the JSON-RPC request object
+type jsonRPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  *string         `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// jsonRPCHandler - answers the calls using the stubs
+type jsonRPCHandler struct {
+	stubs []JSONRPCStub
+}
+
+// JSONRPCEndpoint - creates an endpoint answering the JSON-RPC 2.0 calls and batches,
+// each call is recorded as its own request in the journal
+func JSONRPCEndpoint(uri string, configuration *JSONRPCConfiguration) Endpoint {
+
+	if configuration == nil {
+		panic(fmt.Errorf("null configuration"))
+	}
+
+	jh := &jsonRPCHandler{
+		stubs: append([]JSONRPCStub{}, configuration.Stubs...),
+	}
+
+	for i := range jh.stubs {
+		if jh.stubs[i].Params == nil {
+			continue
+		}
+
+		params, err := normalizeExpectedJSON(jh.stubs[i].Params)
+		if err != nil {
+			panic(fmt.Errorf("invalid params in the stub %d: %w", i, err))
+		}
+
+		jh.stubs[i].Params = params
+	}
+
+	return Endpoint{
+		URI: uri,
+		Methods: map[string]Response{
+			http.MethodPost: {Func: jh.answer},
+		},
+	}
+}
+
+// ByJSONRPCMethod - selects the JSON-RPC calls by the method name
+func ByJSONRPCMethod(method string) RequestFilter {
+
+	return func(request *Request) bool {
+		return request.JSONRPC != nil && request.JSONRPC.Method == method
+	}
+}
+
+// answer - answers a single call or a batch
+func (jh *jsonRPCHandler) answer(request *Request) Response {
+
+	body := bytes.TrimSpace(request.Body)
+
+	if len(body) > 0 && body[0] == '[' {
+
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return jsonRPCResponseOf(jsonRPCErrorObject(nil, JSONRPCParseError, "parse error"))
+		}
+
+		if len(batch) == 0 {
+			return jsonRPCResponseOf(jsonRPCErrorObject(nil, JSONRPCInvalidRequest, "invalid request"))
+		}
+
+		results := []interface{}{}
+		journal := make([]Request, len(batch))
+
+		for i, raw := range batch {
+
+			journal[i] = *copyRequest(request)
+			journal[i].Body = append([]byte(nil), raw...)
+
+			if result := jh.call(&journal[i], raw); result != nil {
+				results = append(results, result)
+			}
+		}
+
+		var body interface{}
+		if len(results) > 0 {
+			body = results
+		}
+
+		response := jsonRPCResponseOf(body)
+		response.journal = journal
+
+		return response
+	}
+
+	if result := jh.call(request, body); result != nil {
+		return jsonRPCResponseOf(result)
+	}
+
+	return jsonRPCResponseOf(nil)
+}
+
+// call - answers a call annotating the request, returns nil for notifications
+func (jh *jsonRPCHandler) call(request *Request, raw json.RawMessage) interface{} {
+
+	call := jsonRPCRequest{}
+	if err := json.Unmarshal(raw, &call); err != nil {
+
+		if _, ok := err.(*json.UnmarshalTypeError); !ok {
+			return jsonRPCErrorObject(nil, JSONRPCParseError, "parse error")
+		}
+
+		return jsonRPCErrorObject(nil, JSONRPCInvalidRequest, "invalid request")
+	}
+
+	if call.JSONRPC != jsonRPCVersion || call.Method == nil || !validJSONRPCID(call.ID) {
+		return jsonRPCErrorObject(nil, JSONRPCInvalidRequest, "invalid request")
+	}
+
+	request.JSONRPC = &JSONRPCCall{
+		ID:           call.ID,
+		Method:       *call.Method,
+		Notification: call.ID == nil,
+	}
+
+	if len(call.Params) > 0 {
+
+		if first := bytes.TrimSpace(call.Params)[0]; first != '{' && first != '[' {
+			request.JSONRPC.Error = &JSONRPCError{Code: JSONRPCInvalidRequest, Message: "the params must be an object or an array"}
+			return jsonRPCErrorObject(call.ID, request.JSONRPC.Error.Code, request.JSONRPC.Error.Message)
+		}
+
+		if err := json.Unmarshal(call.Params, &request.JSONRPC.Params); err != nil {
+			request.JSONRPC.Error = &JSONRPCError{Code: JSONRPCParseError, Message: "parse error"}
+			return jsonRPCErrorObject(call.ID, request.JSONRPC.Error.Code, request.JSONRPC.Error.Message)
+		}
+	}
+
+	stub, methodFound := jh.findStub(request.JSONRPC)
+
+	var object map[string]interface{}
+
+	switch {
+	case stub != nil && stub.Error != nil:
+		request.JSONRPC.Error = stub.Error
+		object = map[string]interface{}{"jsonrpc": jsonRPCVersion, "error": stub.Error}
+
+	case stub != nil:
+		object = map[string]interface{}{"jsonrpc": jsonRPCVersion, "result": stub.Result}
+
+	case methodFound:
+		request.JSONRPC.Error = &JSONRPCError{Code: JSONRPCInvalidParams, Message: "invalid params"}
+		object = map[string]interface{}{"jsonrpc": jsonRPCVersion, "error": request.JSONRPC.Error}
+
+	default:
+		request.JSONRPC.Error = &JSONRPCError{Code: JSONRPCMethodNotFound, Message: "method not found"}
+		object = map[string]interface{}{"jsonrpc": jsonRPCVersion, "error": request.JSONRPC.Error}
+	}
+
+	if request.JSONRPC.Notification {
+		return nil
+	}
+
+	object["id"] = call.ID
+
+	return object
+}
+
+// findStub - finds the first stub matching the call, also returns if the method has stubs
+func (jh *jsonRPCHandler) findStub(call *JSONRPCCall) (*JSONRPCStub, bool) {
+
+	methodFound := false
+	comparison := &jsonComparison{}
+
+	for i := range jh.stubs {
+
+		if jh.stubs[i].Method != call.Method {
+			continue
+		}
+
+		methodFound = true
+
+		if jh.stubs[i].Params == nil {
+			return &jh.stubs[i], true
+		}
+
+		if reflect.DeepEqual(comparison.reconcile("$", jh.stubs[i].Params, call.Params, call.Params != nil), call.Params) {
+			return &jh.stubs[i], true
+		}
+	}
+
+	return nil, methodFound
+}
+
+// validJSONRPCID - checks if the id is absent, a string, a number or null
+func validJSONRPCID(id json.RawMessage) bool {
+
+	if id == nil {
+		return true
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(id, &value); err != nil {
+		return false
+	}
+
+	switch value.(type) {
+	case nil, string, float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// jsonRPCErrorObject - builds an error response object
+func jsonRPCErrorObject(id json.RawMessage, code int, message string) map[string]interface{} {
+
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+
+	return map[string]interface{}{
+		"jsonrpc": jsonRPCVersion,
+		"error":   &JSONRPCError{Code: code, Message: message},
+		"id":      id,
+	}
+}
+
+// jsonRPCResponseOf - builds the json response, a nil body (only notifications) has no content
+func jsonRPCResponseOf(body interface{}) Response {
+
+	if body == nil {
+		return Response{Status: http.StatusNoContent}
+	}
+
+	return Response{
+		Status:  http.StatusOK,
+		Body:    body,
+		Headers: http.Header{contentTypeHeader: []string{ContentTypeJSON}},
+	}
+}
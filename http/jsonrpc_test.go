@@ -0,0 +1,165 @@
+package http_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	gotesthttp "github.com/rnojiri/gotest/http"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* The tests for the JSON-RPC endpoint.
+* @author rnojiri
+**/
+
+// newJSONRPCServer - creates a server with a JSON-RPC endpoint
+func newJSONRPCServer(t *testing.T) *gotesthttp.Server {
+
+	defaultConf.T = t
+	defaultConf.Responses = map[string][]gotesthttp.Endpoint{
+		"default": {
+			gotesthttp.JSONRPCEndpoint("/rpc", &gotesthttp.JSONRPCConfiguration{
+				Stubs: []gotesthttp.JSONRPCStub{
+					{
+						Method: "eth_getBalance",
+						Params: []interface{}{"0xabc", gotesthttp.AnyString()},
+						Result: "0x10",
+					},
+					{
+						Method: "eth_blockNumber",
+						Result: "0x2a",
+					},
+					{
+						Method: "eth_call",
+						Error:  &gotesthttp.JSONRPCError{Code: 3, Message: "execution reverted", Data: "0x01"},
+					},
+				},
+			}),
+		},
+	}
+
+	return gotesthttp.NewServer(&defaultConf)
+}
+
+// postJSONRPC - posts the body returning the status and the raw response body
+func postJSONRPC(t *testing.T, server *gotesthttp.Server, body string) (int, string) {
+
+	res := server.DoRequest(&gotesthttp.Request{URI: "/rpc", Method: http.MethodPost, Body: []byte(body)})
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	assert.NoError(t, err, "expected no error reading")
+
+	return res.StatusCode, string(data)
+}
+
+// TestJSONRPCCalls - tests matching the stubs by method and params echoing the id
+func TestJSONRPCCalls(t *testing.T) {
+
+	server := newJSONRPCServer(t)
+	defer server.Close()
+
+	testCases := []struct {
+		body     string
+		expected string
+	}{
+		{`{"jsonrpc":"2.0","method":"eth_getBalance","params":["0xabc","latest"],"id":1}`, `{"id":1,"jsonrpc":"2.0","result":"0x10"}`},
+		{`{"jsonrpc":"2.0","method":"eth_blockNumber","id":"a"}`, `{"id":"a","jsonrpc":"2.0","result":"0x2a"}`},
+		{`{"jsonrpc":"2.0","method":"eth_call","params":[],"id":3}`, `{"error":{"code":3,"message":"execution reverted","data":"0x01"},"id":3,"jsonrpc":"2.0"}`},
+		{`{"jsonrpc":"2.0","method":"eth_getBalance","params":["0xdef","latest"],"id":4}`, `{"error":{"code":-32602,"message":"invalid params"},"id":4,"jsonrpc":"2.0"}`},
+		{`{"jsonrpc":"2.0","method":"eth_unknown","id":5}`, `{"error":{"code":-32601,"message":"method not found"},"id":5,"jsonrpc":"2.0"}`},
+		{`{"jsonrpc":"1.0","method":"eth_blockNumber","id":6}`, `{"error":{"code":-32600,"message":"invalid request"},"id":null,"jsonrpc":"2.0"}`},
+		{`{"jsonrpc":"2.0","method":`, `{"error":{"code":-32700,"message":"parse error"},"id":null,"jsonrpc":"2.0"}`},
+		{`[]`, `{"error":{"code":-32600,"message":"invalid request"},"id":null,"jsonrpc":"2.0"}`},
+	}
+
+	for _, testCase := range testCases {
+
+		status, body := postJSONRPC(t, server, testCase.body)
+		assert.Equal(t, http.StatusOK, status, "expected ok for: %s", testCase.body)
+		assert.JSONEq(t, testCase.expected, body, "expected the response for: %s", testCase.body)
+	}
+
+	status, body := postJSONRPC(t, server, `{"jsonrpc":"2.0","method":"eth_blockNumber"}`)
+	assert.Equal(t, http.StatusNoContent, status, "expected no content for a notification")
+	assert.Empty(t, body, "expected no body for a notification")
+
+	requests := server.Requests(gotesthttp.ByJSONRPCMethod("eth_getBalance"))
+	if assert.Len(t, requests, 2, "expected the calls in the journal") {
+		assert.Equal(t, json.RawMessage("1"), requests[0].JSONRPC.ID, "expected the id")
+		assert.Equal(t, []interface{}{"0xabc", "latest"}, requests[0].JSONRPC.Params, "expected the params")
+		assert.Nil(t, requests[0].JSONRPC.Error, "expected no error")
+		assert.Equal(t, gotesthttp.JSONRPCInvalidParams, requests[1].JSONRPC.Error.Code, "expected the answered error")
+	}
+
+	requests = server.Requests(gotesthttp.ByJSONRPCMethod("eth_blockNumber"))
+	if assert.Len(t, requests, 2, "expected the calls in the journal") {
+		assert.True(t, requests[1].JSONRPC.Notification, "expected the notification")
+	}
+}
+
+// TestJSONRPCBatch - tests the batch calls recorded one by one
+func TestJSONRPCBatch(t *testing.T) {
+
+	server := newJSONRPCServer(t)
+	defer server.Close()
+
+	status, body := postJSONRPC(t, server, `[
+		{"jsonrpc":"2.0","method":"eth_blockNumber","id":1},
+		{"jsonrpc":"2.0","method":"eth_getBalance","params":["0xabc","latest"],"id":2},
+		{"jsonrpc":"2.0","method":"eth_blockNumber"},
+		1
+	]`)
+
+	assert.Equal(t, http.StatusOK, status, "expected ok")
+	assert.JSONEq(t, `[
+		{"id":1,"jsonrpc":"2.0","result":"0x2a"},
+		{"id":2,"jsonrpc":"2.0","result":"0x10"},
+		{"error":{"code":-32600,"message":"invalid request"},"id":null,"jsonrpc":"2.0"}
+	]`, body, "expected the batch response without the notification")
+
+	requests := server.Requests()
+	if assert.Len(t, requests, 4, "expected each call in the journal") {
+		assert.Equal(t, "eth_blockNumber", requests[0].JSONRPC.Method, "expected the first call")
+		assert.Equal(t, "eth_getBalance", requests[1].JSONRPC.Method, "expected the second call")
+		assert.JSONEq(t, `{"jsonrpc":"2.0","method":"eth_getBalance","params":["0xabc","latest"],"id":2}`, string(requests[1].Body), "expected the call as body")
+		assert.True(t, requests[2].JSONRPC.Notification, "expected the notification")
+		assert.Nil(t, requests[3].JSONRPC, "expected the invalid call without the parsed call")
+	}
+
+	status, _ = postJSONRPC(t, server, `[{"jsonrpc":"2.0","method":"eth_blockNumber"}]`)
+	assert.Equal(t, http.StatusNoContent, status, "expected no content for a batch of notifications")
+}
+
+// TestJSONRPCBatchChaos - tests the injected fault recorded in each call of the batch
+func TestJSONRPCBatchChaos(t *testing.T) {
+
+	endpoint := gotesthttp.JSONRPCEndpoint("/rpc", &gotesthttp.JSONRPCConfiguration{
+		Stubs: []gotesthttp.JSONRPCStub{{Method: "eth_blockNumber", Result: "0x2a"}},
+	})
+	endpoint.Chaos = &gotesthttp.Chaos{Seed: 1, Probability: 1, Faults: []gotesthttp.ChaosFault{gotesthttp.ChaosLatency}, MaxLatency: time.Millisecond}
+
+	server, err := gotesthttp.NewServerWithOptions(t, gotesthttp.WithEndpoints("default", endpoint))
+	if err != nil {
+		t.Fatalf("error creating the server: %v", err)
+	}
+
+	status, _ := postJSONRPC(t, server, `[
+		{"jsonrpc":"2.0","method":"eth_blockNumber","id":1},
+		{"jsonrpc":"2.0","method":"eth_blockNumber","id":2}
+	]`)
+	assert.Equal(t, http.StatusOK, status, "expected ok")
+
+	requests := server.Requests()
+	if assert.Len(t, requests, 2, "expected each call in the journal") {
+		for i, request := range requests {
+			if assert.NotNil(t, request.Chaos, "expected the fault in the call %d", i) {
+				assert.Equal(t, gotesthttp.ChaosLatency, request.Chaos.Fault, "expected the latency in the call %d", i)
+			}
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package http
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+/**
+* Content negotiation, renders the same body as json, xml, yaml or csv using the Accept header.
+* @author rnojiri
+**/
+
+// The formats available to the content negotiation.
+const (
+	FormatJSON string = "json"
+	FormatXML  string = "xml"
+	FormatYAML string = "yaml"
+	FormatCSV  string = "csv"
+)
+
+const (
+	acceptHeader     string = "Accept"
+	varyHeader       string = "Vary"
+	xmlRootElement   string = "response"
+	xmlItemElement   string = "item"
+	contentTypeYAML  string = "application/yaml"
+	contentTypeCSV   string = "text/csv; charset=utf-8"
+	defaultAcceptAll string = "*/*"
+)
+
+// formatMediaTypes - the media types of each format, the first one is the default
+var formatMediaTypes = map[string][]string{
+	FormatJSON: {ContentTypeJSON},
+	FormatXML:  {ContentTypeXML, "text/xml"},
+	FormatYAML: {contentTypeYAML, "application/x-yaml", "text/yaml"},
+	FormatCSV:  {"text/csv"},
+}
+
+// acceptedRange - a media range from the Accept header
+type acceptedRange struct {
+	mediaType string
+	quality   float64
+}
+
+// parseAccept - parses the Accept header sorting by quality (the header order is kept on ties)
+func parseAccept(header string) []acceptedRange {
+
+	if strings.TrimSpace(header) == "" {
+		header = defaultAcceptAll
+	}
+
+	ranges := []acceptedRange{}
+
+	for _, part := range strings.Split(header, ",") {
+
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = parsed
+			}
+		}
+
+		if quality > 0 {
+			ranges = append(ranges, acceptedRange{mediaType: mediaType, quality: quality})
+		}
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+
+	return ranges
+}
+
+// matchMediaRange - checks if the media type matches the range (like */* or application/*)
+func matchMediaRange(mediaRange, mediaType string) bool {
+
+	if mediaRange == defaultAcceptAll || mediaRange == mediaType {
+		return true
+	}
+
+	rangeType, rangeSubtype, _ := strings.Cut(mediaRange, "/")
+	mainType, _, _ := strings.Cut(mediaType, "/")
+
+	return rangeSubtype == "*" && rangeType == mainType
+}
+
+// chooseFormat - chooses the format and the media type for the Accept header
+func chooseFormat(accept string, formats []string) (string, string, bool) {
+
+	for _, accepted := range parseAccept(accept) {
+		for _, format := range formats {
+			for _, mediaType := range formatMediaTypes[format] {
+				if matchMediaRange(accepted.mediaType, mediaType) {
+					return format, mediaType, true
+				}
+			}
+		}
+	}
+
+	return "", "", false
+}
+
+// negotiate - renders the response body in the format accepted by the client,
+// returns the chosen format (empty when none is acceptable and the response is a 406)
+// or an error and the first unknown format
+func negotiate(accept string, response Response) (Response, string, error) {
+
+	for _, format := range response.Formats {
+		if _, ok := formatMediaTypes[format]; !ok {
+			return response, format, fmt.Errorf("unknown format: %s", format)
+		}
+	}
+
+	response.Headers = response.Headers.Clone()
+	if response.Headers == nil {
+		response.Headers = http.Header{}
+	}
+
+	response.Headers.Add(varyHeader, acceptHeader)
+
+	format, mediaType, ok := chooseFormat(accept, response.Formats)
+	if !ok {
+
+		available := []string{}
+		for _, f := range response.Formats {
+			available = append(available, formatMediaTypes[f][0])
+		}
+
+		response.Status = http.StatusNotAcceptable
+		response.Body = "not acceptable, available: " + strings.Join(available, ", ")
+		response.Headers.Del(contentTypeHeader)
+
+		return response, "", nil
+	}
+
+	body, err := renderFormat(format, response.Body)
+	if err != nil {
+		return response, format, err
+	}
+
+	if format == FormatCSV {
+		mediaType = contentTypeCSV
+	}
+
+	response.Body = body
+	response.Headers.Set(contentTypeHeader, mediaType)
+
+	return response, format, nil
+}
+
+// renderFormat - encodes the body in the format
+func renderFormat(format string, body interface{}) ([]byte, error) {
+
+	switch format {
+
+	case FormatJSON:
+		return json.Marshal(body)
+
+	case FormatXML:
+		return renderXML(body)
+
+	case FormatYAML:
+		document, err := roundTripJSON(body)
+		if err != nil {
+			return nil, err
+		}
+		return yaml.Marshal(document)
+
+	case FormatCSV:
+		return renderCSV(body)
+
+	default:
+		return nil, fmt.Errorf("unknown format: %s", format)
+	}
+}
+
+// renderXML - encodes structs using encoding/xml and maps, slices and scalars using generic elements
+func renderXML(body interface{}) ([]byte, error) {
+
+	if reflect.Indirect(reflect.ValueOf(body)).Kind() == reflect.Struct {
+		if data, err := xml.Marshal(body); err == nil {
+			return append([]byte(xml.Header), data...), nil
+		}
+	}
+
+	document, err := roundTripJSON(body)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := bytes.NewBufferString(xml.Header)
+
+	encoder := xml.NewEncoder(buffer)
+	if err := encodeXMLElement(encoder, xmlRootElement, document); err != nil {
+		return nil, err
+	}
+
+	if err := encoder.Flush(); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// encodeXMLElement - encodes a decoded json value as a xml element
+func encodeXMLElement(encoder *xml.Encoder, name string, value interface{}) error {
+
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+
+	if err := encoder.EncodeToken(start); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			if err := encodeXMLElement(encoder, k, v[k]); err != nil {
+				return err
+			}
+		}
+
+	case []interface{}:
+		for _, item := range v {
+			if err := encodeXMLElement(encoder, xmlItemElement, item); err != nil {
+				return err
+			}
+		}
+
+	case nil:
+
+	default:
+		if err := encoder.EncodeToken(xml.CharData(formatScalar(v))); err != nil {
+			return err
+		}
+	}
+
+	return encoder.EncodeToken(start.End())
+}
+
+// renderCSV - encodes a [][]string as is or a slice of objects using the sorted keys as header
+func renderCSV(body interface{}) ([]byte, error) {
+
+	records, ok := body.([][]string)
+	if !ok {
+
+		document, err := roundTripJSON(body)
+		if err != nil {
+			return nil, err
+		}
+
+		items, ok := document.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("csv bodies must be a [][]string or a slice of objects")
+		}
+
+		records, err = csvRecords(items)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	buffer := bytes.Buffer{}
+
+	writer := csv.NewWriter(&buffer)
+	if err := writer.WriteAll(records); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// csvRecords - converts the objects to records, the header contains all keys sorted
+func csvRecords(items []interface{}) ([][]string, error) {
+
+	keySet := map[string]bool{}
+	objects := make([]map[string]interface{}, len(items))
+
+	for i, item := range items {
+
+		object, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("csv bodies must be a slice of objects, found: %T", item)
+		}
+
+		for k := range object {
+			keySet[k] = true
+		}
+
+		objects[i] = object
+	}
+
+	header := make([]string, 0, len(keySet))
+	for k := range keySet {
+		header = append(header, k)
+	}
+
+	sort.Strings(header)
+
+	records := [][]string{header}
+
+	for _, object := range objects {
+
+		record := make([]string, len(header))
+		for i, k := range header {
+			record[i] = formatScalar(object[k])
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// formatScalar - formats a decoded json value as text (objects and arrays are formatted as json)
+func formatScalar(value interface{}) string {
+
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+}
@@ -0,0 +1,124 @@
+package http_test
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	gotesthttp "github.com/rnojiri/gotest/http"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* The tests for the content negotiation.
+* @author rnojiri
+**/
+
+// negotiatedItem - the logical body rendered in all formats
+type negotiatedItem struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// TestContentNegotiation - tests rendering the same body in each accepted format
+func TestContentNegotiation(t *testing.T) {
+
+	defaultConf.T = t
+	defaultConf.Responses = map[string][]gotesthttp.Endpoint{
+		"default": {
+			{
+				URI: "/items",
+				Methods: map[string]gotesthttp.Response{
+					http.MethodGet: {
+						Status:  http.StatusOK,
+						Body:    []negotiatedItem{{ID: 1, Name: "a"}, {ID: 2, Name: "b,c"}},
+						Formats: []string{gotesthttp.FormatJSON, gotesthttp.FormatXML, gotesthttp.FormatYAML, gotesthttp.FormatCSV},
+					},
+				},
+			},
+		},
+	}
+
+	server := gotesthttp.NewServer(&defaultConf)
+	defer server.Close()
+
+	testCases := []struct {
+		accept         string
+		status         int
+		contentType    string
+		body           string
+		representation string
+	}{
+		{"", http.StatusOK, "application/json", `[{"id":1,"name":"a"},{"id":2,"name":"b,c"}]`, gotesthttp.FormatJSON},
+		{"text/xml", http.StatusOK, "text/xml", `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<response><item><id>1</id><name>a</name></item><item><id>2</id><name>b,c</name></item></response>`, gotesthttp.FormatXML},
+		{"application/json;q=0.5, application/yaml", http.StatusOK, "application/yaml", "- id: 1\n  name: a\n- id: 2\n  name: b,c\n", gotesthttp.FormatYAML},
+		{"text/*", http.StatusOK, "text/xml", "", gotesthttp.FormatXML},
+		{"text/csv", http.StatusOK, "text/csv; charset=utf-8", "id,name\n1,a\n2,\"b,c\"\n", gotesthttp.FormatCSV},
+		{"image/png", http.StatusNotAcceptable, "text/plain; charset=utf-8", "", ""},
+	}
+
+	for _, testCase := range testCases {
+
+		headers := http.Header{}
+		if testCase.accept != "" {
+			headers.Set("Accept", testCase.accept)
+		}
+
+		res := server.DoRequest(&gotesthttp.Request{URI: "/items", Method: http.MethodGet, Headers: headers, Tag: testCase.accept})
+
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+
+		if !assert.NoError(t, err, "expected no error reading") {
+			return
+		}
+
+		assert.Equal(t, testCase.status, res.StatusCode, "expected the status for: %s", testCase.accept)
+		assert.Equal(t, testCase.contentType, res.Header.Get("Content-Type"), "expected the content type for: %s", testCase.accept)
+		assert.Equal(t, "Accept", res.Header.Get("Vary"), "expected the vary header")
+
+		if testCase.body != "" {
+			assert.Equal(t, testCase.body, string(body), "expected the body for: %s", testCase.accept)
+		}
+
+		requests := server.Requests(gotesthttp.ByTag(testCase.accept))
+		if assert.Len(t, requests, 1, "expected the request in the journal") {
+			assert.Equal(t, testCase.representation, requests[0].Representation, "expected the chosen representation")
+		}
+	}
+}
+
+// TestContentNegotiationUnknownFormat - tests the unknown formats answered with 500
+func TestContentNegotiationUnknownFormat(t *testing.T) {
+
+	conf := defaultConf
+	conf.T = nil
+	conf.Responses = map[string][]gotesthttp.Endpoint{"default": {}}
+
+	server := gotesthttp.NewServer(&conf)
+	defer server.Close()
+
+	server.AddEndpoint("default", gotesthttp.Endpoint{
+		URI: "/items",
+		Methods: map[string]gotesthttp.Response{
+			http.MethodGet: {Status: http.StatusOK, Body: []string{"a"}, Formats: []string{gotesthttp.FormatJSON, "toml"}},
+		},
+	})
+
+	for _, accept := range []string{"application/json", "image/png"} {
+
+		res := server.DoRequest(&gotesthttp.Request{URI: "/items", Method: http.MethodGet, Headers: http.Header{"Accept": {accept}}})
+		res.Body.Close()
+
+		assert.Equal(t, http.StatusInternalServerError, res.StatusCode, "expected the unknown format error for: %s", accept)
+	}
+
+	errs := server.GetErrors()
+	if assert.Len(t, errs, 2, "expected the errors") {
+		assert.Equal(t, "error rendering the toml representation: unknown format: toml", errs[0].Error(), "expected the unknown format")
+	}
+
+	for _, request := range server.Requests() {
+		assert.Equal(t, "toml", request.Representation, "expected the failed representation in the journal")
+	}
+}
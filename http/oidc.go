@@ -0,0 +1,700 @@
+package http
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/**
+* Mocks an OAuth2/OpenID Connect identity provider issuing signed JWTs.
+* @author rnojiri
+**/
+
+const (
+	identityProviderMode    string = "oidc"
+	defaultTokenTTL                = time.Hour
+	identityProviderKeyBits int    = 2048
+	identityProviderAlg     string = "RS256"
+)
+
+// The uris served by the identity provider.
+const (
+	DiscoveryURI     string = "/.well-known/openid-configuration"
+	JWKSURI          string = "/jwks"
+	AuthorizeURI     string = "/authorize"
+	TokenURI         string = "/token"
+	IntrospectionURI string = "/introspect"
+)
+
+// The supported grant types.
+const (
+	GrantClientCredentials string = "client_credentials"
+	GrantPassword          string = "password"
+	GrantRefreshToken      string = "refresh_token"
+	GrantAuthorizationCode string = "authorization_code"
+)
+
+// IdentityProviderConfiguration - the identity provider configuration
+type IdentityProviderConfiguration struct {
+	// Host - the host to listen
+	Host string
+	// Port - the port to listen (0 chooses a free port)
+	Port int
+	// Issuer - the token issuer (the server url when empty)
+	Issuer string
+	// Clients - the client secrets by client id (an empty secret allows public clients)
+	Clients map[string]string
+	// Users - the passwords by user name (used by the password grant)
+	Users map[string]string
+	// Claims - extra claims added to all issued tokens
+	Claims map[string]interface{}
+	// TokenTTL - the token duration (one hour when zero)
+	TokenTTL time.Duration
+	// T - the test, benchmark or fuzz test
+	T testing.TB
+}
+
+// TokenRequest - a request received by the token endpoint
+type TokenRequest struct {
+	GrantType    string
+	ClientID     string
+	Username     string
+	Scope        string
+	Code         string
+	CodeVerifier string
+	RefreshToken string
+	// Error - the oauth2 error returned, empty on success
+	Error string
+	// Form - the full received form
+	Form url.Values
+}
+
+// signingKey - a key used to sign the tokens
+type signingKey struct {
+	id         string
+	privateKey *rsa.PrivateKey
+}
+
+// authorizationCode - a code issued by the authorize endpoint
+type authorizationCode struct {
+	clientID            string
+	redirectURI         string
+	subject             string
+	scope               string
+	nonce               string
+	codeChallenge       string
+	codeChallengeMethod string
+}
+
+// refreshGrant - the data kept for a refresh token
+type refreshGrant struct {
+	clientID string
+	subject  string
+	scope    string
+}
+
+// IdentityProvider - the mocked identity provider
+type IdentityProvider struct {
+	*Server
+	configuration *IdentityProviderConfiguration
+	issuer        string
+	keys          []*signingKey
+	claims        map[string]interface{}
+	codes         map[string]authorizationCode
+	refreshTokens map[string]refreshGrant
+	tokenRequests []TokenRequest
+	providerMutex sync.Mutex
+}
+
+// oauthError - the oauth2 error response
+type oauthError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// NewIdentityProvider - creates a new identity provider listening for requests
+func NewIdentityProvider(configuration *IdentityProviderConfiguration) *IdentityProvider {
+
+	if configuration == nil {
+		panic(fmt.Errorf("null configuration"))
+	}
+
+	ip := &IdentityProvider{
+		configuration: configuration,
+		claims:        copyClaims(configuration.Claims),
+		codes:         map[string]authorizationCode{},
+		refreshTokens: map[string]refreshGrant{},
+	}
+
+	ip.RotateKeys()
+
+	ip.Server = NewServer(&Configuration{
+		Host: configuration.Host,
+		Port: configuration.Port,
+		T:    configuration.T,
+		Responses: map[string][]Endpoint{
+			identityProviderMode: {
+				{
+					URI:     DiscoveryURI,
+					Methods: map[string]Response{http.MethodGet: {Func: ip.discovery}},
+				},
+				{
+					URI:     JWKSURI,
+					Methods: map[string]Response{http.MethodGet: {Func: ip.jwks}},
+				},
+				{
+					URI:     AuthorizeURI + `(\?.*)?$`,
+					Regexp:  true,
+					Methods: map[string]Response{http.MethodGet: {Func: ip.authorize}},
+				},
+				{
+					URI:     TokenURI,
+					Methods: map[string]Response{http.MethodPost: {Func: ip.token}},
+				},
+				{
+					URI:     IntrospectionURI,
+					Methods: map[string]Response{http.MethodPost: {Func: ip.introspect}},
+				},
+			},
+		},
+	})
+
+	ip.issuer = configuration.Issuer
+	if ip.issuer == "" {
+		ip.issuer = ip.Server.server.URL
+	}
+
+	return ip
+}
+
+// Issuer - returns the issuer (also the base url of the provider)
+func (ip *IdentityProvider) Issuer() string {
+
+	return ip.issuer
+}
+
+// SetClaims - replaces the extra claims added to all issued tokens
+func (ip *IdentityProvider) SetClaims(claims map[string]interface{}) {
+
+	ip.providerMutex.Lock()
+	defer ip.providerMutex.Unlock()
+
+	ip.claims = copyClaims(claims)
+}
+
+// RotateKeys - creates a new signing key, the previous keys are still published in the JWKS
+func (ip *IdentityProvider) RotateKeys() {
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, identityProviderKeyBits)
+	if err != nil {
+		panic(err)
+	}
+
+	ip.providerMutex.Lock()
+	defer ip.providerMutex.Unlock()
+
+	ip.keys = append([]*signingKey{{id: randomToken(8), privateKey: privateKey}}, ip.keys...)
+}
+
+// RetirePreviousKeys - removes all keys but the current one from the JWKS
+func (ip *IdentityProvider) RetirePreviousKeys() {
+
+	ip.providerMutex.Lock()
+	defer ip.providerMutex.Unlock()
+
+	ip.keys = ip.keys[:1]
+}
+
+// KeyID - returns the id of the current signing key
+func (ip *IdentityProvider) KeyID() string {
+
+	ip.providerMutex.Lock()
+	defer ip.providerMutex.Unlock()
+
+	return ip.keys[0].id
+}
+
+// TokenRequests - returns a copy of the requests received by the token endpoint
+func (ip *IdentityProvider) TokenRequests() []TokenRequest {
+
+	ip.providerMutex.Lock()
+	defer ip.providerMutex.Unlock()
+
+	return append([]TokenRequest{}, ip.tokenRequests...)
+}
+
+// IssueToken - signs a token containing exactly the given claims
+func (ip *IdentityProvider) IssueToken(claims map[string]interface{}) string {
+
+	ip.providerMutex.Lock()
+	key := ip.keys[0]
+	ip.providerMutex.Unlock()
+
+	header, err := json.Marshal(map[string]string{"alg": identityProviderAlg, "typ": "JWT", "kid": key.id})
+	if err != nil {
+		panic(err)
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		panic(err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	hash := sha256.Sum256([]byte(signingInput))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key.privateKey, crypto.SHA256, hash[:])
+	if err != nil {
+		panic(err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// VerifyToken - verifies the signature and expiration returning the token claims
+func (ip *IdentityProvider) VerifyToken(token string) (map[string]interface{}, error) {
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+
+	header := map[string]string{}
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature: %w", err)
+	}
+
+	key := ip.findKey(header["kid"])
+	if key == nil {
+		return nil, fmt.Errorf("unknown key id: %s", header["kid"])
+	}
+
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+	err = rsa.VerifyPKCS1v15(&key.privateKey.PublicKey, crypto.SHA256, hash[:], signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", err)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token payload: %w", err)
+	}
+
+	claims := map[string]interface{}{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("malformed token payload: %w", err)
+	}
+
+	if exp, ok := claims["exp"].(float64); ok && time.Now().Unix() > int64(exp) {
+		return nil, fmt.Errorf("token expired")
+	}
+
+	return claims, nil
+}
+
+// findKey - finds a published key by id
+func (ip *IdentityProvider) findKey(id string) *signingKey {
+
+	ip.providerMutex.Lock()
+	defer ip.providerMutex.Unlock()
+
+	for _, key := range ip.keys {
+		if key.id == id {
+			return key
+		}
+	}
+
+	return nil
+}
+
+// discovery - answers the openid discovery document
+func (ip *IdentityProvider) discovery(request *Request) Response {
+
+	return jsonResponse(http.StatusOK, map[string]interface{}{
+		"issuer":                                ip.issuer,
+		"authorization_endpoint":                ip.issuer + AuthorizeURI,
+		"token_endpoint":                        ip.issuer + TokenURI,
+		"introspection_endpoint":                ip.issuer + IntrospectionURI,
+		"jwks_uri":                              ip.issuer + JWKSURI,
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{identityProviderAlg},
+		"grant_types_supported":                 []string{GrantAuthorizationCode, GrantClientCredentials, GrantPassword, GrantRefreshToken},
+		"code_challenge_methods_supported":      []string{"S256", "plain"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+	})
+}
+
+// jwks - answers the published keys
+func (ip *IdentityProvider) jwks(request *Request) Response {
+
+	ip.providerMutex.Lock()
+	defer ip.providerMutex.Unlock()
+
+	keys := make([]map[string]string, len(ip.keys))
+	for i, key := range ip.keys {
+		keys[i] = map[string]string{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": identityProviderAlg,
+			"kid": key.id,
+			"n":   base64.RawURLEncoding.EncodeToString(key.privateKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.privateKey.E)).Bytes()),
+		}
+	}
+
+	return jsonResponse(http.StatusOK, map[string]interface{}{"keys": keys})
+}
+
+// authorize - issues a code and redirects to the client (the login_hint is used as subject)
+func (ip *IdentityProvider) authorize(request *Request) Response {
+
+	query := request.Query()
+
+	clientID := query.Get("client_id")
+	if _, ok := ip.configuration.Clients[clientID]; !ok {
+		return jsonResponse(http.StatusBadRequest, oauthError{Error: "unauthorized_client", Description: "unknown client: " + clientID})
+	}
+
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || !redirectURI.IsAbs() {
+		return jsonResponse(http.StatusBadRequest, oauthError{Error: "invalid_request", Description: "invalid redirect_uri"})
+	}
+
+	subject := query.Get("login_hint")
+	if subject == "" {
+		subject = "user"
+	}
+
+	challengeMethod := query.Get("code_challenge_method")
+	if challengeMethod == "" && query.Get("code_challenge") != "" {
+		challengeMethod = "plain"
+	}
+
+	code := randomToken(16)
+
+	ip.providerMutex.Lock()
+	ip.codes[code] = authorizationCode{
+		clientID:            clientID,
+		redirectURI:         query.Get("redirect_uri"),
+		subject:             subject,
+		scope:               query.Get("scope"),
+		nonce:               query.Get("nonce"),
+		codeChallenge:       query.Get("code_challenge"),
+		codeChallengeMethod: challengeMethod,
+	}
+	ip.providerMutex.Unlock()
+
+	redirectQuery := redirectURI.Query()
+	redirectQuery.Set("code", code)
+	if state := query.Get("state"); state != "" {
+		redirectQuery.Set("state", state)
+	}
+
+	redirectURI.RawQuery = redirectQuery.Encode()
+
+	return Response{
+		Status:  http.StatusFound,
+		Headers: http.Header{"Location": []string{redirectURI.String()}},
+	}
+}
+
+// token - the token endpoint
+func (ip *IdentityProvider) token(request *Request) Response {
+
+	form, err := url.ParseQuery(string(request.Body))
+	if err != nil {
+		return jsonResponse(http.StatusBadRequest, oauthError{Error: "invalid_request", Description: err.Error()})
+	}
+
+	tokenRequest := TokenRequest{
+		GrantType:    form.Get("grant_type"),
+		ClientID:     form.Get("client_id"),
+		Username:     form.Get("username"),
+		Scope:        form.Get("scope"),
+		Code:         form.Get("code"),
+		CodeVerifier: form.Get("code_verifier"),
+		RefreshToken: form.Get("refresh_token"),
+		Form:         form,
+	}
+
+	clientSecret := form.Get("client_secret")
+	if basicID, basicSecret, ok := basicAuth(request.Headers); ok {
+		tokenRequest.ClientID = basicID
+		clientSecret = basicSecret
+	}
+
+	response := ip.grant(&tokenRequest, clientSecret)
+
+	ip.providerMutex.Lock()
+	ip.tokenRequests = append(ip.tokenRequests, tokenRequest)
+	ip.providerMutex.Unlock()
+
+	return response
+}
+
+// grant - validates the client and issues the tokens for the grant type
+func (ip *IdentityProvider) grant(tokenRequest *TokenRequest, clientSecret string) Response {
+
+	fail := func(status int, code, description string) Response {
+		tokenRequest.Error = code
+		return jsonResponse(status, oauthError{Error: code, Description: description})
+	}
+
+	expectedSecret, ok := ip.configuration.Clients[tokenRequest.ClientID]
+	if !ok || (expectedSecret != "" && subtle.ConstantTimeCompare([]byte(expectedSecret), []byte(clientSecret)) != 1) {
+		return fail(http.StatusUnauthorized, "invalid_client", "invalid client credentials")
+	}
+
+	switch tokenRequest.GrantType {
+
+	case GrantClientCredentials:
+		return ip.issueTokens(tokenRequest.ClientID, tokenRequest.ClientID, tokenRequest.Scope, "", false)
+
+	case GrantPassword:
+		password, ok := ip.configuration.Users[tokenRequest.Username]
+		if !ok || subtle.ConstantTimeCompare([]byte(password), []byte(tokenRequest.Form.Get("password"))) != 1 {
+			return fail(http.StatusBadRequest, "invalid_grant", "invalid user credentials")
+		}
+
+		return ip.issueTokens(tokenRequest.ClientID, tokenRequest.Username, tokenRequest.Scope, "", true)
+
+	case GrantRefreshToken:
+		ip.providerMutex.Lock()
+		refresh, ok := ip.refreshTokens[tokenRequest.RefreshToken]
+		delete(ip.refreshTokens, tokenRequest.RefreshToken)
+		ip.providerMutex.Unlock()
+
+		if !ok || refresh.clientID != tokenRequest.ClientID {
+			return fail(http.StatusBadRequest, "invalid_grant", "invalid refresh token")
+		}
+
+		return ip.issueTokens(refresh.clientID, refresh.subject, refresh.scope, "", true)
+
+	case GrantAuthorizationCode:
+		ip.providerMutex.Lock()
+		code, ok := ip.codes[tokenRequest.Code]
+		delete(ip.codes, tokenRequest.Code)
+		ip.providerMutex.Unlock()
+
+		if !ok || code.clientID != tokenRequest.ClientID {
+			return fail(http.StatusBadRequest, "invalid_grant", "invalid authorization code")
+		}
+
+		if redirectURI := tokenRequest.Form.Get("redirect_uri"); redirectURI != "" && redirectURI != code.redirectURI {
+			return fail(http.StatusBadRequest, "invalid_grant", "redirect_uri mismatch")
+		}
+
+		if !verifyCodeChallenge(code.codeChallenge, code.codeChallengeMethod, tokenRequest.CodeVerifier) {
+			return fail(http.StatusBadRequest, "invalid_grant", "invalid code verifier")
+		}
+
+		return ip.issueTokens(code.clientID, code.subject, code.scope, code.nonce, true)
+
+	default:
+		return fail(http.StatusBadRequest, "unsupported_grant_type", "unsupported grant type: "+tokenRequest.GrantType)
+	}
+}
+
+// issueTokens - creates the token response
+func (ip *IdentityProvider) issueTokens(clientID, subject, scope, nonce string, refresh bool) Response {
+
+	ttl := ip.configuration.TokenTTL
+	if ttl == 0 {
+		ttl = defaultTokenTTL
+	}
+
+	now := time.Now()
+
+	ip.providerMutex.Lock()
+	claims := copyClaims(ip.claims)
+	ip.providerMutex.Unlock()
+
+	claims["iss"] = ip.issuer
+	claims["sub"] = subject
+	claims["aud"] = clientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(ttl).Unix()
+	claims["jti"] = randomToken(8)
+
+	if scope != "" {
+		claims["scope"] = scope
+	}
+
+	body := map[string]interface{}{
+		"access_token": ip.IssueToken(claims),
+		"token_type":   "Bearer",
+		"expires_in":   int64(ttl.Seconds()),
+	}
+
+	if scope != "" {
+		body["scope"] = scope
+	}
+
+	if hasScope(scope, "openid") {
+
+		idClaims := copyClaims(claims)
+		delete(idClaims, "scope")
+		if nonce != "" {
+			idClaims["nonce"] = nonce
+		}
+
+		body["id_token"] = ip.IssueToken(idClaims)
+	}
+
+	if refresh {
+
+		refreshToken := randomToken(16)
+
+		ip.providerMutex.Lock()
+		ip.refreshTokens[refreshToken] = refreshGrant{clientID: clientID, subject: subject, scope: scope}
+		ip.providerMutex.Unlock()
+
+		body["refresh_token"] = refreshToken
+	}
+
+	return jsonResponse(http.StatusOK, body)
+}
+
+// introspect - the token introspection endpoint (RFC 7662)
+func (ip *IdentityProvider) introspect(request *Request) Response {
+
+	form, err := url.ParseQuery(string(request.Body))
+	if err != nil {
+		return jsonResponse(http.StatusBadRequest, oauthError{Error: "invalid_request", Description: err.Error()})
+	}
+
+	token := form.Get("token")
+
+	ip.providerMutex.Lock()
+	refresh, isRefresh := ip.refreshTokens[token]
+	ip.providerMutex.Unlock()
+
+	if isRefresh {
+		return jsonResponse(http.StatusOK, map[string]interface{}{
+			"active":     true,
+			"token_type": GrantRefreshToken,
+			"client_id":  refresh.clientID,
+			"sub":        refresh.subject,
+			"scope":      refresh.scope,
+		})
+	}
+
+	claims, err := ip.VerifyToken(token)
+	if err != nil {
+		return jsonResponse(http.StatusOK, map[string]interface{}{"active": false})
+	}
+
+	claims["active"] = true
+	claims["token_type"] = "Bearer"
+	claims["client_id"] = claims["aud"]
+
+	return jsonResponse(http.StatusOK, claims)
+}
+
+// verifyCodeChallenge - verifies the PKCE code verifier
+func verifyCodeChallenge(challenge, method, verifier string) bool {
+
+	if challenge == "" {
+		return true
+	}
+
+	switch method {
+	case "S256":
+		hash := sha256.Sum256([]byte(verifier))
+		return base64.RawURLEncoding.EncodeToString(hash[:]) == challenge
+	case "plain":
+		return verifier == challenge
+	default:
+		return false
+	}
+}
+
+// CodeChallengeS256 - generates the S256 PKCE code challenge of the verifier
+func CodeChallengeS256(verifier string) string {
+
+	hash := sha256.Sum256([]byte(verifier))
+
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// basicAuth - reads the client credentials from the authorization header
+func basicAuth(headers http.Header) (string, string, bool) {
+
+	req := http.Request{Header: headers}
+
+	return req.BasicAuth()
+}
+
+// hasScope - checks if the space separated scopes contain the scope
+func hasScope(scopes, scope string) bool {
+
+	for _, s := range strings.Fields(scopes) {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// jsonResponse - creates a json response
+func jsonResponse(status int, body interface{}) Response {
+
+	return Response{
+		Status: status,
+		Body:   body,
+		Headers: http.Header{
+			"Content-Type":  []string{"application/json"},
+			"Cache-Control": []string{"no-store"},
+		},
+	}
+}
+
+// copyClaims - copies the claims map
+func copyClaims(claims map[string]interface{}) map[string]interface{} {
+
+	result := make(map[string]interface{}, len(claims)+8)
+	for k, v := range claims {
+		result[k] = v
+	}
+
+	return result
+}
+
+// randomToken - generates a random hex token
+func randomToken(size int) string {
+
+	buffer := make([]byte, size)
+	if _, err := rand.Read(buffer); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(buffer)
+}
@@ -0,0 +1,269 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	gotesthttp "github.com/rnojiri/gotest/http"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* The tests for the identity provider mock.
+* @author rnojiri
+**/
+
+// newIdentityProvider - creates the provider used by the tests
+func newIdentityProvider(t *testing.T) *gotesthttp.IdentityProvider {
+
+	return gotesthttp.NewIdentityProvider(&gotesthttp.IdentityProviderConfiguration{
+		Host:    "localhost",
+		Clients: map[string]string{"service": "secret", "spa": ""},
+		Users:   map[string]string{"alice": "wonderland"},
+		Claims:  map[string]interface{}{"tenant": "acme"},
+		T:       t,
+	})
+}
+
+// postForm - posts a form returning the status and the decoded json body
+func postForm(t *testing.T, ip *gotesthttp.IdentityProvider, uri string, form url.Values) (int, map[string]interface{}) {
+
+	res, err := http.PostForm(ip.Issuer()+uri, form)
+	if !assert.NoError(t, err, "expected no error posting the form") {
+		return 0, nil
+	}
+
+	defer res.Body.Close()
+
+	body := map[string]interface{}{}
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&body), "expected a json body")
+
+	return res.StatusCode, body
+}
+
+// TestIdentityProviderClientCredentials - tests the client credentials grant and the introspection
+func TestIdentityProviderClientCredentials(t *testing.T) {
+
+	ip := newIdentityProvider(t)
+	defer ip.Close()
+
+	status, body := postForm(t, ip, gotesthttp.TokenURI, url.Values{
+		"grant_type":    {gotesthttp.GrantClientCredentials},
+		"client_id":     {"service"},
+		"client_secret": {"secret"},
+		"scope":         {"read"},
+	})
+
+	if !assert.Equal(t, http.StatusOK, status, "expected a token") {
+		return
+	}
+
+	claims, err := ip.VerifyToken(body["access_token"].(string))
+	if !assert.NoError(t, err, "expected a valid token") {
+		return
+	}
+
+	assert.Equal(t, "acme", claims["tenant"], "expected the configured claim")
+	assert.Equal(t, "service", claims["sub"], "expected the client as subject")
+	assert.Equal(t, ip.Issuer(), claims["iss"], "expected the issuer")
+
+	_, introspection := postForm(t, ip, gotesthttp.IntrospectionURI, url.Values{"token": {body["access_token"].(string)}})
+	assert.Equal(t, true, introspection["active"], "expected an active token")
+
+	_, introspection = postForm(t, ip, gotesthttp.IntrospectionURI, url.Values{"token": {"invalid"}})
+	assert.Equal(t, false, introspection["active"], "expected an inactive token")
+
+	status, body = postForm(t, ip, gotesthttp.TokenURI, url.Values{
+		"grant_type":    {gotesthttp.GrantClientCredentials},
+		"client_id":     {"service"},
+		"client_secret": {"wrong"},
+	})
+
+	assert.Equal(t, http.StatusUnauthorized, status, "expected an invalid client")
+	assert.Equal(t, "invalid_client", body["error"], "expected the oauth2 error")
+
+	requests := ip.TokenRequests()
+	if assert.Len(t, requests, 2, "expected the recorded token requests") {
+		assert.Equal(t, "read", requests[0].Scope, "expected the recorded scope")
+		assert.Equal(t, "invalid_client", requests[1].Error, "expected the recorded error")
+	}
+}
+
+// TestIdentityProviderPasswordAndRefresh - tests the password and refresh token grants
+func TestIdentityProviderPasswordAndRefresh(t *testing.T) {
+
+	ip := newIdentityProvider(t)
+	defer ip.Close()
+
+	ip.SetClaims(map[string]interface{}{"role": "admin"})
+
+	status, body := postForm(t, ip, gotesthttp.TokenURI, url.Values{
+		"grant_type":    {gotesthttp.GrantPassword},
+		"client_id":     {"service"},
+		"client_secret": {"secret"},
+		"username":      {"alice"},
+		"password":      {"wonderland"},
+	})
+
+	if !assert.Equal(t, http.StatusOK, status, "expected a token") {
+		return
+	}
+
+	refreshToken := body["refresh_token"].(string)
+
+	status, body = postForm(t, ip, gotesthttp.TokenURI, url.Values{
+		"grant_type":    {gotesthttp.GrantRefreshToken},
+		"client_id":     {"service"},
+		"client_secret": {"secret"},
+		"refresh_token": {refreshToken},
+	})
+
+	if !assert.Equal(t, http.StatusOK, status, "expected a refreshed token") {
+		return
+	}
+
+	claims, err := ip.VerifyToken(body["access_token"].(string))
+	if assert.NoError(t, err, "expected a valid token") {
+		assert.Equal(t, "alice", claims["sub"], "expected the user as subject")
+		assert.Equal(t, "admin", claims["role"], "expected the changed claims")
+		assert.Nil(t, claims["tenant"], "expected the replaced claims")
+	}
+
+	status, _ = postForm(t, ip, gotesthttp.TokenURI, url.Values{
+		"grant_type":    {gotesthttp.GrantRefreshToken},
+		"client_id":     {"service"},
+		"client_secret": {"secret"},
+		"refresh_token": {refreshToken},
+	})
+
+	assert.Equal(t, http.StatusBadRequest, status, "expected the refresh token to be used only once")
+}
+
+// TestIdentityProviderAuthorizationCodePKCE - tests the authorization code grant with PKCE
+func TestIdentityProviderAuthorizationCodePKCE(t *testing.T) {
+
+	ip := newIdentityProvider(t)
+	defer ip.Close()
+
+	verifier := strings.Repeat("verifier", 6)
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	res, err := client.Get(ip.Issuer() + gotesthttp.AuthorizeURI + "?" + url.Values{
+		"client_id":             {"spa"},
+		"redirect_uri":          {"http://app.local/callback"},
+		"scope":                 {"openid profile"},
+		"state":                 {"xyz"},
+		"nonce":                 {"n-1"},
+		"login_hint":            {"bob"},
+		"code_challenge":        {gotesthttp.CodeChallengeS256(verifier)},
+		"code_challenge_method": {"S256"},
+	}.Encode())
+	if !assert.NoError(t, err, "expected no error authorizing") {
+		return
+	}
+
+	res.Body.Close()
+
+	if !assert.Equal(t, http.StatusFound, res.StatusCode, "expected a redirect") {
+		return
+	}
+
+	location, err := url.Parse(res.Header.Get("Location"))
+	if !assert.NoError(t, err, "expected a valid location") {
+		return
+	}
+
+	assert.Equal(t, "xyz", location.Query().Get("state"), "expected the state")
+
+	form := url.Values{
+		"grant_type":    {gotesthttp.GrantAuthorizationCode},
+		"client_id":     {"spa"},
+		"code":          {location.Query().Get("code")},
+		"redirect_uri":  {"http://app.local/callback"},
+		"code_verifier": {"wrong"},
+	}
+
+	status, _ := postForm(t, ip, gotesthttp.TokenURI, form)
+	assert.Equal(t, http.StatusBadRequest, status, "expected an invalid verifier")
+
+	res, err = client.Get(ip.Issuer() + gotesthttp.AuthorizeURI + "?" + url.Values{
+		"client_id":      {"spa"},
+		"redirect_uri":   {"http://app.local/callback"},
+		"scope":          {"openid"},
+		"nonce":          {"n-1"},
+		"login_hint":     {"bob"},
+		"code_challenge": {verifier},
+	}.Encode())
+	if !assert.NoError(t, err, "expected no error authorizing") {
+		return
+	}
+
+	res.Body.Close()
+
+	location, _ = url.Parse(res.Header.Get("Location"))
+	form.Set("code", location.Query().Get("code"))
+	form.Set("code_verifier", verifier)
+
+	status, body := postForm(t, ip, gotesthttp.TokenURI, form)
+	if !assert.Equal(t, http.StatusOK, status, "expected a token") {
+		return
+	}
+
+	claims, err := ip.VerifyToken(body["id_token"].(string))
+	if assert.NoError(t, err, "expected a valid id token") {
+		assert.Equal(t, "bob", claims["sub"], "expected the login hint as subject")
+		assert.Equal(t, "n-1", claims["nonce"], "expected the nonce")
+	}
+}
+
+// TestIdentityProviderKeyRotation - tests the discovery and the JWKS key rotation
+func TestIdentityProviderKeyRotation(t *testing.T) {
+
+	ip := newIdentityProvider(t)
+	defer ip.Close()
+
+	oldToken := ip.IssueToken(map[string]interface{}{"sub": "old"})
+	oldKeyID := ip.KeyID()
+
+	ip.RotateKeys()
+
+	assert.NotEqual(t, oldKeyID, ip.KeyID(), "expected a new key")
+
+	res, err := http.Get(ip.Issuer() + gotesthttp.DiscoveryURI)
+	if !assert.NoError(t, err, "expected no error getting the discovery") {
+		return
+	}
+
+	discovery := map[string]interface{}{}
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&discovery), "expected a json body")
+	res.Body.Close()
+
+	res, err = http.Get(discovery["jwks_uri"].(string))
+	if !assert.NoError(t, err, "expected no error getting the jwks") {
+		return
+	}
+
+	jwks := struct {
+		Keys []map[string]string `json:"keys"`
+	}{}
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&jwks), "expected a json body")
+	res.Body.Close()
+
+	assert.Len(t, jwks.Keys, 2, "expected the current and the previous key")
+
+	_, err = ip.VerifyToken(oldToken)
+	assert.NoError(t, err, "expected the old token to be valid while its key is published")
+
+	ip.RetirePreviousKeys()
+
+	_, err = ip.VerifyToken(oldToken)
+	assert.Error(t, err, "expected the old token to be invalid after retiring the key")
+}
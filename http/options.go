@@ -0,0 +1,353 @@
+package http
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+/**
+* Functional options and the configuration validation.
+* @author rnojiri
+**/
+
+const (
+	defaultOptionsHost string = "localhost"
+	maxPort            int    = 65535
+)
+
+// Option - changes the configuration of the server created by NewServerWithOptions
+type Option func(configuration *Configuration) error
+
+// WithHost - the host to listen
+func WithHost(host string) Option {
+
+	return func(configuration *Configuration) error {
+		configuration.Host = host
+		return nil
+	}
+}
+
+// WithPort - the port to listen (0 chooses a free port)
+func WithPort(port int) Option {
+
+	return func(configuration *Configuration) error {
+		if err := validatePort(port); err != nil {
+			return err
+		}
+		configuration.Port = port
+		return nil
+	}
+}
+
+// WithSocketPath - listens on the unix socket instead of the host and port
+func WithSocketPath(socketPath string) Option {
+
+	return func(configuration *Configuration) error {
+		if socketPath == "" {
+			return fmt.Errorf("empty socket path")
+		}
+		configuration.SocketPath = socketPath
+		return nil
+	}
+}
+
+// WithEndpoints - adds the endpoints to the mode
+func WithEndpoints(mode string, endpoints ...Endpoint) Option {
+
+	return func(configuration *Configuration) error {
+		if len(endpoints) == 0 {
+			return fmt.Errorf("no endpoints for the mode %q", mode)
+		}
+
+		for i := range endpoints {
+			if err := validateEndpoint(mode, &endpoints[i]); err != nil {
+				return err
+			}
+		}
+
+		if configuration.Responses == nil {
+			configuration.Responses = map[string][]Endpoint{}
+		}
+
+		configuration.Responses[mode] = append(configuration.Responses[mode], endpoints...)
+
+		return nil
+	}
+}
+
+// WithVirtualHost - adds the endpoints to the mode of the virtual host
+func WithVirtualHost(host, mode string, endpoints ...Endpoint) Option {
+
+	return func(configuration *Configuration) error {
+		if host == "" {
+			return fmt.Errorf("empty virtual host")
+		}
+
+		if len(endpoints) == 0 {
+			return fmt.Errorf("no endpoints for the mode %q of the virtual host %q", mode, host)
+		}
+
+		for i := range endpoints {
+			if err := validateEndpoint(host+" "+mode, &endpoints[i]); err != nil {
+				return err
+			}
+		}
+
+		if configuration.VirtualHosts == nil {
+			configuration.VirtualHosts = map[string]map[string][]Endpoint{}
+		}
+
+		if configuration.VirtualHosts[host] == nil {
+			configuration.VirtualHosts[host] = map[string][]Endpoint{}
+		}
+
+		configuration.VirtualHosts[host][mode] = append(configuration.VirtualHosts[host][mode], endpoints...)
+
+		return nil
+	}
+}
+
+// WithTLS - serves https using the httptest certificate
+func WithTLS() Option {
+
+	return func(configuration *Configuration) error {
+		configuration.TLS = true
+		return nil
+	}
+}
+
+// WithCORS - the cross origin policy of all endpoints
+func WithCORS(policy *CORS) Option {
+
+	return func(configuration *Configuration) error {
+		if err := validateCORS(policy); err != nil {
+			return fmt.Errorf("invalid server cors: %w", err)
+		}
+		configuration.CORS = policy
+		return nil
+	}
+}
+
+// WithRateLimit - limits all requests of the server
+func WithRateLimit(policy *RateLimit) Option {
+
+	return func(configuration *Configuration) error {
+		if err := validateRateLimit(policy); err != nil {
+			return fmt.Errorf("invalid server rate limit: %w", err)
+		}
+		configuration.RateLimit = policy
+		return nil
+	}
+}
+
+// WithChaos - injects faults in the requests of all endpoints
+func WithChaos(policy *Chaos) Option {
+
+	return func(configuration *Configuration) error {
+		if err := validateChaos(policy); err != nil {
+			return fmt.Errorf("invalid server chaos: %w", err)
+		}
+		configuration.Chaos = policy
+		return nil
+	}
+}
+
+// WithPassthrough - proxies the requests without a configured endpoint or method to the upstream
+func WithPassthrough(policy *Passthrough) Option {
+
+	return func(configuration *Configuration) error {
+		if err := validatePassthrough(policy); err != nil {
+			return fmt.Errorf("invalid passthrough: %w", err)
+		}
+		configuration.Passthrough = policy
+		return nil
+	}
+}
+
+// WithRetention - limits the journal and the errors of long running tests
+func WithRetention(policy *Retention) Option {
+
+	return func(configuration *Configuration) error {
+		if err := policy.Validate(); err != nil {
+			return fmt.Errorf("invalid retention: %w", err)
+		}
+		configuration.Retention = policy
+		return nil
+	}
+}
+
+// NewServerWithOptions - creates a server listening on localhost at a free port by default,
+// it is closed by the cleanup of the test, benchmark or fuzz test (when not nil)
+func NewServerWithOptions(tb testing.TB, options ...Option) (*Server, error) {
+
+	configuration := &Configuration{
+		Host: defaultOptionsHost,
+		T:    tb,
+	}
+
+	for _, option := range options {
+		if err := option(configuration); err != nil {
+			return nil, err
+		}
+	}
+
+	return NewServerE(configuration)
+}
+
+// Validate - checks the configuration returning the first problem found
+func (c *Configuration) Validate() error {
+
+	if len(c.Responses) == 0 && len(c.VirtualHosts) == 0 {
+		return fmt.Errorf("expected at least one response")
+	}
+
+	if err := validatePort(c.Port); err != nil {
+		return err
+	}
+
+	if c.SocketPath != "" && c.Port != 0 {
+		return fmt.Errorf("the port %d can not be used with the socket path %q", c.Port, c.SocketPath)
+	}
+
+	if err := validateRateLimit(c.RateLimit); err != nil {
+		return fmt.Errorf("invalid server rate limit: %w", err)
+	}
+
+	if err := validateCORS(c.CORS); err != nil {
+		return fmt.Errorf("invalid server cors: %w", err)
+	}
+
+	if err := validateChaos(c.Chaos); err != nil {
+		return fmt.Errorf("invalid server chaos: %w", err)
+	}
+
+	if err := validatePassthrough(c.Passthrough); err != nil {
+		return fmt.Errorf("invalid passthrough: %w", err)
+	}
+
+	if err := c.Retention.Validate(); err != nil {
+		return fmt.Errorf("invalid retention: %w", err)
+	}
+
+	for mode, endpoints := range c.Responses {
+		for i := range endpoints {
+			if err := validateEndpoint(mode, &endpoints[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	for host, modes := range c.VirtualHosts {
+
+		if host == "" {
+			return fmt.Errorf("empty virtual host")
+		}
+
+		for mode, endpoints := range modes {
+			for i := range endpoints {
+				if err := validateEndpoint(host+" "+mode, &endpoints[i]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// validatePort - checks the port range
+func validatePort(port int) error {
+
+	if port < 0 || port > maxPort {
+		return fmt.Errorf("invalid port %d, expected a value between 0 and %d", port, maxPort)
+	}
+
+	return nil
+}
+
+// validateRateLimit - checks the policy, nil policies are valid
+func validateRateLimit(policy *RateLimit) error {
+
+	if policy == nil {
+		return nil
+	}
+
+	if policy.Limit <= 0 {
+		return fmt.Errorf("expected a positive limit, found %d", policy.Limit)
+	}
+
+	if policy.Window <= 0 {
+		return fmt.Errorf("expected a positive window, found %s", policy.Window)
+	}
+
+	if policy.Algorithm != FixedWindow && policy.Algorithm != TokenBucket {
+		return fmt.Errorf("unknown algorithm: %d", policy.Algorithm)
+	}
+
+	return nil
+}
+
+// validateEndpoint - checks the uri, the methods and the responses
+func validateEndpoint(mode string, endpoint *Endpoint) error {
+
+	if endpoint.URI == "" {
+		return fmt.Errorf("mode %q: empty endpoint uri", mode)
+	}
+
+	if endpoint.Regexp {
+		if _, err := regexp.Compile(endpoint.URI); err != nil {
+			return fmt.Errorf("mode %q: invalid uri regexp %q: %w", mode, endpoint.URI, err)
+		}
+	}
+
+	if len(endpoint.Methods) == 0 {
+		return fmt.Errorf("mode %q: no methods configured for the uri %q", mode, endpoint.URI)
+	}
+
+	if err := validateRateLimit(endpoint.RateLimit); err != nil {
+		return fmt.Errorf("mode %q: invalid rate limit for the uri %q: %w", mode, endpoint.URI, err)
+	}
+
+	if err := validateCORS(endpoint.CORS); err != nil {
+		return fmt.Errorf("mode %q: invalid cors for the uri %q: %w", mode, endpoint.URI, err)
+	}
+
+	if err := validateChaos(endpoint.Chaos); err != nil {
+		return fmt.Errorf("mode %q: invalid chaos for the uri %q: %w", mode, endpoint.URI, err)
+	}
+
+	if err := validateSignatureVerification(endpoint.Signature); err != nil {
+		return fmt.Errorf("mode %q: invalid signature verification for the uri %q: %w", mode, endpoint.URI, err)
+	}
+
+	for method, response := range endpoint.Methods {
+
+		if method == "" || strings.ContainsAny(method, " \t\r\n") {
+			return fmt.Errorf("mode %q: invalid method %q for the uri %q", mode, method, endpoint.URI)
+		}
+
+		if response.Status == 0 && response.Func == nil {
+			return fmt.Errorf("mode %q: no status for %s %s", mode, method, endpoint.URI)
+		}
+
+		if response.Status != 0 && (response.Status < 100 || response.Status > 999) {
+			return fmt.Errorf("mode %q: invalid status %d for %s %s", mode, response.Status, method, endpoint.URI)
+		}
+
+		for _, format := range response.Formats {
+			if _, ok := formatMediaTypes[format]; !ok {
+				return fmt.Errorf("mode %q: unknown format %q for %s %s", mode, format, method, endpoint.URI)
+			}
+		}
+
+		for i := range response.Webhooks {
+			if err := validateWebhook(&response.Webhooks[i]); err != nil {
+				return fmt.Errorf("mode %q: invalid webhook for %s %s: %w", mode, method, endpoint.URI, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package http_test
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	gotesthttp "github.com/rnojiri/gotest/http"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* The tests for the functional options and the configuration validation.
+* @author rnojiri
+**/
+
+// webhookEndpoint - an endpoint option triggering the webhook
+func webhookEndpoint(webhook gotesthttp.Webhook) gotesthttp.Option {
+
+	return gotesthttp.WithEndpoints("default", gotesthttp.Endpoint{
+		URI:     "/a",
+		Methods: map[string]gotesthttp.Response{http.MethodPost: {Status: http.StatusOK, Webhooks: []gotesthttp.Webhook{webhook}}},
+	})
+}
+
+// TestNewServerWithOptions - tests creating a server using options closed by the test cleanup
+func TestNewServerWithOptions(t *testing.T) {
+
+	var server *gotesthttp.Server
+
+	t.Run("create", func(t *testing.T) {
+
+		var err error
+
+		server, err = gotesthttp.NewServerWithOptions(t,
+			gotesthttp.WithEndpoints("default", newTextEndpoint("/ok", "ok")),
+			gotesthttp.WithRateLimit(&gotesthttp.RateLimit{Limit: 10, Window: time.Second}),
+		)
+		if !assert.NoError(t, err, "expected no error") {
+			return
+		}
+
+		res := server.DoRequest(&gotesthttp.Request{URI: "/ok", Method: http.MethodGet})
+		res.Body.Close()
+
+		assert.Equal(t, http.StatusOK, res.StatusCode, "expected ok")
+	})
+
+	if server == nil {
+		return
+	}
+
+	_, err := http.Get("http://" + server.Address() + "/ok")
+	assert.Error(t, err, "expected the server closed by the cleanup")
+}
+
+// TestConfigurationErrors - tests the descriptive errors instead of panics
+func TestConfigurationErrors(t *testing.T) {
+
+	testCases := []struct {
+		options  []gotesthttp.Option
+		expected string
+	}{
+		{nil, "expected at least one response"},
+		{[]gotesthttp.Option{gotesthttp.WithPort(70000)}, "invalid port 70000"},
+		{[]gotesthttp.Option{gotesthttp.WithSocketPath("")}, "empty socket path"},
+		{[]gotesthttp.Option{gotesthttp.WithEndpoints("default")}, `no endpoints for the mode "default"`},
+		{[]gotesthttp.Option{gotesthttp.WithEndpoints("default", gotesthttp.Endpoint{URI: "/a"})}, `no methods configured for the uri "/a"`},
+		{[]gotesthttp.Option{gotesthttp.WithEndpoints("default", gotesthttp.Endpoint{URI: "/[", Regexp: true, Methods: newTextEndpoint("/", "ok").Methods})}, "invalid uri regexp"},
+		{[]gotesthttp.Option{gotesthttp.WithRateLimit(&gotesthttp.RateLimit{Limit: 1})}, "expected a positive window"},
+		{[]gotesthttp.Option{
+			gotesthttp.WithEndpoints("default", gotesthttp.Endpoint{
+				URI:     "/a",
+				Methods: map[string]gotesthttp.Response{http.MethodGet: {Status: http.StatusOK, Formats: []string{"toml"}}},
+			}),
+		}, `unknown format "toml"`},
+		{[]gotesthttp.Option{gotesthttp.WithEndpoints("default", gotesthttp.Endpoint{
+			URI:     "/a",
+			Methods: map[string]gotesthttp.Response{http.MethodGet: {Body: "no status"}},
+		})}, "no status for GET /a"},
+		{[]gotesthttp.Option{webhookEndpoint(gotesthttp.Webhook{})}, "empty webhook url"},
+		{[]gotesthttp.Option{webhookEndpoint(gotesthttp.Webhook{URL: "http://localhost/hook", Signature: &gotesthttp.WebhookSignature{Secret: "s"}})}, "empty webhook signature header"},
+		{[]gotesthttp.Option{webhookEndpoint(gotesthttp.Webhook{URL: "http://localhost/hook", Signature: &gotesthttp.WebhookSignature{Header: "X-Signature", Algorithm: "md5"}})}, "unknown signature algorithm: md5"},
+	}
+
+	for _, testCase := range testCases {
+
+		server, err := gotesthttp.NewServerWithOptions(t, testCase.options...)
+		assert.Nil(t, server, "expected no server")
+
+		if assert.Error(t, err, "expected an error") {
+			assert.Contains(t, err.Error(), testCase.expected, "expected a descriptive error")
+		}
+	}
+
+	_, err := gotesthttp.NewServerE(nil)
+	assert.Error(t, err, "expected an error for a null configuration")
+
+	busy, err := gotesthttp.NewServerWithOptions(t, gotesthttp.WithEndpoints("default", newTextEndpoint("/ok", "ok")))
+	if !assert.NoError(t, err, "expected no error") {
+		return
+	}
+
+	_, rawPort, _ := net.SplitHostPort(busy.Address())
+	port, _ := strconv.Atoi(rawPort)
+
+	_, err = gotesthttp.NewServerE(&gotesthttp.Configuration{
+		Host:      "localhost",
+		Port:      port,
+		Responses: map[string][]gotesthttp.Endpoint{"default": {newTextEndpoint("/ok", "ok")}},
+	})
+	assert.Error(t, err, "expected an error listening on a busy port")
+}
+
+// TestNewServerNotValidated - tests the old constructor accepting the endpoints without validation
+func TestNewServerNotValidated(t *testing.T) {
+
+	conf := defaultConf
+	conf.T = t
+	conf.Port = 0
+	conf.Responses = map[string][]gotesthttp.Endpoint{
+		"default": {
+			{URI: "", Methods: map[string]gotesthttp.Response{http.MethodGet: {Status: http.StatusOK, Body: "root"}}},
+			{URI: "/empty"},
+		},
+	}
+
+	var server *gotesthttp.Server
+
+	if !assert.NotPanics(t, func() { server = gotesthttp.NewServer(&conf) }, "expected no validation") {
+		return
+	}
+
+	defer server.Close()
+
+	res := server.DoRequest(&gotesthttp.Request{URI: "/", Method: http.MethodGet})
+	res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected the empty uri cleaned to the root")
+
+	assert.Panics(t, func() { gotesthttp.NewServer(nil) }, "expected the null configuration to panic")
+}
+
+// TestNewServerNotClosedByCleanup - tests the old constructor leaving the server to be closed by the caller
+func TestNewServerNotClosedByCleanup(t *testing.T) {
+
+	var server *gotesthttp.Server
+
+	t.Run("create", func(t *testing.T) {
+
+		conf := defaultConf
+		conf.T = t
+		conf.Port = 0
+		conf.Responses = map[string][]gotesthttp.Endpoint{"default": {newTextEndpoint("/ok", "ok")}}
+
+		server = gotesthttp.NewServer(&conf)
+	})
+
+	defer server.Close()
+
+	res, err := http.Get("http://" + server.Address() + "/ok")
+	if assert.NoError(t, err, "expected the server not closed by the cleanup") {
+		res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode, "expected ok")
+	}
+}
+
+// BenchmarkServer - benchmarks the requests using a server created with a benchmark
+func BenchmarkServer(b *testing.B) {
+
+	server, err := gotesthttp.NewServerWithOptions(b, gotesthttp.WithEndpoints("default", newTextEndpoint("/ok", "ok")))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for i := 0; i < b.N; i++ {
+		res := server.DoRequest(&gotesthttp.Request{URI: "/ok", Method: http.MethodGet})
+		res.Body.Close()
+	}
+}
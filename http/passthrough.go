@@ -0,0 +1,140 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+)
+
+/**
+* Proxies the requests without a configured endpoint to a real upstream (partial mocks).
+* @author rnojiri
+**/
+
+// Passthrough - the upstream receiving the requests without a configured endpoint or method
+type Passthrough struct {
+	// URL - the upstream url, its path is used as prefix of the proxied uris
+	URL string
+	// Host - the Host header sent to the upstream (the upstream host by default)
+	Host string
+	// KeepHost - sends the Host header received by the server (has precedence over Host)
+	KeepHost bool
+	// Headers - replaces these headers in the proxied requests
+	Headers http.Header
+	// RemoveHeaders - removes these headers from the proxied requests
+	RemoveHeaders []string
+	// ResponseHeaders - replaces these headers in the upstream responses
+	ResponseHeaders http.Header
+}
+
+// ByPassthrough - selects the requests by the proxied flag
+func ByPassthrough(passthrough bool) RequestFilter {
+
+	return func(request *Request) bool {
+		return request.Passthrough == passthrough
+	}
+}
+
+// parsePassthroughURL - parses the upstream url
+func parsePassthroughURL(policy *Passthrough) (*url.URL, error) {
+
+	target, err := url.Parse(policy.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upstream url %q: %w", policy.URL, err)
+	}
+
+	if (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, fmt.Errorf("expected an absolute http or https upstream url, found %q", policy.URL)
+	}
+
+	return target, nil
+}
+
+// validatePassthrough - checks the policy, nil policies are valid
+func validatePassthrough(policy *Passthrough) error {
+
+	if policy == nil {
+		return nil
+	}
+
+	_, err := parsePassthroughURL(policy)
+
+	return err
+}
+
+// newPassthroughProxy - creates the reverse proxy rewriting the hosts and headers, nil policies return nil
+func (hs *Server) newPassthroughProxy(policy *Passthrough) (*httputil.ReverseProxy, error) {
+
+	if policy == nil {
+		return nil, nil
+	}
+
+	target, err := parsePassthroughURL(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+
+			pr.SetURL(target)
+			pr.SetXForwarded()
+
+			if policy.KeepHost {
+				pr.Out.Host = pr.In.Host
+			} else if policy.Host != "" {
+				pr.Out.Host = policy.Host
+			}
+
+			pr.Out.Header.Del(ModeHeader)
+			pr.Out.Header.Del(TagHeader)
+
+			for _, name := range policy.RemoveHeaders {
+				pr.Out.Header.Del(name)
+			}
+
+			for name, values := range policy.Headers {
+				pr.Out.Header[http.CanonicalHeaderKey(name)] = values
+			}
+		},
+		ModifyResponse: func(res *http.Response) error {
+
+			for name, values := range policy.ResponseHeaders {
+				res.Header[http.CanonicalHeaderKey(name)] = values
+			}
+
+			return nil
+		},
+		ErrorHandler: func(res http.ResponseWriter, req *http.Request, err error) {
+
+			hs.addError(fmt.Errorf("passthrough of %s %s failed: %w", req.Method, req.URL.Path, err))
+			http.Error(res, "passthrough failed: "+err.Error(), http.StatusBadGateway)
+		},
+	}, nil
+}
+
+// passthrough - proxies the request to the upstream and records it in the journal
+func (hs *Server) passthrough(res http.ResponseWriter, req *http.Request, virtualHost, mode, cleanURI string, received time.Time) {
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		hs.fail(res, http.StatusBadRequest, "error reading request body: %v", err)
+		return
+	}
+
+	request := newRequest(req, mode, cleanURI, body, received)
+	request.Passthrough = true
+	request.VirtualHost = virtualHost
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	sequences := hs.begin([]Request{request})
+
+	hs.proxy.ServeHTTP(res, req)
+
+	hs.finish(sequences, req.Context().Err() != nil)
+}
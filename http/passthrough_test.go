@@ -0,0 +1,170 @@
+package http_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	gotesthttp "github.com/rnojiri/gotest/http"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* The tests for the passthrough of the unmatched requests.
+* @author rnojiri
+**/
+
+// TestPassthrough - tests the unmatched requests proxied to the upstream and recorded in the journal
+func TestPassthrough(t *testing.T) {
+
+	upstream, err := gotesthttp.NewServerWithOptions(t,
+		gotesthttp.WithEndpoints("default",
+			gotesthttp.Endpoint{
+				URI: "/api/users",
+				Methods: map[string]gotesthttp.Response{
+					http.MethodPost: {Status: http.StatusCreated, Body: "created"},
+				},
+			},
+			newTextEndpoint("/api/stubbed", "real"),
+		),
+	)
+	if !assert.NoError(t, err, "expected no error creating the upstream") {
+		return
+	}
+
+	server, err := gotesthttp.NewServerWithOptions(t,
+		gotesthttp.WithEndpoints("default", newTextEndpoint("/stubbed", "mock")),
+		gotesthttp.WithPassthrough(&gotesthttp.Passthrough{
+			URL:             "http://" + upstream.Address() + "/api",
+			Host:            "real.service",
+			Headers:         http.Header{"Authorization": {"Bearer upstream"}},
+			RemoveHeaders:   []string{"X-Secret"},
+			ResponseHeaders: http.Header{"X-Proxied": {"true"}},
+		}),
+	)
+	if !assert.NoError(t, err, "expected no error creating the server") {
+		return
+	}
+
+	status, body := doGet(t, server, "/stubbed")
+	assert.Equal(t, http.StatusOK, status, "expected the stubbed response")
+	assert.Equal(t, "mock", body, "expected the mocked body")
+
+	res := server.DoRequest(&gotesthttp.Request{
+		URI:     "/users",
+		Method:  http.MethodPost,
+		Body:    []byte(`{"name":"test"}`),
+		Headers: http.Header{"X-Secret": {"s"}, "Authorization": {"Bearer client"}},
+		Tag:     "proxied",
+	})
+	res.Body.Close()
+
+	assert.Equal(t, http.StatusCreated, res.StatusCode, "expected the upstream status")
+	assert.Equal(t, "true", res.Header.Get("X-Proxied"), "expected the response header")
+
+	received := upstream.TakeRequest()
+	if assert.NotNil(t, received, "expected the proxied request") {
+		assert.Equal(t, "/api/users", received.URI, "expected the prefixed uri")
+		assert.Equal(t, `{"name":"test"}`, string(received.Body), "expected the body")
+		assert.Equal(t, "Bearer upstream", received.Headers.Get("Authorization"), "expected the replaced header")
+		assert.Empty(t, received.Headers.Get("X-Secret"), "expected the removed header")
+		assert.Empty(t, received.Tag, "expected the gotest headers removed")
+	}
+
+	proxied := server.Requests(gotesthttp.ByPassthrough(true))
+	if assert.Len(t, proxied, 1, "expected the proxied request in the journal") {
+		assert.Equal(t, "/users", proxied[0].URI, "expected the received uri")
+		assert.Equal(t, "proxied", proxied[0].Tag, "expected the tag")
+		assert.Equal(t, `{"name":"test"}`, string(proxied[0].Body), "expected the body")
+	}
+
+	assert.Len(t, server.Requests(gotesthttp.ByPassthrough(false)), 1, "expected the stubbed request")
+}
+
+// TestPassthroughHost - tests the host sent to the upstream
+func TestPassthroughHost(t *testing.T) {
+
+	hosts := make(chan string, 1)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		hosts <- req.Host
+	}))
+	defer upstream.Close()
+
+	upstreamURL, err := url.Parse(upstream.URL)
+	if !assert.NoError(t, err, "expected no error parsing the upstream url") {
+		return
+	}
+
+	testCases := []struct {
+		name     string
+		policy   gotesthttp.Passthrough
+		expected string
+	}{
+		{"upstream", gotesthttp.Passthrough{}, upstreamURL.Host},
+		{"rewritten", gotesthttp.Passthrough{Host: "real.service"}, "real.service"},
+		{"kept", gotesthttp.Passthrough{Host: "ignored", KeepHost: true}, "original.test"},
+	}
+
+	for _, testCase := range testCases {
+
+		t.Run(testCase.name, func(t *testing.T) {
+
+			policy := testCase.policy
+			policy.URL = upstream.URL
+
+			server, err := gotesthttp.NewServerWithOptions(t,
+				gotesthttp.WithEndpoints("default", newTextEndpoint("/stubbed", "mock")),
+				gotesthttp.WithPassthrough(&policy),
+			)
+			if !assert.NoError(t, err, "expected no error") {
+				return
+			}
+
+			req, err := http.NewRequest(http.MethodGet, "http://"+server.Address()+"/unmatched", nil)
+			if !assert.NoError(t, err, "expected no error creating the request") {
+				return
+			}
+
+			req.Host = "original.test"
+
+			res, err := http.DefaultClient.Do(req)
+			if !assert.NoError(t, err, "expected no error") {
+				return
+			}
+
+			res.Body.Close()
+
+			assert.Equal(t, testCase.expected, <-hosts, "expected the host")
+		})
+	}
+}
+
+// TestPassthroughErrors - tests the unreachable upstream and the invalid urls
+func TestPassthroughErrors(t *testing.T) {
+
+	server, err := gotesthttp.NewServerE(&gotesthttp.Configuration{
+		Host:        "localhost",
+		Responses:   map[string][]gotesthttp.Endpoint{"default": {newTextEndpoint("/stubbed", "mock")}},
+		Passthrough: &gotesthttp.Passthrough{URL: "http://localhost:1"},
+	})
+	if !assert.NoError(t, err, "expected no error") {
+		return
+	}
+
+	defer server.Close()
+
+	status, _ := doGet(t, server, "/unmatched")
+	assert.Equal(t, http.StatusBadGateway, status, "expected the bad gateway")
+	assert.Len(t, server.GetErrors(), 1, "expected the proxy error")
+	assert.Len(t, server.Requests(gotesthttp.ByPassthrough(true)), 1, "expected the request in the journal")
+
+	for _, invalid := range []string{"", "localhost:8080", "ftp://localhost", "http://"} {
+		_, err = gotesthttp.NewServerWithOptions(t,
+			gotesthttp.WithEndpoints("default", newTextEndpoint("/x", "ok")),
+			gotesthttp.WithPassthrough(&gotesthttp.Passthrough{URL: invalid}),
+		)
+		assert.Error(t, err, "expected the invalid url: %q", invalid)
+	}
+}
@@ -0,0 +1,263 @@
+package http
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/**
+* Rate limit and quota simulation for the server and the endpoints.
+* @author rnojiri
+**/
+
+// RateLimitAlgorithm - the algorithm used to limit the requests
+type RateLimitAlgorithm int
+
+const (
+	// FixedWindow - allows Limit requests in each Window
+	FixedWindow RateLimitAlgorithm = iota
+	// TokenBucket - a bucket of Limit tokens refilled completely after each Window
+	TokenBucket
+)
+
+// The rate limit headers.
+const (
+	RateLimitLimitHeader     string = "X-RateLimit-Limit"
+	RateLimitRemainingHeader string = "X-RateLimit-Remaining"
+	RateLimitResetHeader     string = "X-RateLimit-Reset"
+	apiKeyHeader             string = "X-Api-Key"
+	apiKeyQueryParameter     string = "api_key"
+)
+
+// RateLimitKey - returns the key identifying the client of the request
+type RateLimitKey func(req *http.Request) string
+
+// RateLimit - the rate limit policy
+type RateLimit struct {
+	// Algorithm - the algorithm (FixedWindow by default)
+	Algorithm RateLimitAlgorithm
+	// Limit - the requests allowed by window (or the bucket capacity)
+	Limit int
+	// Window - the window duration (or the time to refill the whole bucket)
+	Window time.Duration
+	// KeyBy - identifies the clients, a nil value limits all requests together
+	KeyBy RateLimitKey
+}
+
+const rateLimitPruneInterval = time.Second
+
+// rateLimitState - the state of a client
+type rateLimitState struct {
+	start  time.Time
+	count  int
+	tokens float64
+	// window - the policy window, the state is pruned after a window without requests
+	window time.Duration
+}
+
+// rateLimiter - the state of all policies
+type rateLimiter struct {
+	states map[string]*rateLimitState
+	pruned time.Time
+	mutex  sync.Mutex
+}
+
+// rateLimitScope - a policy and the scope of its states
+type rateLimitScope struct {
+	scope  string
+	policy *RateLimit
+}
+
+// KeyByHeader - identifies the clients by a header value
+func KeyByHeader(name string) RateLimitKey {
+
+	return func(req *http.Request) string {
+		return req.Header.Get(name)
+	}
+}
+
+// KeyByClientIP - identifies the clients by the remote ip
+func KeyByClientIP() RateLimitKey {
+
+	return func(req *http.Request) string {
+		host, _, err := net.SplitHostPort(req.RemoteAddr)
+		if err != nil {
+			return req.RemoteAddr
+		}
+
+		return host
+	}
+}
+
+// KeyByAPIKey - identifies the clients by the X-Api-Key header or the api_key query parameter
+func KeyByAPIKey() RateLimitKey {
+
+	return func(req *http.Request) string {
+		if key := req.Header.Get(apiKeyHeader); key != "" {
+			return key
+		}
+
+		return req.URL.Query().Get(apiKeyQueryParameter)
+	}
+}
+
+// allow - checks the policies consuming them only when all allow the request, returns the policy
+// used in the headers (the denying or the last one), its remaining requests, the time to reset
+// and if the request is allowed
+func (rl *rateLimiter) allow(scopes []rateLimitScope, req *http.Request, now time.Time) (*RateLimit, int, time.Duration, bool) {
+
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	if rl.states == nil {
+		rl.states = map[string]*rateLimitState{}
+	}
+
+	rl.prune(now)
+
+	states := make([]*rateLimitState, len(scopes))
+
+	for i, s := range scopes {
+
+		key := s.scope
+		if s.policy.KeyBy != nil {
+			key += "|" + s.policy.KeyBy(req)
+		}
+
+		state, ok := rl.states[key]
+		if !ok {
+			state = &rateLimitState{start: now, tokens: float64(s.policy.Limit), window: s.policy.Window}
+			rl.states[key] = state
+		}
+
+		state.refresh(s.policy, now)
+
+		if wait, ok := state.available(s.policy, now); !ok {
+			return s.policy, 0, wait, false
+		}
+
+		states[i] = state
+	}
+
+	var remaining int
+	var reset time.Duration
+
+	for i, s := range scopes {
+		remaining, reset = states[i].consume(s.policy, now)
+	}
+
+	return scopes[len(scopes)-1].policy, remaining, reset, true
+}
+
+// prune - removes the states without requests in the last window, at most once a second
+func (rl *rateLimiter) prune(now time.Time) {
+
+	if now.Sub(rl.pruned) < rateLimitPruneInterval {
+		return
+	}
+
+	rl.pruned = now
+
+	for key, state := range rl.states {
+		if now.Sub(state.start) >= state.window {
+			delete(rl.states, key)
+		}
+	}
+}
+
+// refresh - starts a new window or refills the bucket
+func (s *rateLimitState) refresh(policy *RateLimit, now time.Time) {
+
+	if policy.Algorithm == TokenBucket {
+
+		rate := float64(policy.Limit) / float64(policy.Window)
+
+		s.tokens = math.Min(float64(policy.Limit), s.tokens+float64(now.Sub(s.start))*rate)
+		s.start = now
+
+		return
+	}
+
+	if now.Sub(s.start) >= policy.Window {
+		s.start = now
+		s.count = 0
+	}
+}
+
+// available - checks if a request can be consumed, returns the time to wait when it can not
+func (s *rateLimitState) available(policy *RateLimit, now time.Time) (time.Duration, bool) {
+
+	if policy.Algorithm == TokenBucket {
+
+		if s.tokens < 1 {
+			rate := float64(policy.Limit) / float64(policy.Window)
+			return time.Duration(math.Ceil((1 - s.tokens) / rate)), false
+		}
+
+		return 0, true
+	}
+
+	if s.count >= policy.Limit {
+		return s.start.Add(policy.Window).Sub(now), false
+	}
+
+	return 0, true
+}
+
+// consume - takes a token or counts the request in the window, returns the remaining requests and the time to reset
+func (s *rateLimitState) consume(policy *RateLimit, now time.Time) (int, time.Duration) {
+
+	if policy.Algorithm == TokenBucket {
+
+		rate := float64(policy.Limit) / float64(policy.Window)
+
+		s.tokens--
+
+		return int(s.tokens), time.Duration(math.Ceil((float64(policy.Limit) - s.tokens) / rate))
+	}
+
+	s.count++
+
+	return policy.Limit - s.count, s.start.Add(policy.Window).Sub(now)
+}
+
+// checkRateLimits - checks the server and the endpoint policies, adds the rate limit headers
+// and returns the throttled response if the request is not allowed
+func (hs *Server) checkRateLimits(virtualHost, mode string, endpoint *Endpoint, req *http.Request, headers http.Header) (*Response, bool) {
+
+	scopes := make([]rateLimitScope, 0, 2)
+
+	if hs.configuration.RateLimit != nil {
+		scopes = append(scopes, rateLimitScope{"server", hs.configuration.RateLimit})
+	}
+
+	if endpoint.RateLimit != nil {
+		scopes = append(scopes, rateLimitScope{"endpoint|" + virtualHost + "|" + mode + "|" + endpoint.URI, endpoint.RateLimit})
+	}
+
+	if len(scopes) == 0 {
+		return nil, false
+	}
+
+	policy, remaining, reset, allowed := hs.rateLimiter.allow(scopes, req, time.Now())
+
+	resetSeconds := strconv.FormatInt(int64(math.Ceil(reset.Seconds())), 10)
+
+	headers.Set(RateLimitLimitHeader, strconv.Itoa(policy.Limit))
+	headers.Set(RateLimitRemainingHeader, strconv.Itoa(remaining))
+	headers.Set(RateLimitResetHeader, resetSeconds)
+
+	if !allowed {
+		return &Response{
+			Status:  http.StatusTooManyRequests,
+			Body:    "rate limit exceeded",
+			Headers: http.Header{"Retry-After": []string{resetSeconds}},
+		}, true
+	}
+
+	return nil, false
+}
@@ -0,0 +1,121 @@
+package http_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	gotesthttp "github.com/rnojiri/gotest/http"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* The tests for the rate limit policies.
+* @author rnojiri
+**/
+
+// TestFixedWindowRateLimit - tests the fixed window algorithm by api key
+func TestFixedWindowRateLimit(t *testing.T) {
+
+	endpoint := newTextEndpoint("/limited", "ok")
+	endpoint.RateLimit = &gotesthttp.RateLimit{
+		Algorithm: gotesthttp.FixedWindow,
+		Limit:     2,
+		Window:    time.Minute,
+		KeyBy:     gotesthttp.KeyByAPIKey(),
+	}
+
+	defaultConf.T = t
+	defaultConf.Responses = map[string][]gotesthttp.Endpoint{"default": {endpoint}}
+
+	server := gotesthttp.NewServer(&defaultConf)
+	defer server.Close()
+
+	doWithKey := func(key string) *http.Response {
+		res := server.DoRequest(&gotesthttp.Request{
+			URI:     "/limited",
+			Method:  http.MethodGet,
+			Headers: http.Header{"X-Api-Key": []string{key}},
+		})
+		res.Body.Close()
+		return res
+	}
+
+	res := doWithKey("a")
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected the first request")
+	assert.Equal(t, "2", res.Header.Get(gotesthttp.RateLimitLimitHeader), "expected the limit header")
+	assert.Equal(t, "1", res.Header.Get(gotesthttp.RateLimitRemainingHeader), "expected the remaining header")
+
+	assert.Equal(t, http.StatusOK, doWithKey("a").StatusCode, "expected the second request")
+
+	res = doWithKey("a")
+	assert.Equal(t, http.StatusTooManyRequests, res.StatusCode, "expected the third request to be throttled")
+	assert.Equal(t, "60", res.Header.Get("Retry-After"), "expected the window as retry after")
+	assert.Equal(t, "0", res.Header.Get(gotesthttp.RateLimitRemainingHeader), "expected no remaining requests")
+
+	assert.Equal(t, http.StatusOK, doWithKey("b").StatusCode, "expected other keys to have their own quota")
+
+	assert.Len(t, server.Requests(gotesthttp.ByThrottled(true)), 1, "expected one throttled request in the journal")
+	assert.Len(t, server.Requests(gotesthttp.ByThrottled(false)), 3, "expected the allowed requests in the journal")
+}
+
+// TestTokenBucketRateLimit - tests the token bucket algorithm on the whole server
+func TestTokenBucketRateLimit(t *testing.T) {
+
+	defaultConf.T = t
+	defaultConf.Responses = map[string][]gotesthttp.Endpoint{"default": {newTextEndpoint("/a", "a"), newTextEndpoint("/b", "b")}}
+	defaultConf.RateLimit = &gotesthttp.RateLimit{
+		Algorithm: gotesthttp.TokenBucket,
+		Limit:     2,
+		Window:    200 * time.Millisecond,
+	}
+
+	defer func() {
+		defaultConf.RateLimit = nil
+	}()
+
+	server := gotesthttp.NewServer(&defaultConf)
+	defer server.Close()
+
+	assert.Equal(t, http.StatusOK, doStatus(server, "/a"), "expected a token")
+	assert.Equal(t, http.StatusOK, doStatus(server, "/b"), "expected a token")
+	assert.Equal(t, http.StatusTooManyRequests, doStatus(server, "/a"), "expected an empty bucket")
+
+	<-time.After(150 * time.Millisecond)
+
+	assert.Equal(t, http.StatusOK, doStatus(server, "/b"), "expected a refilled token")
+}
+
+// TestServerAndEndpointRateLimits - tests the server quota not consumed by the requests throttled by the endpoint
+func TestServerAndEndpointRateLimits(t *testing.T) {
+
+	limited := newTextEndpoint("/limited", "ok")
+	limited.RateLimit = &gotesthttp.RateLimit{Limit: 1, Window: time.Minute}
+
+	server, err := gotesthttp.NewServerWithOptions(t,
+		gotesthttp.WithEndpoints("default", limited, newTextEndpoint("/free", "ok")),
+		gotesthttp.WithRateLimit(&gotesthttp.RateLimit{Limit: 3, Window: time.Minute}),
+	)
+	if err != nil {
+		t.Fatalf("error creating the server: %v", err)
+	}
+
+	assert.Equal(t, http.StatusOK, doStatus(server, "/limited"), "expected the endpoint quota")
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusTooManyRequests, doStatus(server, "/limited"), "expected the endpoint throttling")
+	}
+
+	assert.Equal(t, http.StatusOK, doStatus(server, "/free"), "expected the server quota not consumed")
+	assert.Equal(t, http.StatusOK, doStatus(server, "/free"), "expected the server quota not consumed")
+	assert.Equal(t, http.StatusTooManyRequests, doStatus(server, "/free"), "expected the server throttling")
+}
+
+// doStatus - does a GET returning only the status
+func doStatus(server *gotesthttp.Server, uri string) int {
+
+	res := server.DoRequest(&gotesthttp.Request{URI: uri, Method: http.MethodGet})
+	res.Body.Close()
+
+	return res.StatusCode
+}
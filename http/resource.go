@@ -0,0 +1,544 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/**
+* Mocks a REST collection backed by an in-memory store.
+* @author rnojiri
+**/
+
+// The resource defaults.
+const (
+	defaultResourceIDField       string = "id"
+	defaultResourcePageParam     string = "page"
+	defaultResourcePageSizeParam string = "page_size"
+	resourceTotalCountHeader     string = "X-Total-Count"
+	locationHeader               string = "Location"
+)
+
+// ResourceConfiguration - the in-memory resource configuration
+type ResourceConfiguration struct {
+	// URI - the collection uri like /v1/items, the items are under /v1/items/{id}
+	URI string
+	// IDField - the field identifying the items ("id" by default)
+	IDField string
+	// Seed - the initial items, the ones without id receive a generated one
+	Seed []map[string]interface{}
+	// PageSize - the default page size of the list (all items when zero)
+	PageSize int
+	// PageParam - the query parameter with the page starting at 1 ("page" by default)
+	PageParam string
+	// PageSizeParam - the query parameter with the page size ("page_size" by default)
+	PageSizeParam string
+}
+
+// Resource - an in-memory REST collection answering list, get, create, update, patch and delete,
+// the other query parameters of the list filter the items by the fields with the same name
+type Resource struct {
+	configuration *ResourceConfiguration
+	uriRegexp     *regexp.Regexp
+	ids           []string
+	items         map[string]map[string]interface{}
+	nextID        int64
+	mutex         sync.Mutex
+}
+
+// resourceError - the error response
+type resourceError struct {
+	Error string `json:"error"`
+}
+
+// NewResource - creates the resource with the seed items, panics if the configuration is invalid (see NewResourceE)
+func NewResource(configuration *ResourceConfiguration) *Resource {
+
+	r, err := NewResourceE(configuration)
+	if err != nil {
+		panic(err)
+	}
+
+	return r
+}
+
+// NewResourceE - creates the resource with the seed items validating the configuration
+func NewResourceE(configuration *ResourceConfiguration) (*Resource, error) {
+
+	if configuration == nil {
+		return nil, fmt.Errorf("null configuration")
+	}
+
+	if !strings.HasPrefix(configuration.URI, "/") {
+		return nil, fmt.Errorf("expected an absolute resource uri, found %q", configuration.URI)
+	}
+
+	if configuration.PageSize < 0 {
+		return nil, fmt.Errorf("invalid page size: %d", configuration.PageSize)
+	}
+
+	c := *configuration
+	c.URI = strings.TrimSuffix(CleanURI(c.URI), "/")
+
+	if c.URI == "" {
+		return nil, fmt.Errorf("expected a collection uri, found %q", configuration.URI)
+	}
+
+	if c.IDField == "" {
+		c.IDField = defaultResourceIDField
+	}
+
+	if c.PageParam == "" {
+		c.PageParam = defaultResourcePageParam
+	}
+
+	if c.PageSizeParam == "" {
+		c.PageSizeParam = defaultResourcePageSizeParam
+	}
+
+	// the seed is normalized once so the reset can not fail
+	c.Seed = make([]map[string]interface{}, len(configuration.Seed))
+	for i, item := range configuration.Seed {
+
+		copied, err := copyResourceItem(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid seed item %d: %w", i, err)
+		}
+
+		c.Seed[i] = copied
+	}
+
+	r := &Resource{
+		configuration: &c,
+		// the endpoint uris are cleaned and can not start with ^, the prefix is checked by the answer
+		uriRegexp: regexp.MustCompile(regexp.QuoteMeta(c.URI) + `(/[^/?]*)?/?(\?.*)?$`),
+	}
+
+	r.Reset()
+
+	return r, nil
+}
+
+// Endpoint - returns the endpoint answering the collection and the items
+func (r *Resource) Endpoint() Endpoint {
+
+	return Endpoint{
+		URI:    r.uriRegexp.String(),
+		Regexp: true,
+		Methods: map[string]Response{
+			http.MethodGet:    {Func: r.answer},
+			http.MethodPost:   {Func: r.answer},
+			http.MethodPut:    {Func: r.answer},
+			http.MethodPatch:  {Func: r.answer},
+			http.MethodDelete: {Func: r.answer},
+		},
+	}
+}
+
+// Reset - replaces the stored items by the seed ones
+func (r *Resource) Reset() {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.ids = nil
+	r.items = map[string]map[string]interface{}{}
+	r.nextID = 0
+
+	for _, item := range r.configuration.Seed {
+		copied, _ := copyResourceItem(item)
+		r.store(copied)
+	}
+}
+
+// Items - returns a copy of the stored items in the insertion order
+func (r *Resource) Items() []map[string]interface{} {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	items := make([]map[string]interface{}, len(r.ids))
+	for i, id := range r.ids {
+		items[i], _ = copyResourceItem(r.items[id])
+	}
+
+	return items
+}
+
+// Item - returns a copy of the stored item
+func (r *Resource) Item(id string) (map[string]interface{}, bool) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	item, ok := r.items[id]
+	if !ok {
+		return nil, false
+	}
+
+	copied, _ := copyResourceItem(item)
+
+	return copied, true
+}
+
+// Put - stores a copy of the item replacing the one with the same id, the id is generated when
+// missing, returns the item id
+func (r *Resource) Put(item map[string]interface{}) (string, error) {
+
+	copied, err := copyResourceItem(item)
+	if err != nil {
+		return "", err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.store(copied), nil
+}
+
+// Delete - removes the item, returns false if not found
+func (r *Resource) Delete(id string) bool {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.remove(id)
+}
+
+// store - stores the item keeping the position of a replaced one, the mutex must be locked
+func (r *Resource) store(item map[string]interface{}) string {
+
+	value, ok := item[r.configuration.IDField]
+	if !ok || value == nil {
+		r.nextID++
+		value = float64(r.nextID)
+		item[r.configuration.IDField] = value
+	}
+
+	id := resourceValue(value)
+
+	if number, err := strconv.ParseInt(id, 10, 64); err == nil && number > r.nextID {
+		r.nextID = number
+	}
+
+	if _, exists := r.items[id]; !exists {
+		r.ids = append(r.ids, id)
+	}
+
+	r.items[id] = item
+
+	return id
+}
+
+// remove - removes the item, the mutex must be locked
+func (r *Resource) remove(id string) bool {
+
+	if _, ok := r.items[id]; !ok {
+		return false
+	}
+
+	delete(r.items, id)
+
+	for i := range r.ids {
+		if r.ids[i] == id {
+			r.ids = append(r.ids[:i], r.ids[i+1:]...)
+			break
+		}
+	}
+
+	return true
+}
+
+// answer - routes the request to the collection or to the item
+func (r *Resource) answer(request *Request) Response {
+
+	parsed, err := url.ParseRequestURI(request.URI)
+	if err != nil {
+		return resourceErrorResponse(http.StatusBadRequest, "invalid uri: %v", err)
+	}
+
+	if parsed.Path != r.configuration.URI && !strings.HasPrefix(parsed.Path, r.configuration.URI+"/") {
+		return resourceErrorResponse(http.StatusNotFound, "no resource under the uri: %s", parsed.Path)
+	}
+
+	id := strings.Trim(strings.TrimPrefix(parsed.Path, r.configuration.URI), "/")
+
+	// the automatic HEAD is answered like the GET
+	method := request.Method
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if id == "" {
+
+		switch method {
+		case http.MethodGet:
+			return r.list(parsed.Query())
+		case http.MethodPost:
+			return r.create(request)
+		}
+
+		return resourceErrorResponse(http.StatusMethodNotAllowed, "method %s not allowed in the collection", request.Method)
+	}
+
+	switch method {
+	case http.MethodGet:
+		return r.get(id)
+	case http.MethodPut:
+		return r.update(id, request, false)
+	case http.MethodPatch:
+		return r.update(id, request, true)
+	case http.MethodDelete:
+		return r.delete(id)
+	}
+
+	return resourceErrorResponse(http.StatusMethodNotAllowed, "method %s not allowed in the item", request.Method)
+}
+
+// list - answers the filtered page of items
+func (r *Resource) list(query url.Values) Response {
+
+	page, err := resourceQueryInt(query, r.configuration.PageParam, 1)
+	if err != nil {
+		return resourceErrorResponse(http.StatusBadRequest, "%v", err)
+	}
+
+	pageSize, err := resourceQueryInt(query, r.configuration.PageSizeParam, r.configuration.PageSize)
+	if err != nil {
+		return resourceErrorResponse(http.StatusBadRequest, "%v", err)
+	}
+
+	items := []map[string]interface{}{}
+	for _, id := range r.ids {
+		if r.matches(r.items[id], query) {
+			items = append(items, r.items[id])
+		}
+	}
+
+	total := len(items)
+
+	if pageSize > 0 {
+
+		start := (page - 1) * pageSize
+		if start > total {
+			start = total
+		}
+
+		end := start + pageSize
+		if end > total {
+			end = total
+		}
+
+		items = items[start:end]
+	}
+
+	response := resourceResponse(http.StatusOK, items)
+	response.Headers.Set(resourceTotalCountHeader, strconv.Itoa(total))
+
+	return response
+}
+
+// matches - checks the item fields against the query parameters, many values of the same
+// parameter match any of them
+func (r *Resource) matches(item map[string]interface{}, query url.Values) bool {
+
+	for name, values := range query {
+
+		if name == r.configuration.PageParam || name == r.configuration.PageSizeParam {
+			continue
+		}
+
+		value, ok := item[name]
+		if !ok {
+			return false
+		}
+
+		found := false
+		for _, expected := range values {
+			if resourceValue(value) == expected {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// get - answers the item
+func (r *Resource) get(id string) Response {
+
+	item, ok := r.items[id]
+	if !ok {
+		return resourceErrorResponse(http.StatusNotFound, "item not found: %s", id)
+	}
+
+	return resourceResponse(http.StatusOK, item)
+}
+
+// create - stores the posted item, existing ids are answered with 409
+func (r *Resource) create(request *Request) Response {
+
+	item, err := readResourceItem(request.Body)
+	if err != nil {
+		return resourceErrorResponse(http.StatusBadRequest, "%v", err)
+	}
+
+	if value, ok := item[r.configuration.IDField]; ok && value != nil {
+		if _, exists := r.items[resourceValue(value)]; exists {
+			return resourceErrorResponse(http.StatusConflict, "item already exists: %s", resourceValue(value))
+		}
+	}
+
+	id := r.store(item)
+
+	response := resourceResponse(http.StatusCreated, item)
+	response.Headers.Set(locationHeader, r.configuration.URI+"/"+url.PathEscape(id))
+
+	return response
+}
+
+// update - replaces the item or merges the fields (null removes a field) when patching
+func (r *Resource) update(id string, request *Request, patch bool) Response {
+
+	current, ok := r.items[id]
+	if !ok {
+		return resourceErrorResponse(http.StatusNotFound, "item not found: %s", id)
+	}
+
+	item, err := readResourceItem(request.Body)
+	if err != nil {
+		return resourceErrorResponse(http.StatusBadRequest, "%v", err)
+	}
+
+	if value, ok := item[r.configuration.IDField]; ok && value != nil && resourceValue(value) != id {
+		return resourceErrorResponse(http.StatusConflict, "the %s field does not match the item: %s", r.configuration.IDField, id)
+	}
+
+	if patch {
+
+		merged, _ := copyResourceItem(current)
+		for name, value := range item {
+			if value == nil {
+				delete(merged, name)
+			} else {
+				merged[name] = value
+			}
+		}
+
+		item = merged
+	}
+
+	item[r.configuration.IDField] = current[r.configuration.IDField]
+	r.items[id] = item
+
+	return resourceResponse(http.StatusOK, item)
+}
+
+// delete - removes the item
+func (r *Resource) delete(id string) Response {
+
+	if !r.remove(id) {
+		return resourceErrorResponse(http.StatusNotFound, "item not found: %s", id)
+	}
+
+	return Response{Status: http.StatusNoContent}
+}
+
+// readResourceItem - reads the json object of the body
+func readResourceItem(body []byte) (map[string]interface{}, error) {
+
+	item := map[string]interface{}{}
+
+	err := json.Unmarshal(body, &item)
+	if err != nil {
+		return nil, fmt.Errorf("expected a json object: %w", err)
+	}
+
+	if item == nil {
+		return nil, fmt.Errorf("expected a json object, found null")
+	}
+
+	return item, nil
+}
+
+// copyResourceItem - deep copies the item normalizing the values to their json representation
+func copyResourceItem(item map[string]interface{}) (map[string]interface{}, error) {
+
+	data, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+
+	return readResourceItem(data)
+}
+
+// resourceValue - formats the field value to compare with the ids and the query parameters
+func resourceValue(value interface{}) string {
+
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	return fmt.Sprint(value)
+}
+
+// resourceQueryInt - reads a positive query parameter
+func resourceQueryInt(query url.Values, name string, defaultValue int) (int, error) {
+
+	value := query.Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 1 {
+		return 0, fmt.Errorf("invalid %s: %s", name, value)
+	}
+
+	return number, nil
+}
+
+// resourceResponse - builds the json response, the stored items are copied because the body
+// is encoded after the store is unlocked
+func resourceResponse(status int, body interface{}) Response {
+
+	switch value := body.(type) {
+	case map[string]interface{}:
+		body, _ = copyResourceItem(value)
+	case []map[string]interface{}:
+		items := make([]map[string]interface{}, len(value))
+		for i := range value {
+			items[i], _ = copyResourceItem(value[i])
+		}
+		body = items
+	}
+
+	return Response{
+		Status:  status,
+		Body:    body,
+		Headers: http.Header{contentTypeHeader: []string{ContentTypeJSON}},
+	}
+}
+
+// resourceErrorResponse - builds the json error response
+func resourceErrorResponse(status int, format string, args ...interface{}) Response {
+
+	return resourceResponse(status, resourceError{Error: fmt.Sprintf(format, args...)})
+}
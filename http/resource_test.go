@@ -0,0 +1,227 @@
+package http_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	gotesthttp "github.com/rnojiri/gotest/http"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* The tests for the in-memory resources.
+* @author rnojiri
+**/
+
+// newResourceServer - creates a server with the items resource
+func newResourceServer(t *testing.T) (*gotesthttp.Server, *gotesthttp.Resource) {
+
+	resource := gotesthttp.NewResource(&gotesthttp.ResourceConfiguration{
+		URI: "/v1/items",
+		Seed: []map[string]interface{}{
+			{"id": 1, "name": "pen", "color": "blue"},
+			{"id": 2, "name": "pencil", "color": "black"},
+			{"id": 3, "name": "marker", "color": "blue"},
+		},
+	})
+
+	server, err := gotesthttp.NewServerWithOptions(t, gotesthttp.WithEndpoints("default", resource.Endpoint()))
+	if err != nil {
+		t.Fatalf("error creating the server: %v", err)
+	}
+
+	return server, resource
+}
+
+// doResourceRequest - sends the request and decodes the json response
+func doResourceRequest(t *testing.T, server *gotesthttp.Server, method, uri, body string) (*http.Response, interface{}) {
+
+	res := server.DoRequest(&gotesthttp.Request{
+		URI:     uri,
+		Method:  method,
+		Body:    []byte(body),
+		Headers: http.Header{"Content-Type": {"application/json"}},
+	})
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("error reading the body: %v", err)
+	}
+
+	var document interface{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &document); err != nil {
+			t.Fatalf("expected a json body: %s", data)
+		}
+	}
+
+	return res, document
+}
+
+// TestResourceCRUD - tests the create, get, update, patch and delete of the items
+func TestResourceCRUD(t *testing.T) {
+
+	server, resource := newResourceServer(t)
+
+	res, body := doResourceRequest(t, server, http.MethodPost, "/v1/items", `{"name":"eraser","color":"white"}`)
+	assert.Equal(t, http.StatusCreated, res.StatusCode, "expected the item created")
+	assert.Equal(t, "/v1/items/4", res.Header.Get("Location"), "expected the item location")
+	assert.Equal(t, map[string]interface{}{"id": 4.0, "name": "eraser", "color": "white"}, body, "expected the generated id")
+
+	res, _ = doResourceRequest(t, server, http.MethodPost, "/v1/items", `{"id":2,"name":"copy"}`)
+	assert.Equal(t, http.StatusConflict, res.StatusCode, "expected the existing id")
+
+	res, _ = doResourceRequest(t, server, http.MethodPost, "/v1/items", `[1,2]`)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "expected a json object")
+
+	res, body = doResourceRequest(t, server, http.MethodGet, "/v1/items/2", "")
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected the item")
+	assert.Equal(t, map[string]interface{}{"id": 2.0, "name": "pencil", "color": "black"}, body, "expected the seed item")
+
+	res, body = doResourceRequest(t, server, http.MethodPut, "/v1/items/2", `{"name":"crayon"}`)
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected the item replaced")
+	assert.Equal(t, map[string]interface{}{"id": 2.0, "name": "crayon"}, body, "expected only the new fields")
+
+	res, body = doResourceRequest(t, server, http.MethodPatch, "/v1/items/1", `{"color":"red","name":null}`)
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected the item patched")
+	assert.Equal(t, map[string]interface{}{"id": 1.0, "color": "red"}, body, "expected the fields merged")
+
+	res, _ = doResourceRequest(t, server, http.MethodPatch, "/v1/items/1", `{"id":7}`)
+	assert.Equal(t, http.StatusConflict, res.StatusCode, "expected the id not changed")
+
+	res, _ = doResourceRequest(t, server, http.MethodDelete, "/v1/items/3", "")
+	assert.Equal(t, http.StatusNoContent, res.StatusCode, "expected the item deleted")
+
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		res, body = doResourceRequest(t, server, method, "/v1/items/3", `{}`)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode, "expected not found: %s", method)
+		assert.Equal(t, map[string]interface{}{"error": "item not found: 3"}, body, "expected the error: %s", method)
+	}
+
+	res, _ = doResourceRequest(t, server, http.MethodPost, "/v1/items/1", `{}`)
+	assert.Equal(t, http.StatusMethodNotAllowed, res.StatusCode, "expected no post in the item")
+
+	items := resource.Items()
+	if assert.Len(t, items, 3, "expected the stored items") {
+		assert.Equal(t, []interface{}{1.0, 2.0, 4.0}, []interface{}{items[0]["id"], items[1]["id"], items[2]["id"]}, "expected the insertion order")
+	}
+}
+
+// TestResourceList - tests the pagination and the filters of the list
+func TestResourceList(t *testing.T) {
+
+	server, _ := newResourceServer(t)
+
+	testCases := []struct {
+		name  string
+		uri   string
+		names []interface{}
+		total string
+	}{
+		{name: "all", uri: "/v1/items", names: []interface{}{"pen", "pencil", "marker"}, total: "3"},
+		{name: "trailing bar", uri: "/v1/items/", names: []interface{}{"pen", "pencil", "marker"}, total: "3"},
+		{name: "filter", uri: "/v1/items?color=blue", names: []interface{}{"pen", "marker"}, total: "2"},
+		{name: "many values", uri: "/v1/items?name=pen&name=pencil", names: []interface{}{"pen", "pencil"}, total: "2"},
+		{name: "unknown field", uri: "/v1/items?size=10", names: []interface{}{}, total: "0"},
+		{name: "first page", uri: "/v1/items?page_size=2", names: []interface{}{"pen", "pencil"}, total: "3"},
+		{name: "second page", uri: "/v1/items?page=2&page_size=2", names: []interface{}{"marker"}, total: "3"},
+		{name: "after last page", uri: "/v1/items?page=3&page_size=2", names: []interface{}{}, total: "3"},
+		{name: "filtered page", uri: "/v1/items?color=blue&page=2&page_size=1", names: []interface{}{"marker"}, total: "2"},
+	}
+
+	for _, testCase := range testCases {
+
+		t.Run(testCase.name, func(t *testing.T) {
+
+			res, body := doResourceRequest(t, server, http.MethodGet, testCase.uri, "")
+			assert.Equal(t, http.StatusOK, res.StatusCode, "expected the list")
+			assert.Equal(t, testCase.total, res.Header.Get("X-Total-Count"), "expected the total")
+
+			names := []interface{}{}
+			for _, item := range body.([]interface{}) {
+				names = append(names, item.(map[string]interface{})["name"])
+			}
+
+			assert.Equal(t, testCase.names, names, "expected the listed items")
+		})
+	}
+
+	res, _ := doResourceRequest(t, server, http.MethodGet, "/v1/items?page=0", "")
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "expected an invalid page")
+}
+
+// TestResourceStore - tests the direct access to the store
+func TestResourceStore(t *testing.T) {
+
+	server, resource := newResourceServer(t)
+
+	id, err := resource.Put(map[string]interface{}{"id": "abc", "name": "ruler"})
+	if !assert.NoError(t, err, "expected no error storing the item") {
+		return
+	}
+
+	assert.Equal(t, "abc", id, "expected the given id")
+
+	res, body := doResourceRequest(t, server, http.MethodGet, "/v1/items/abc", "")
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected the stored item")
+	assert.Equal(t, map[string]interface{}{"id": "abc", "name": "ruler"}, body, "expected the stored item")
+
+	id, err = resource.Put(map[string]interface{}{"name": "glue"})
+	if !assert.NoError(t, err, "expected no error storing the item") {
+		return
+	}
+
+	assert.Equal(t, "4", id, "expected the generated id")
+
+	item, ok := resource.Item("1")
+	if !assert.True(t, ok, "expected the seed item") {
+		return
+	}
+
+	item["name"] = "changed"
+
+	item, _ = resource.Item("1")
+	assert.Equal(t, "pen", item["name"], "expected a copy of the item")
+
+	assert.True(t, resource.Delete("1"), "expected the item deleted")
+	assert.False(t, resource.Delete("1"), "expected the item not found")
+
+	res, _ = doResourceRequest(t, server, http.MethodGet, "/v1/items/1", "")
+	assert.Equal(t, http.StatusNotFound, res.StatusCode, "expected the deleted item")
+
+	resource.Reset()
+	assert.Len(t, resource.Items(), 3, "expected the seed items")
+
+	assert.Panics(t, func() {
+		gotesthttp.NewResource(&gotesthttp.ResourceConfiguration{URI: "items"})
+	}, "expected the relative uri")
+}
+
+// TestResourceURIPrefix - tests the uris only ending with the resource uri not answered
+func TestResourceURIPrefix(t *testing.T) {
+
+	server, _ := newResourceServer(t)
+
+	res, _ := doResourceRequest(t, server, http.MethodGet, "/v2/v1/items/1", "")
+	assert.Equal(t, http.StatusNotFound, res.StatusCode, "expected only the resource uri")
+}
+
+// TestResourceInvalidConfiguration - tests the errors creating the resources
+func TestResourceInvalidConfiguration(t *testing.T) {
+
+	invalid := map[string]*gotesthttp.ResourceConfiguration{
+		"null":          nil,
+		"relative uri":  {URI: "items"},
+		"root uri":      {URI: "/"},
+		"negative page": {URI: "/items", PageSize: -1},
+		"invalid seed":  {URI: "/items", Seed: []map[string]interface{}{{"id": make(chan int)}}},
+	}
+
+	for name, configuration := range invalid {
+		_, err := gotesthttp.NewResourceE(configuration)
+		assert.Errorf(t, err, "expected error for the configuration: %s", name)
+	}
+}
@@ -2,18 +2,22 @@ package http
 
 import (
 	"bytes"
-	"encoding/json"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/http/httputil"
 	"regexp"
-	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/jinzhu/copier"
+	"github.com/rnojiri/gotest/internal/lifecycle"
+	"github.com/rnojiri/gotest/internal/retention"
+	utils "github.com/rnojiri/gotest/utils"
 )
 
 /**
@@ -27,6 +31,52 @@ type Request struct {
 	Body    []byte
 	Method  string
 	Headers http.Header
+	// Mode - the mode used to answer (when sending, the mode asked to the server)
+	Mode string
+	// Tag - a free tag like the test name (sent using the TagHeader)
+	Tag string
+	// Throttled - the request was answered with 429 by a rate limit
+	Throttled bool
+	// Representation - the format chosen by the content negotiation
+	Representation string
+	// GraphQL - the parsed operation received by a GraphQL endpoint
+	GraphQL *GraphQLOperation
+	// JSONRPC - the call received by a JSON-RPC endpoint
+	JSONRPC *JSONRPCCall
+	// Chaos - the fault injected by the chaos policy
+	Chaos *ChaosInjection
+	// Passthrough - the request was proxied to the upstream
+	Passthrough bool
+	// Host - the Host header (when sending, overrides the host of the url)
+	Host string
+	// RemoteAddr - the client address
+	RemoteAddr string
+	// Proto - the protocol version like HTTP/1.1
+	Proto string
+	// ContentLength - the declared body length (-1 when unknown)
+	ContentLength int64
+	// TransferEncoding - the transfer encodings like chunked
+	TransferEncoding []string
+	// Trailers - the trailers sent after the body
+	Trailers http.Header
+	// TLS - the connection state of the https requests
+	TLS *tls.ConnectionState
+	// Received - when the request was received
+	Received time.Time
+	// Responded - when the response started to be written (zero while in flight or when aborted)
+	Responded time.Time
+	// Sequence - the unique and increasing number of the request in the journal (starts at 1)
+	Sequence uint64
+	// Endpoint - the uri (or regular expression) of the endpoint answering the request
+	Endpoint string
+	// Aborted - the client went away before the response
+	Aborted bool
+	// VirtualHost - the virtual host answering the request (empty for the default host)
+	VirtualHost string
+	// Preflight - the request is a CORS preflight
+	Preflight bool
+	// Signature - the signature verification result (nil when the endpoint does not verify)
+	Signature *SignatureCheck
 }
 
 // Response - the endpoint response data
@@ -39,8 +89,21 @@ type Response struct {
 	Status int
 	// Wait - a time to wait until responds
 	Wait time.Duration
+	// Func - generates the response from the request (the returned Func is ignored),
+	// the request can be changed to add information to the journal
+	Func ResponseFunc
+	// Formats - renders the Body in the first of these formats accepted by the client
+	// (FormatJSON, FormatXML, FormatYAML or FormatCSV), or answers 406 when none is acceptable
+	Formats []string
+	// Webhooks - the callbacks sent in the background after the response
+	Webhooks []Webhook
+	// journal - the requests recorded instead of the received one (set by the functions answering many calls)
+	journal []Request
 }
 
+// ResponseFunc - generates a response from the received request
+type ResponseFunc func(request *Request) Response
+
 // Endpoint - an endpoint to be listened
 type Endpoint struct {
 	// URI - the endpoint's uri
@@ -51,6 +114,14 @@ type Endpoint struct {
 	Methods map[string]Response
 	// Regexp - activates regular expression for uris
 	Regexp bool
+	// RateLimit - limits the requests of this endpoint
+	RateLimit *RateLimit
+	// Chaos - injects faults in the requests of this endpoint (has precedence over the server policy)
+	Chaos *Chaos
+	// CORS - the cross origin policy of this endpoint (has precedence over the server policy)
+	CORS *CORS
+	// Signature - verifies the HMAC signature of the received webhooks
+	Signature *SignatureVerification
 }
 
 // Server - the server listening for HTTP requests
@@ -62,200 +133,483 @@ type Server struct {
 	configuration *Configuration
 	mode          string
 	mutex         sync.Mutex
+	configMutex   sync.RWMutex
+	rateLimiter   rateLimiter
+	// chaos - the server chaos policy, protected by the configMutex
+	chaos      *Chaos
+	chaosState chaosState
+	// proxy - sends the unmatched requests to the passthrough upstream (nil when not configured)
+	proxy *httputil.ReverseProxy
+	// goldenNormalizers - protected by the mutex
+	goldenNormalizers []GoldenNormalizer
+	// webhooks - the webhook sender (replaced by Restart after Close), protected by the configMutex
+	webhooks *webhookSender
+	// deliveries - the finished webhook deliveries, protected by the mutex
+	deliveries []WebhookDelivery
+	// retention - applies the retention policy and counts the requests, protected by the mutex
+	retention *retention.Counter
+	byMethod  map[string]uint64
+	byURI     map[string]uint64
+	// sequence - the last journal sequence number, protected by the mutex
+	sequence uint64
+	// inFlight - the requests not answered yet by sequence, protected by the mutex
+	inFlight map[uint64]Request
+	// gate - holds the connections and requests while paused
+	gate lifecycle.Gate
+	// virtualHosts - the modes of each virtual host, protected by the configMutex
+	virtualHosts map[string]*virtualHost
 }
 
 // Configuration - configuration
 type Configuration struct {
-	Host      string
-	Port      int
+	// Host - the host to listen
+	Host string
+	// Port - the port to listen (0 chooses a free port)
+	Port int
+	// SocketPath - listens on this unix socket instead of the host and port (a path starting
+	// with @ is an abstract socket on linux), the socket file is removed when closed
+	SocketPath string
+	// Responses - the endpoints by mode
 	Responses map[string][]Endpoint
-	T         *testing.T
+	// T - the test, benchmark or fuzz test, when nil the failures are stored as errors (see GetErrors)
+	T testing.TB
+	// RateLimit - limits all requests of the server
+	RateLimit *RateLimit
+	// Retention - limits the journal and the errors of long running tests (nil keeps all)
+	Retention *Retention
+	// Chaos - injects faults in the requests of all endpoints
+	Chaos *Chaos
+	// Passthrough - proxies the requests without a configured endpoint or method to an upstream
+	Passthrough *Passthrough
+	// VirtualHosts - the endpoints by mode of each host, chosen by the TLS SNI or by the Host header
+	// (without the port), the requests to other hosts are answered by the Responses
+	VirtualHosts map[string]map[string][]Endpoint
+	// TLS - serves https using the httptest certificate (valid for example.com and the loopback addresses)
+	TLS bool
+	// CORS - the cross origin policy of all endpoints, the OPTIONS requests are answered
+	// automatically (with the Allow header) when not configured, like HEAD using the GET response
+	CORS *CORS
 }
 
+const (
+	// ModeHeader - the header used to choose the mode of a single request
+	ModeHeader string = "X-Gotest-Mode"
+	// ModeCookie - the cookie used to choose the mode of a single request (the header has precedence)
+	ModeCookie string = "gotest-mode"
+	// TagHeader - the header used to tag a request in the journal
+	TagHeader string = "X-Gotest-Tag"
+)
+
 var multipleBarRegexp = regexp.MustCompile("[/]+")
 
-// NewServer - creates a new HTTP listener server
+// NewServer - creates a new HTTP listener server without validating the endpoints,
+// panics on errors (see NewServerE)
 func NewServer(configuration *Configuration) *Server {
 
 	if configuration == nil {
 		panic(fmt.Errorf("null configuration"))
 	}
 
-	if len(configuration.Responses) == 0 {
+	if len(configuration.Responses) == 0 && len(configuration.VirtualHosts) == 0 {
 		panic(fmt.Errorf("expected at least one response"))
 	}
 
+	hs, err := newServer(configuration)
+	if err != nil {
+		panic(err)
+	}
+
+	return hs
+}
+
+// NewServerE - creates a new HTTP listener server validating the configuration,
+// the server is closed by the test cleanup when a test is configured
+func NewServerE(configuration *Configuration) (*Server, error) {
+
+	if configuration == nil {
+		return nil, fmt.Errorf("null configuration")
+	}
+
+	if err := configuration.Validate(); err != nil {
+		return nil, err
+	}
+
+	hs, err := newServer(configuration)
+	if err != nil {
+		return nil, err
+	}
+
+	if configuration.T != nil {
+		configuration.T.Cleanup(hs.Close)
+	}
+
+	return hs, nil
+}
+
+// newServer - creates and starts the server
+func newServer(configuration *Configuration) (*Server, error) {
+
 	hs := &Server{
-		requests: []Request{},
+		requests:   []Request{},
+		webhooks:   newWebhookSender(),
+		deliveries: []WebhookDelivery{},
+		retention:  retention.NewCounter(configuration.Retention),
+		byMethod:   map[string]uint64{},
+		byURI:      map[string]uint64{},
+		inFlight:   map[uint64]Request{},
+		chaos:      configuration.Chaos,
+	}
+
+	var err error
+	hs.proxy, err = hs.newPassthroughProxy(configuration.Passthrough)
+	if err != nil {
+		return nil, err
 	}
 
 	hs.responseMap = map[string]map[string]Endpoint{}
 	for mode, responses := range configuration.Responses {
 
-		hs.responseMap[mode] = map[string]Endpoint{}
-		for _, response := range responses {
-			response.URI = CleanURI(response.URI)
-			hs.responseMap[mode][response.URI] = response
-		}
-
+		hs.responseMap[mode] = buildEndpointMap(responses)
 		hs.mode = mode
 	}
 
-	hs.server = httptest.NewUnstartedServer(http.HandlerFunc(hs.handler))
+	hs.virtualHosts = map[string]*virtualHost{}
+	for host, modes := range configuration.VirtualHosts {
 
-	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", configuration.Host, configuration.Port))
-	if err != nil {
-		panic(err)
+		vh := &virtualHost{responseMap: map[string]map[string]Endpoint{}}
+		for mode, responses := range modes {
+			vh.responseMap[mode] = buildEndpointMap(responses)
+			vh.mode = mode
+		}
+
+		hs.virtualHosts[strings.ToLower(host)] = vh
 	}
 
 	confCopy := Configuration{}
 	copier.Copy(&confCopy, configuration)
 
-	hs.server.Listener = listener
-	hs.mutex = sync.Mutex{}
-	hs.server.Start()
 	hs.configuration = &confCopy
 
-	return hs
+	if err := hs.listen(); err != nil {
+		return nil, err
+	}
+
+	return hs, nil
+}
+
+// listen - starts the http server on the configured address, a random port is kept in the configuration
+func (hs *Server) listen() error {
+
+	network, address := "tcp", fmt.Sprintf("%s:%d", hs.configuration.Host, hs.configuration.Port)
+	if hs.configuration.SocketPath != "" {
+		network, address = "unix", hs.configuration.SocketPath
+		if err := utils.RemoveSocketFile(hs.configuration.SocketPath); err != nil {
+			return fmt.Errorf("error removing the stale socket file: %w", err)
+		}
+	}
+
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return fmt.Errorf("error listening on %s: %w", address, err)
+	}
+
+	if hs.configuration.SocketPath == "" && hs.configuration.Port == 0 {
+		hs.configuration.Port = listener.Addr().(*net.TCPAddr).Port
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(hs.handler))
+	server.Listener = lifecycle.NewListener(listener, &hs.gate)
+
+	if hs.configuration.TLS {
+		server.StartTLS()
+	} else {
+		server.Start()
+	}
+
+	hs.configMutex.Lock()
+	defer hs.configMutex.Unlock()
+
+	hs.server = server
+
+	return nil
 }
 
 // handler - handles all requests
 func (hs *Server) handler(res http.ResponseWriter, req *http.Request) {
 
+	if !hs.gate.Wait(req.Context().Done()) {
+		return
+	}
+
+	received := time.Now()
 	cleanURI := CleanURI(req.RequestURI)
 
-	modeMaps, ok := hs.responseMap[hs.mode]
+	virtualHost := hs.requestVirtualHost(req)
+
+	mode, modeMaps, ok := hs.snapshot(virtualHost, requestMode(req))
 	if !ok {
-		hs.configuration.T.Fatalf("no configuration set with name: %s", hs.mode)
+		hs.fail(res, http.StatusInternalServerError, "no configuration set with name: %s", mode)
+		return
 	}
 
-	var endpoint Endpoint
-	var err error
-	found := false
+	endpoint, found, err := findEndpoint(modeMaps, cleanURI)
+	if err != nil {
+		hs.fail(res, http.StatusInternalServerError, "failed to run regexp: %s", cleanURI)
+		return
+	}
 
-	for uri, item := range modeMaps {
+	if !found && hs.proxy != nil {
+		hs.passthrough(res, req, virtualHost, mode, cleanURI, received)
+		return
+	}
 
-		match := false
+	if !found {
+		hs.fail(res, http.StatusNotFound, "no enpoint configured with uri: %s", cleanURI)
+		return
+	}
 
-		if item.Regexp {
+	corsPolicy := hs.corsPolicy(&endpoint)
 
-			match, err = regexp.MatchString(uri, cleanURI)
-			if err != nil {
-				hs.configuration.T.Fatalf("failed to run regexp: %s", cleanURI)
-			}
+	var preflight bool
+	response, ok := endpoint.Methods[req.Method]
+	if !ok {
+		response, preflight, ok = automaticResponse(corsPolicy, &endpoint, req)
+	}
 
-			if match {
-				endpoint = item
-				found = true
-				break
-			}
+	if !ok && hs.proxy != nil {
+		hs.passthrough(res, req, virtualHost, mode, cleanURI, received)
+		return
+	}
+
+	if !ok {
+		hs.fail(res, http.StatusMethodNotAllowed, "no method configured under uri: %s", cleanURI)
+		return
+	}
+
+	bufferReqBody := new(bytes.Buffer)
+	_, err = bufferReqBody.ReadFrom(req.Body)
+	if err != nil {
+		hs.fail(res, http.StatusBadRequest, "error reading request body: %v", err)
+		return
+	}
+
+	request := newRequest(req, mode, cleanURI, bufferReqBody.Bytes(), received)
+	request.Endpoint = endpoint.URI
+	request.VirtualHost = virtualHost
+	request.Preflight = preflight
+
+	// recorded before the Func and the rate limits so the slow requests are visible in InFlight
+	sequences := hs.begin([]Request{request})
+	request.Sequence = sequences[0]
+
+	// the preflights are answered only by the CORS policy
+	if !preflight {
+
+		if endpoint.Signature != nil {
+			request.Signature = endpoint.Signature.verify(req.Header, request.Body, received)
 		}
 
-		if !match && uri == cleanURI {
-			endpoint = item
-			found = true
-			break
+		if throttled, ok := hs.checkRateLimits(virtualHost, mode, &endpoint, req, res.Header()); ok {
+			response = *throttled
+			request.Throttled = true
+		} else if request.Signature != nil && !request.Signature.Valid && endpoint.Signature.Reject {
+			response = signatureRejectedResponse(request.Signature)
+		} else if response.Func != nil {
+			response = response.Func(&request)
 		}
+
+		addCORSHeaders(corsPolicy, req, res.Header())
 	}
 
-	if !found {
-		hs.configuration.T.Fatalf("no enpoint configured with uri: %s", cleanURI)
+	if len(response.Formats) > 0 {
+		response, request.Representation, err = negotiate(req.Header.Get(acceptHeader), response)
+		if err != nil {
+			hs.finish(hs.update(request.Sequence, []Request{request}), false)
+			hs.fail(res, http.StatusInternalServerError, "error rendering the %s representation: %v", request.Representation, err)
+			return
+		}
 	}
 
-	response, ok := endpoint.Methods[req.Method]
-	if !ok {
-		hs.configuration.T.Fatalf("no method configured under uri: %s", cleanURI)
+	if !request.Throttled {
+		request.Chaos = hs.injectChaos(&endpoint, &response)
 	}
 
-	if response.Wait != 0 {
-		time.Sleep(response.Wait)
+	journal := []Request{request}
+	if len(response.journal) > 0 {
+		journal = make([]Request, len(response.journal))
+		for i, entry := range response.journal {
+			entry.Chaos = request.Chaos
+			entry.Representation = request.Representation
+			journal[i] = entry
+		}
+	}
+
+	sequences = hs.update(request.Sequence, journal)
+
+	if !hs.wait(req, response.Wait) {
+		hs.finish(sequences, true)
+		return
+	}
+
+	if request.Chaos != nil && (request.Chaos.Fault == ChaosReset || request.Chaos.Fault == ChaosTruncate) {
+		hs.finish(sequences, false)
+		hs.breakConnection(res, &response, request.Chaos.Fault)
+		return
+	}
+
+	hs.finish(sequences, false)
+	hs.writeResponse(res, &response)
+	hs.triggerWebhooks(&request, response.Webhooks)
+}
+
+// wait - waits the response delay, returns false if the client went away
+func (hs *Server) wait(req *http.Request, delay time.Duration) bool {
+
+	if delay <= 0 {
+		return req.Context().Err() == nil
 	}
 
-	AddHeaders(res.Header(), response.Headers)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
 
-	res.WriteHeader(response.Status)
+	select {
+	case <-timer.C:
+		return true
+	case <-req.Context().Done():
+		return false
+	}
+}
 
-	if response.Body != nil {
+// newRequest - creates the journal entry with the metadata of the received request (the body must be read)
+func newRequest(req *http.Request, mode, cleanURI string, body []byte, received time.Time) Request {
+
+	request := Request{
+		URI:              cleanURI,
+		Body:             body,
+		Headers:          req.Header.Clone(),
+		Method:           req.Method,
+		Mode:             mode,
+		Tag:              req.Header.Get(TagHeader),
+		Host:             req.Host,
+		RemoteAddr:       req.RemoteAddr,
+		Proto:            req.Proto,
+		ContentLength:    req.ContentLength,
+		TransferEncoding: append([]string(nil), req.TransferEncoding...),
+		Received:         received,
+	}
+
+	if len(req.Trailer) > 0 {
+		request.Trailers = req.Trailer.Clone()
+	}
+
+	if req.TLS != nil {
+		state := *req.TLS
+		request.TLS = &state
+	}
 
-		var inBytes []byte
-		var err error
-		switch response.Body.(type) {
-		case int:
-			inBytes = []byte(strconv.FormatInt(int64(response.Body.(int)), 10))
-		case string:
-			inBytes = []byte(response.Body.(string))
-		case bool:
-			inBytes = []byte(strconv.FormatBool(response.Body.(bool)))
-		default:
-			inBytes, err = json.Marshal(response.Body)
+	return request
+}
+
+// findEndpoint - finds the endpoint matching the uri
+func findEndpoint(modeMaps map[string]Endpoint, cleanURI string) (Endpoint, bool, error) {
+
+	for uri, item := range modeMaps {
+
+		if item.Regexp {
+
+			match, err := regexp.MatchString(uri, cleanURI)
 			if err != nil {
-				hs.configuration.T.Fatalf("error marshaling json: %v", err)
+				return Endpoint{}, false, err
+			}
+
+			if match {
+				return item, true, nil
 			}
 		}
 
-		_, err = res.Write(inBytes)
-		if err != nil {
-			hs.errors = append(hs.errors, err)
-			return
+		if uri == cleanURI {
+			return item, true, nil
 		}
 	}
 
-	bufferReqBody := new(bytes.Buffer)
-	_, err = bufferReqBody.ReadFrom(req.Body)
-	if err != nil {
-		hs.configuration.T.Fatalf("error reading request body: %v", err)
+	return Endpoint{}, false, nil
+}
+
+// fail - fails the test or, when there is no test configured, stores the error and
+// answers with the given status (a nil writer skips the response)
+func (hs *Server) fail(res http.ResponseWriter, status int, format string, args ...interface{}) {
+
+	if hs.configuration.T != nil {
+		hs.configuration.T.Fatalf(format, args...)
+		return
 	}
 
+	err := fmt.Errorf(format, args...)
+
+	hs.addError(err)
+
+	if res != nil {
+		http.Error(res, err.Error(), status)
+	}
+}
+
+// addError - stores an asynchronous error
+func (hs *Server) addError(err error) {
+
 	hs.mutex.Lock()
 	defer hs.mutex.Unlock()
 
-	hs.requests = append(
-		hs.requests,
-		Request{
-			URI:     cleanURI,
-			Body:    bufferReqBody.Bytes(),
-			Headers: req.Header.Clone(),
-			Method:  req.Method,
-		},
-	)
+	hs.errors = append(hs.errors, err)
+	hs.errors = retention.Evict(hs.errors, hs.retention.AddError(len(hs.errors)))
 }
 
-// Close - closes this server
+// Close - closes this server, the pending webhooks are canceled
 func (hs *Server) Close() {
 
-	if hs.server != nil {
-		hs.server.Close()
+	if server := hs.httpServer(); server != nil {
+		server.Close()
 	}
-}
 
-// RequestChannel - reads from the request channel
-func (hs *Server) RequestChannel() []Request {
+	if sender := hs.webhookSender(); sender != nil {
+		sender.close()
+	}
 
-	return hs.requests
+	if hs.configuration != nil && hs.configuration.SocketPath != "" {
+		if err := utils.RemoveSocketFile(hs.configuration.SocketPath); err != nil {
+			hs.addError(err)
+		}
+	}
 }
 
-func (hs *Server) FirstRequest() *Request {
+// GetErrors - get asynchronous errors
+func (hs *Server) GetErrors() []error {
 
 	hs.mutex.Lock()
 	defer hs.mutex.Unlock()
 
-	if len(hs.requests) == 0 {
-		return nil
-	}
+	return append([]error{}, hs.errors...)
+}
 
-	req := hs.requests[0]
+// Address - returns the address the server is listening
+func (hs *Server) Address() string {
 
-	if len(hs.requests) == 0 {
-		hs.requests = []Request{}
-	} else {
-		hs.requests = hs.requests[1:]
-	}
+	return hs.httpServer().Listener.Addr().String()
+}
+
+// httpServer - returns the current http server (replaced by Restart)
+func (hs *Server) httpServer() *httptest.Server {
 
-	return &req
+	hs.configMutex.RLock()
+	defer hs.configMutex.RUnlock()
+
+	return hs.server
 }
 
-// SetMode - sets the server mode
-func (hs *Server) SetMode(mode string) {
+// webhookSender - returns the current webhook sender (replaced by Restart)
+func (hs *Server) webhookSender() *webhookSender {
+
+	hs.configMutex.RLock()
+	defer hs.configMutex.RUnlock()
 
-	hs.mode = mode
+	return hs.webhooks
 }
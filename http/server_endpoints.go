@@ -0,0 +1,257 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+/**
+* Functions to change the endpoints of a running server.
+* The endpoint maps are never changed after being published, every change creates
+* a new map, so each request handles a consistent snapshot of the configuration.
+* @author rnojiri
+**/
+
+// buildEndpointMap - creates the endpoint map of a mode
+func buildEndpointMap(endpoints []Endpoint) map[string]Endpoint {
+
+	endpointMap := make(map[string]Endpoint, len(endpoints))
+	for _, endpoint := range endpoints {
+		endpoint = bufferReaders(endpoint)
+		endpoint.URI = CleanURI(endpoint.URI)
+		endpointMap[endpoint.URI] = endpoint
+	}
+
+	return endpointMap
+}
+
+// virtualHost - the modes of a host and its current mode
+type virtualHost struct {
+	responseMap map[string]map[string]Endpoint
+	mode        string
+}
+
+// hostModes - returns the modes and the current mode of the virtual host, the empty host is the
+// default one, an unknown host is created using the mode when create is set or nil is returned
+// (the configMutex must be locked)
+func (hs *Server) hostModes(host, mode string, create bool) (*map[string]map[string]Endpoint, *string) {
+
+	if host == "" {
+		return &hs.responseMap, &hs.mode
+	}
+
+	vh, ok := hs.virtualHosts[host]
+	if !ok {
+		if !create {
+			return nil, nil
+		}
+		vh = &virtualHost{responseMap: map[string]map[string]Endpoint{}, mode: mode}
+		hs.virtualHosts[host] = vh
+	}
+
+	return &vh.responseMap, &vh.mode
+}
+
+// snapshot - returns the mode and its endpoints of the virtual host, the host mode is used when
+// the requested one is empty
+func (hs *Server) snapshot(host, requestedMode string) (string, map[string]Endpoint, bool) {
+
+	hs.configMutex.RLock()
+	defer hs.configMutex.RUnlock()
+
+	responseMap, mode := hs.responseMap, hs.mode
+	if vh, ok := hs.virtualHosts[host]; ok {
+		responseMap, mode = vh.responseMap, vh.mode
+	}
+
+	if requestedMode != "" {
+		mode = requestedMode
+	}
+
+	endpoints, ok := responseMap[mode]
+
+	return mode, endpoints, ok
+}
+
+// requestMode - returns the mode chosen by the request header or cookie
+func requestMode(req *http.Request) string {
+
+	if mode := req.Header.Get(ModeHeader); mode != "" {
+		return mode
+	}
+
+	if cookie, err := req.Cookie(ModeCookie); err == nil {
+		return cookie.Value
+	}
+
+	return ""
+}
+
+// requestVirtualHost - returns the configured virtual host named by the TLS SNI or by the Host header,
+// or empty for the default host
+func (hs *Server) requestVirtualHost(req *http.Request) string {
+
+	hs.configMutex.RLock()
+	defer hs.configMutex.RUnlock()
+
+	candidates := []string{hostname(req.Host)}
+	if req.TLS != nil && req.TLS.ServerName != "" {
+		candidates = append([]string{strings.ToLower(req.TLS.ServerName)}, candidates...)
+	}
+
+	for _, candidate := range candidates {
+		if _, ok := hs.virtualHosts[candidate]; ok {
+			return candidate
+		}
+	}
+
+	return ""
+}
+
+// hostname - returns the lower case host without the port
+func hostname(host string) string {
+
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+
+	return strings.ToLower(strings.Trim(host, "[]"))
+}
+
+// updateMode - replaces the endpoints of a mode of the virtual host using a copy of the current ones,
+// unknown hosts and modes are created only when create is set, returns false when not updated
+func (hs *Server) updateMode(host, mode string, create bool, update func(endpoints map[string]Endpoint)) bool {
+
+	hs.configMutex.Lock()
+	defer hs.configMutex.Unlock()
+
+	responseMap, _ := hs.hostModes(host, mode, create)
+	if responseMap == nil {
+		return false
+	}
+
+	current, ok := (*responseMap)[mode]
+	if !ok && !create {
+		return false
+	}
+
+	endpoints := make(map[string]Endpoint, len(current)+1)
+	for uri, endpoint := range current {
+		endpoints[uri] = endpoint
+	}
+
+	update(endpoints)
+
+	updated := make(map[string]map[string]Endpoint, len(*responseMap)+1)
+	for m, e := range *responseMap {
+		updated[m] = e
+	}
+
+	updated[mode] = endpoints
+	*responseMap = updated
+
+	return true
+}
+
+// SetMode - sets the server mode
+func (hs *Server) SetMode(mode string) {
+
+	hs.SetHostMode("", mode)
+}
+
+// Mode - returns the server mode
+func (hs *Server) Mode() string {
+
+	return hs.HostMode("")
+}
+
+// SetHostMode - sets the mode of the virtual host (empty for the default host), unknown hosts are ignored
+func (hs *Server) SetHostMode(host, mode string) {
+
+	hs.configMutex.Lock()
+	defer hs.configMutex.Unlock()
+
+	if _, current := hs.hostModes(strings.ToLower(host), mode, false); current != nil {
+		*current = mode
+	}
+}
+
+// HostMode - returns the mode of the virtual host (empty for the default host)
+func (hs *Server) HostMode(host string) string {
+
+	hs.configMutex.RLock()
+	defer hs.configMutex.RUnlock()
+
+	if host == "" {
+		return hs.mode
+	}
+
+	if vh, ok := hs.virtualHosts[strings.ToLower(host)]; ok {
+		return vh.mode
+	}
+
+	return ""
+}
+
+// AddEndpoint - adds or replaces an endpoint in the mode (the mode is created if it does not exist)
+func (hs *Server) AddEndpoint(mode string, endpoint Endpoint) {
+
+	hs.AddHostEndpoint("", mode, endpoint)
+}
+
+// RemoveEndpoint - removes an endpoint (or regular expression) from the mode, returns false if it was not found
+func (hs *Server) RemoveEndpoint(mode string, uri string) bool {
+
+	return hs.RemoveHostEndpoint("", mode, uri)
+}
+
+// ReplaceMode - replaces all endpoints of the mode (the mode is created if it does not exist)
+func (hs *Server) ReplaceMode(mode string, endpoints []Endpoint) {
+
+	hs.ReplaceHostMode("", mode, endpoints)
+}
+
+// AddHostEndpoint - adds or replaces an endpoint in the mode of the virtual host (created if it does not exist)
+func (hs *Server) AddHostEndpoint(host, mode string, endpoint Endpoint) {
+
+	endpoint = bufferReaders(endpoint)
+	endpoint.URI = CleanURI(endpoint.URI)
+
+	hs.updateMode(strings.ToLower(host), mode, true, func(endpoints map[string]Endpoint) {
+		endpoints[endpoint.URI] = endpoint
+	})
+}
+
+// RemoveHostEndpoint - removes an endpoint (or regular expression) from the mode of the virtual host,
+// returns false if it was not found (unknown hosts and modes are not created)
+func (hs *Server) RemoveHostEndpoint(host, mode, uri string) bool {
+
+	found := false
+
+	hs.updateMode(strings.ToLower(host), mode, false, func(endpoints map[string]Endpoint) {
+		key := CleanURI(uri)
+		if _, ok := endpoints[key]; ok {
+			delete(endpoints, key)
+			found = true
+		}
+	})
+
+	return found
+}
+
+// ReplaceHostMode - replaces all endpoints of the mode of the virtual host (created if it does not exist)
+func (hs *Server) ReplaceHostMode(host, mode string, endpoints []Endpoint) {
+
+	endpointMap := buildEndpointMap(endpoints)
+
+	hs.updateMode(strings.ToLower(host), mode, true, func(current map[string]Endpoint) {
+		for uri := range current {
+			delete(current, uri)
+		}
+
+		for uri, endpoint := range endpointMap {
+			current[uri] = endpoint
+		}
+	})
+}
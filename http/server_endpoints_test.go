@@ -0,0 +1,256 @@
+package http_test
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+
+	gotesthttp "github.com/rnojiri/gotest/http"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* The tests for the dynamic endpoint functions.
+* @author rnojiri
+**/
+
+// newTextEndpoint - creates an endpoint answering a text on GET
+func newTextEndpoint(uri, text string) gotesthttp.Endpoint {
+
+	return gotesthttp.Endpoint{
+		URI: uri,
+		Methods: map[string]gotesthttp.Response{
+			http.MethodGet: {
+				Body:   text,
+				Status: http.StatusOK,
+			},
+		},
+	}
+}
+
+// doGet - does a GET returning the status and the body
+func doGet(t *testing.T, server *gotesthttp.Server, uri string) (int, string) {
+
+	res := server.DoRequest(&gotesthttp.Request{
+		URI:    uri,
+		Method: http.MethodGet,
+	})
+
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	assert.NoError(t, err, "expects no error reading the response body")
+
+	return res.StatusCode, string(body)
+}
+
+// TestAddRemoveEndpoints - tests changing the endpoints of a running server
+func TestAddRemoveEndpoints(t *testing.T) {
+
+	server := gotesthttp.NewServer(&gotesthttp.Configuration{
+		Host: "localhost",
+		Responses: map[string][]gotesthttp.Endpoint{
+			"default": {newTextEndpoint("/a", "a")},
+		},
+	})
+	defer server.Close()
+
+	server.AddEndpoint("default", newTextEndpoint("/b", "b"))
+
+	status, body := doGet(t, server, "/b")
+	assert.Equal(t, http.StatusOK, status, "expected the added endpoint")
+	assert.Equal(t, "b", body, "expected the added endpoint body")
+
+	assert.True(t, server.RemoveEndpoint("default", "/a"), "expected the endpoint to be found")
+	assert.False(t, server.RemoveEndpoint("default", "/a"), "expected the endpoint to be already removed")
+
+	status, _ = doGet(t, server, "/a")
+	assert.Equal(t, http.StatusNotFound, status, "expected the endpoint to be removed")
+
+	server.ReplaceMode("other", []gotesthttp.Endpoint{newTextEndpoint("/c", "c")})
+	server.SetMode("other")
+	assert.Equal(t, "other", server.Mode(), "expected the new mode")
+
+	status, body = doGet(t, server, "/c")
+	assert.Equal(t, http.StatusOK, status, "expected the replaced mode")
+	assert.Equal(t, "c", body, "expected the replaced mode body")
+
+	status, _ = doGet(t, server, "/b")
+	assert.Equal(t, http.StatusNotFound, status, "expected the endpoint from the other mode")
+
+	assert.Len(t, server.GetErrors(), 2, "expected the not found errors")
+}
+
+// TestConcurrentEndpointChanges - tests changing endpoints while receiving requests (run with -race)
+func TestConcurrentEndpointChanges(t *testing.T) {
+
+	defaultConf.T = t
+	defaultConf.Responses = map[string][]gotesthttp.Endpoint{
+		"mode1": {newTextEndpoint("/stable", "stable")},
+		"mode2": {newTextEndpoint("/stable", "stable")},
+	}
+
+	server := gotesthttp.NewServer(&defaultConf)
+	defer server.Close()
+
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < 50; i++ {
+			mode := fmt.Sprintf("mode%d", i%2+1)
+			server.AddEndpoint(mode, newTextEndpoint(fmt.Sprintf("/dynamic%d", i), "dynamic"))
+			server.SetMode(mode)
+			server.RemoveEndpoint(mode, fmt.Sprintf("/dynamic%d", i-1))
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < 50; i++ {
+			status, body := doGet(t, server, "/stable")
+			assert.Equal(t, http.StatusOK, status, "expected the stable endpoint")
+			assert.Equal(t, "stable", body, "expected the stable body")
+		}
+	}()
+
+	wg.Wait()
+}
+
+// TestVirtualHosts - tests the endpoints and modes of each host
+func TestVirtualHosts(t *testing.T) {
+
+	server, err := gotesthttp.NewServerWithOptions(t,
+		gotesthttp.WithEndpoints("default", newTextEndpoint("/status", "default")),
+		gotesthttp.WithVirtualHost("api.test", "up", newTextEndpoint("/status", "api up")),
+		gotesthttp.WithVirtualHost("api.test", "down", gotesthttp.Endpoint{
+			URI: "/status",
+			Methods: map[string]gotesthttp.Response{
+				http.MethodGet: {Status: http.StatusServiceUnavailable, Body: "api down"},
+			},
+		}),
+		gotesthttp.WithVirtualHost("Auth.Test", "default", newTextEndpoint("/status", "auth")),
+	)
+	if !assert.NoError(t, err, "expected no error") {
+		return
+	}
+
+	server.SetHostMode("api.test", "up")
+
+	testCases := []struct {
+		host   string
+		status int
+		body   string
+	}{
+		{"api.test", http.StatusOK, "api up"},
+		{"API.test:8080", http.StatusOK, "api up"},
+		{"auth.test", http.StatusOK, "auth"},
+		{"unknown.test", http.StatusOK, "default"},
+	}
+
+	for _, testCase := range testCases {
+		status, body := doGetHost(t, server, testCase.host, "/status")
+		assert.Equal(t, testCase.status, status, "expected the status of: %s", testCase.host)
+		assert.Equal(t, testCase.body, body, "expected the body of: %s", testCase.host)
+	}
+
+	server.SetHostMode("api.test", "down")
+	assert.Equal(t, "down", server.HostMode("api.test"), "expected the host mode")
+	assert.Equal(t, "default", server.Mode(), "expected the default mode unchanged")
+
+	status, body := doGetHost(t, server, "api.test", "/status")
+	assert.Equal(t, http.StatusServiceUnavailable, status, "expected the host mode status")
+	assert.Equal(t, "api down", body, "expected the host mode body")
+
+	server.AddHostEndpoint("auth.test", "default", newTextEndpoint("/status", "auth v2"))
+	_, body = doGetHost(t, server, "auth.test", "/status")
+	assert.Equal(t, "auth v2", body, "expected the added endpoint")
+
+	assert.True(t, server.RemoveHostEndpoint("auth.test", "default", "/status"), "expected the removed endpoint")
+
+	apiRequests := server.Requests(gotesthttp.ByHost("api.test"))
+	if assert.Len(t, apiRequests, 3, "expected the api requests") {
+		assert.Equal(t, "api.test", apiRequests[0].VirtualHost, "expected the virtual host")
+		assert.Equal(t, "up", apiRequests[0].Mode, "expected the host mode")
+	}
+
+	assert.Len(t, server.Requests(gotesthttp.ByHost("unknown.test")), 1, "expected the default host request")
+
+	server.AddHostEndpoint("new.test", "default", newTextEndpoint("/status", "new"))
+	assert.Equal(t, "default", server.HostMode("new.test"), "expected the new host using the added mode")
+
+	status, body = doGetHost(t, server, "new.test", "/status")
+	assert.Equal(t, http.StatusOK, status, "expected the new host status")
+	assert.Equal(t, "new", body, "expected the new host body")
+
+	assert.False(t, server.RemoveHostEndpoint("ghost.test", "default", "/status"), "expected the unknown host not found")
+	server.SetHostMode("ghost.test", "down")
+	assert.Equal(t, "", server.HostMode("ghost.test"), "expected the unknown host not created")
+
+	status, body = doGetHost(t, server, "ghost.test", "/status")
+	assert.Equal(t, http.StatusOK, status, "expected the default host status")
+	assert.Equal(t, "default", body, "expected the default host answering the unknown host")
+}
+
+// TestVirtualHostsSNI - tests choosing the virtual host by the TLS server name
+func TestVirtualHostsSNI(t *testing.T) {
+
+	server, err := gotesthttp.NewServerWithOptions(t,
+		gotesthttp.WithTLS(),
+		gotesthttp.WithVirtualHost("sni.test", "default", newTextEndpoint("/", "sni")),
+		gotesthttp.WithVirtualHost("header.test", "default", newTextEndpoint("/", "header")),
+	)
+	if !assert.NoError(t, err, "expected no error") {
+		return
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true, ServerName: "sni.test"},
+		},
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "https://"+server.Address()+"/", nil)
+	if !assert.NoError(t, err, "expected no error creating the request") {
+		return
+	}
+
+	req.Host = "header.test"
+
+	res, err := client.Do(req)
+	if !assert.NoError(t, err, "expected no error") {
+		return
+	}
+
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	assert.NoError(t, err, "expected no error reading the body")
+	assert.Equal(t, "sni", string(body), "expected the host chosen by the server name")
+
+	_, headerBody := doGetHost(t, server, "header.test", "/")
+	assert.Equal(t, "header", headerBody, "expected the host chosen by the header")
+
+	requests := server.Requests(gotesthttp.ByHost("sni.test"))
+	if assert.Len(t, requests, 1, "expected the sni request") && assert.NotNil(t, requests[0].TLS, "expected the tls state") {
+		assert.Equal(t, "sni.test", requests[0].TLS.ServerName, "expected the server name")
+	}
+}
+
+// doGetHost - does a GET to the host returning the status and the body
+func doGetHost(t *testing.T, server *gotesthttp.Server, host, uri string) (int, string) {
+
+	res := server.DoRequest(&gotesthttp.Request{URI: uri, Method: http.MethodGet, Host: host})
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	assert.NoError(t, err, "expected no error reading the body")
+
+	return res.StatusCode, string(body)
+}
@@ -0,0 +1,294 @@
+package http
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rnojiri/gotest/internal/retention"
+)
+
+/**
+* Functions to query the requests received by the server.
+* @author rnojiri
+**/
+
+// Retention - limits the journal of long running tests: keeps the last MaxEntries requests,
+// drops the bodies keeping the metadata or records one of each SampleRate requests
+type Retention = retention.Policy
+
+// RetentionStats - the counters of the received, recorded, not sampled and evicted entries
+type RetentionStats = retention.Stats
+
+// JournalStats - the aggregate statistics of all received requests, accurate even after the evictions
+type JournalStats struct {
+	RetentionStats
+	// ByMethod - the received requests by method
+	ByMethod map[string]uint64
+	// ByURI - the received requests by uri
+	ByURI map[string]uint64
+}
+
+// RequestFilter - selects requests from the journal
+type RequestFilter func(request *Request) bool
+
+// ByMode - selects the requests answered using the mode
+func ByMode(mode string) RequestFilter {
+
+	return func(request *Request) bool {
+		return request.Mode == mode
+	}
+}
+
+// ByTag - selects the requests sent with the tag
+func ByTag(tag string) RequestFilter {
+
+	return func(request *Request) bool {
+		return request.Tag == tag
+	}
+}
+
+// ByThrottled - selects the requests by the rate limit result
+func ByThrottled(throttled bool) RequestFilter {
+
+	return func(request *Request) bool {
+		return request.Throttled == throttled
+	}
+}
+
+// ByHost - selects the requests answered by the virtual host or sent with the Host header (ignoring the port)
+func ByHost(host string) RequestFilter {
+
+	host = strings.ToLower(host)
+
+	return func(request *Request) bool {
+		return request.VirtualHost == host || hostname(request.Host) == host
+	}
+}
+
+// ByAborted - selects the requests by the client cancellation
+func ByAborted(aborted bool) RequestFilter {
+
+	return func(request *Request) bool {
+		return request.Aborted == aborted
+	}
+}
+
+// matchFilters - checks if the request matches all filters
+func matchFilters(request *Request, filters []RequestFilter) bool {
+
+	for _, filter := range filters {
+		if !filter(request) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// record - adds the requests to the journal applying the retention policy, the sequence
+// numbers are set in the requests (the mutex must be locked)
+func (hs *Server) record(requests ...Request) {
+
+	for i := range requests {
+
+		hs.sequence++
+		requests[i].Sequence = hs.sequence
+
+		hs.byMethod[requests[i].Method]++
+		hs.byURI[requests[i].URI]++
+
+		keep, dropBody := hs.retention.Admit(len(requests[i].Body))
+		if !keep {
+			continue
+		}
+
+		request := requests[i]
+		if dropBody {
+			request.Body = nil
+		}
+
+		hs.requests = append(hs.requests, request)
+	}
+
+	hs.requests = retention.Evict(hs.requests, hs.retention.Overflow(len(hs.requests)))
+}
+
+// begin - records the requests before answering them, returns their sequence numbers
+func (hs *Server) begin(requests []Request) []uint64 {
+
+	hs.mutex.Lock()
+	defer hs.mutex.Unlock()
+
+	hs.record(requests...)
+
+	sequences := make([]uint64, len(requests))
+	for i := range requests {
+		sequences[i] = requests[i].Sequence
+		hs.inFlight[sequences[i]] = requests[i]
+	}
+
+	return sequences
+}
+
+// update - replaces the begun request by its final version, the first request keeps the sequence
+// and the others (like the calls of a batch) are recorded after it, returns the sequences of all
+func (hs *Server) update(sequence uint64, requests []Request) []uint64 {
+
+	hs.mutex.Lock()
+	defer hs.mutex.Unlock()
+
+	requests[0].Sequence = sequence
+	hs.inFlight[sequence] = requests[0]
+
+	if i := hs.journalIndex(sequence); i >= 0 {
+		request := requests[0]
+		if hs.requests[i].Body == nil {
+			request.Body = nil
+		}
+		hs.requests[i] = request
+	}
+
+	hs.record(requests[1:]...)
+
+	sequences := make([]uint64, len(requests))
+	for i := range requests {
+		sequences[i] = requests[i].Sequence
+		hs.inFlight[sequences[i]] = requests[i]
+	}
+
+	return sequences
+}
+
+// finish - marks the recorded requests as answered or aborted
+func (hs *Server) finish(sequences []uint64, aborted bool) {
+
+	responded := time.Now()
+
+	hs.mutex.Lock()
+	defer hs.mutex.Unlock()
+
+	for _, sequence := range sequences {
+
+		delete(hs.inFlight, sequence)
+
+		i := hs.journalIndex(sequence)
+		if i < 0 {
+			continue
+		}
+
+		if aborted {
+			hs.requests[i].Aborted = true
+		} else {
+			hs.requests[i].Responded = responded
+		}
+	}
+}
+
+// journalIndex - returns the index of the request in the journal or -1 when it was evicted,
+// taken or not sampled (the mutex must be locked)
+func (hs *Server) journalIndex(sequence uint64) int {
+
+	// the journal is ordered by sequence
+	for i := len(hs.requests) - 1; i >= 0 && hs.requests[i].Sequence >= sequence; i-- {
+		if hs.requests[i].Sequence == sequence {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// InFlight - returns a copy of the requests not answered yet (like the ones waiting the response delay)
+// matching all filters, ordered by sequence
+func (hs *Server) InFlight(filters ...RequestFilter) []Request {
+
+	hs.mutex.Lock()
+	defer hs.mutex.Unlock()
+
+	result := []Request{}
+	for sequence := range hs.inFlight {
+		request := hs.inFlight[sequence]
+		if matchFilters(&request, filters) {
+			result = append(result, request)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Sequence < result[j].Sequence
+	})
+
+	return result
+}
+
+// Stats - returns the statistics of all received requests, including the evicted and not sampled ones
+func (hs *Server) Stats() JournalStats {
+
+	hs.mutex.Lock()
+	defer hs.mutex.Unlock()
+
+	stats := JournalStats{
+		RetentionStats: hs.retention.Stats(),
+		ByMethod:       make(map[string]uint64, len(hs.byMethod)),
+		ByURI:          make(map[string]uint64, len(hs.byURI)),
+	}
+
+	for method, count := range hs.byMethod {
+		stats.ByMethod[method] = count
+	}
+
+	for uri, count := range hs.byURI {
+		stats.ByURI[uri] = count
+	}
+
+	return stats
+}
+
+// RequestChannel - returns a copy of the received requests
+func (hs *Server) RequestChannel() []Request {
+
+	return hs.Requests()
+}
+
+// Requests - returns a copy of the received requests matching all filters
+func (hs *Server) Requests(filters ...RequestFilter) []Request {
+
+	hs.mutex.Lock()
+	defer hs.mutex.Unlock()
+
+	result := []Request{}
+	for i := range hs.requests {
+		if matchFilters(&hs.requests[i], filters) {
+			result = append(result, hs.requests[i])
+		}
+	}
+
+	return result
+}
+
+// TakeRequest - removes and returns the first received request matching all filters
+func (hs *Server) TakeRequest(filters ...RequestFilter) *Request {
+
+	hs.mutex.Lock()
+	defer hs.mutex.Unlock()
+
+	for i := range hs.requests {
+
+		if !matchFilters(&hs.requests[i], filters) {
+			continue
+		}
+
+		req := hs.requests[i]
+		hs.requests = append(hs.requests[:i:i], hs.requests[i+1:]...)
+
+		return &req
+	}
+
+	return nil
+}
+
+// FirstRequest - removes and returns the first received request
+func (hs *Server) FirstRequest() *Request {
+
+	return hs.TakeRequest()
+}
@@ -0,0 +1,393 @@
+package http_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	gotesthttp "github.com/rnojiri/gotest/http"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* The tests for the per request mode and the journal queries.
+* @author rnojiri
+**/
+
+// TestParallelModes - tests parallel subtests using different modes on the same server
+func TestParallelModes(t *testing.T) {
+
+	server := gotesthttp.NewServer(&gotesthttp.Configuration{
+		Host: "localhost",
+		Responses: map[string][]gotesthttp.Endpoint{
+			"ok":    {newTextEndpoint("/resource", "ok")},
+			"error": {newTextEndpoint("/resource", "error")},
+		},
+		T: t,
+	})
+
+	server.SetMode("ok")
+
+	t.Run("group", func(t *testing.T) {
+
+		for _, mode := range []string{"ok", "error", ""} {
+
+			mode := mode
+
+			t.Run("mode-"+mode, func(t *testing.T) {
+				t.Parallel()
+
+				res := server.DoRequest(&gotesthttp.Request{
+					URI:    "/resource",
+					Method: http.MethodGet,
+					Mode:   mode,
+					Tag:    t.Name(),
+				})
+				res.Body.Close()
+
+				request := gotesthttp.WaitForServerRequest(server, 10*time.Millisecond, time.Second, gotesthttp.ByTag(t.Name()))
+				if !assert.NotNil(t, request, "expected a tagged request") {
+					return
+				}
+
+				expectedMode := mode
+				if expectedMode == "" {
+					expectedMode = "ok"
+				}
+
+				assert.Equal(t, expectedMode, request.Mode, "expected the requested mode")
+			})
+		}
+	})
+
+	server.Close()
+
+	assert.Empty(t, server.Requests(), "expected all requests to be taken")
+}
+
+// TestModeCookie - tests choosing the mode using a cookie and filtering by mode
+func TestModeCookie(t *testing.T) {
+
+	defaultConf.T = t
+	defaultConf.Responses = map[string][]gotesthttp.Endpoint{
+		"a": {newTextEndpoint("/x", "a")},
+		"b": {newTextEndpoint("/x", "b")},
+	}
+
+	server := gotesthttp.NewServer(&defaultConf)
+	defer server.Close()
+
+	server.SetMode("a")
+
+	headers := http.Header{}
+	headers.Add("Cookie", gotesthttp.ModeCookie+"=b")
+
+	res := server.DoRequest(&gotesthttp.Request{URI: "/x", Method: http.MethodGet, Headers: headers})
+	res.Body.Close()
+
+	res = server.DoRequest(&gotesthttp.Request{URI: "/x", Method: http.MethodGet})
+	res.Body.Close()
+
+	assert.Len(t, server.Requests(gotesthttp.ByMode("b")), 1, "expected one request using the cookie mode")
+	assert.Len(t, server.Requests(gotesthttp.ByMode("a")), 1, "expected one request using the server mode")
+	assert.Len(t, server.Requests(), 2, "expected all requests")
+}
+
+// TestRetention - tests the journal ring, the dropped bodies, the sampling and the statistics
+func TestRetention(t *testing.T) {
+
+	testCases := []struct {
+		name      string
+		retention *gotesthttp.Retention
+		journal   []string
+		stats     gotesthttp.RetentionStats
+	}{
+		{
+			name:      "ring",
+			retention: &gotesthttp.Retention{MaxEntries: 2},
+			journal:   []string{"3", "4"},
+			stats:     gotesthttp.RetentionStats{Received: 5, Recorded: 5, Evicted: 3, BodyBytes: 5},
+		},
+		{
+			name:      "drop bodies",
+			retention: &gotesthttp.Retention{DropBodies: true},
+			journal:   []string{"", "", "", "", ""},
+			stats:     gotesthttp.RetentionStats{Received: 5, Recorded: 5, BodiesDropped: 5, BodyBytes: 5},
+		},
+		{
+			name:      "sampling",
+			retention: &gotesthttp.Retention{SampleRate: 2},
+			journal:   []string{"0", "2", "4"},
+			stats:     gotesthttp.RetentionStats{Received: 5, Recorded: 3, NotSampled: 2, BodyBytes: 5},
+		},
+	}
+
+	for _, testCase := range testCases {
+
+		t.Run(testCase.name, func(t *testing.T) {
+
+			server, err := gotesthttp.NewServerWithOptions(t,
+				gotesthttp.WithEndpoints("default", newTextEndpoint("/x", "ok")),
+				gotesthttp.WithRetention(testCase.retention),
+			)
+			if !assert.NoError(t, err, "expected no error") {
+				return
+			}
+
+			for i := 0; i < 5; i++ {
+				res := server.DoRequest(&gotesthttp.Request{URI: "/x", Method: http.MethodGet, Body: []byte(strconv.Itoa(i))})
+				res.Body.Close()
+			}
+
+			bodies := []string{}
+			for _, request := range server.Requests() {
+				bodies = append(bodies, string(request.Body))
+			}
+
+			assert.Equal(t, testCase.journal, bodies, "expected the retained journal")
+
+			stats := server.Stats()
+			assert.Equal(t, testCase.stats, stats.RetentionStats, "expected the counters")
+			assert.Equal(t, map[string]uint64{http.MethodGet: 5}, stats.ByMethod, "expected all requests by method")
+			assert.Equal(t, map[string]uint64{"/x": 5}, stats.ByURI, "expected all requests by uri")
+		})
+	}
+}
+
+// TestRetentionErrors - tests the errors limit and the invalid policies
+func TestRetentionErrors(t *testing.T) {
+
+	server, err := gotesthttp.NewServerE(&gotesthttp.Configuration{
+		Host:      "localhost",
+		Responses: map[string][]gotesthttp.Endpoint{"default": {newTextEndpoint("/x", "ok")}},
+		Retention: &gotesthttp.Retention{MaxErrors: 1},
+	})
+	if !assert.NoError(t, err, "expected no error") {
+		return
+	}
+
+	defer server.Close()
+
+	for _, uri := range []string{"/a", "/b", "/c"} {
+		res := server.DoRequest(&gotesthttp.Request{URI: uri, Method: http.MethodGet})
+		res.Body.Close()
+	}
+
+	errors := server.GetErrors()
+	if assert.Len(t, errors, 1, "expected only the last error") {
+		assert.Contains(t, errors[0].Error(), "/c", "expected the last error")
+	}
+
+	stats := server.Stats()
+	assert.Equal(t, uint64(3), stats.Errors, "expected all errors counted")
+	assert.Equal(t, uint64(2), stats.ErrorsEvicted, "expected the evicted errors")
+
+	_, err = gotesthttp.NewServerWithOptions(t,
+		gotesthttp.WithEndpoints("default", newTextEndpoint("/x", "ok")),
+		gotesthttp.WithRetention(&gotesthttp.Retention{SampleRate: -1}),
+	)
+	assert.Error(t, err, "expected the invalid sample rate")
+}
+
+// TestRequestMetadata - tests the connection, protocol, timing and routing metadata in the journal
+func TestRequestMetadata(t *testing.T) {
+
+	server, err := gotesthttp.NewServerWithOptions(t,
+		gotesthttp.WithEndpoints("default", gotesthttp.Endpoint{
+			URI:    "/items/[0-9]+",
+			Regexp: true,
+			Methods: map[string]gotesthttp.Response{
+				http.MethodPost: {Status: http.StatusOK, Wait: 20 * time.Millisecond},
+			},
+		}),
+	)
+	if !assert.NoError(t, err, "expected no error") {
+		return
+	}
+
+	client := &http.Client{}
+
+	for i := 0; i < 2; i++ {
+
+		req, err := http.NewRequest(http.MethodPost, "http://"+server.Address()+"/items/"+strconv.Itoa(i), io.MultiReader(strings.NewReader("chunked body")))
+		if !assert.NoError(t, err, "expected no error creating the request") {
+			return
+		}
+
+		req.Host = "virtual.test"
+		req.Trailer = http.Header{"X-Checksum": {"abc"}}
+
+		res, err := client.Do(req)
+		if !assert.NoError(t, err, "expected no error") {
+			return
+		}
+
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+	}
+
+	requests := server.Requests()
+	if !assert.Len(t, requests, 2, "expected the requests") {
+		return
+	}
+
+	for i, request := range requests {
+
+		assert.Equal(t, uint64(i+1), request.Sequence, "expected the sequence")
+		assert.Equal(t, "virtual.test", request.Host, "expected the host header")
+		assert.Equal(t, "HTTP/1.1", request.Proto, "expected the protocol")
+		assert.Equal(t, int64(-1), request.ContentLength, "expected the unknown length")
+		assert.Equal(t, []string{"chunked"}, request.TransferEncoding, "expected the transfer encoding")
+		assert.Equal(t, "abc", request.Trailers.Get("X-Checksum"), "expected the trailer")
+		assert.Equal(t, "chunked body", string(request.Body), "expected the body")
+		assert.Nil(t, request.TLS, "expected no tls")
+		assert.Equal(t, "/items/[0-9]+", request.Endpoint, "expected the endpoint regexp")
+		assert.Equal(t, "default", request.Mode, "expected the mode")
+		assert.GreaterOrEqual(t, request.Responded.Sub(request.Received), 20*time.Millisecond, "expected the latency")
+	}
+
+	assert.NotEmpty(t, requests[0].RemoteAddr, "expected the remote address")
+	assert.Equal(t, requests[0].RemoteAddr, requests[1].RemoteAddr, "expected the connection reused")
+
+	res := server.DoRequest(&gotesthttp.Request{URI: "/items/3", Method: http.MethodPost, Host: "other.test", Body: []byte("12")})
+	res.Body.Close()
+
+	request := server.TakeRequest(func(request *gotesthttp.Request) bool { return request.Sequence == 3 })
+	if assert.NotNil(t, request, "expected the third request") {
+		assert.Equal(t, "other.test", request.Host, "expected the host sent by DoRequest")
+		assert.Equal(t, int64(2), request.ContentLength, "expected the content length")
+		assert.Empty(t, request.TransferEncoding, "expected no transfer encoding")
+	}
+}
+
+// TestInFlightRequests - tests the requests recorded before the delay and listed while waiting
+func TestInFlightRequests(t *testing.T) {
+
+	server, err := gotesthttp.NewServerWithOptions(t,
+		gotesthttp.WithEndpoints("default", gotesthttp.Endpoint{
+			URI: "/slow",
+			Methods: map[string]gotesthttp.Response{
+				http.MethodGet: {Status: http.StatusOK, Wait: 300 * time.Millisecond},
+			},
+		}),
+	)
+	if !assert.NoError(t, err, "expected no error") {
+		return
+	}
+
+	done := make(chan int)
+
+	go func() {
+		res := server.DoRequest(&gotesthttp.Request{URI: "/slow", Method: http.MethodGet, Tag: "waiting"})
+		res.Body.Close()
+		done <- res.StatusCode
+	}()
+
+	assert.Eventually(t, func() bool {
+		return len(server.InFlight()) == 1
+	}, time.Second, 5*time.Millisecond, "expected the request in flight")
+
+	inFlight := server.InFlight(gotesthttp.ByTag("waiting"))
+	if assert.Len(t, inFlight, 1, "expected the tagged request in flight") {
+		assert.True(t, inFlight[0].Responded.IsZero(), "expected no response yet")
+	}
+
+	requests := server.Requests()
+	if assert.Len(t, requests, 1, "expected the request recorded before the delay") {
+		assert.True(t, requests[0].Responded.IsZero(), "expected no response yet")
+	}
+
+	assert.Equal(t, http.StatusOK, <-done, "expected the response")
+	assert.Empty(t, server.InFlight(), "expected no requests in flight")
+
+	requests = server.Requests()
+	if assert.Len(t, requests, 1, "expected the request") {
+		assert.False(t, requests[0].Responded.IsZero(), "expected the response time")
+		assert.False(t, requests[0].Aborted, "expected the request answered")
+	}
+}
+
+// TestInFlightFunc - tests the requests blocked by the response function listed in flight
+func TestInFlightFunc(t *testing.T) {
+
+	release := make(chan struct{})
+
+	server, err := gotesthttp.NewServerWithOptions(t,
+		gotesthttp.WithEndpoints("default", gotesthttp.Endpoint{
+			URI: "/blocking",
+			Methods: map[string]gotesthttp.Response{
+				http.MethodGet: {Func: func(request *gotesthttp.Request) gotesthttp.Response {
+					<-release
+					return gotesthttp.Response{Status: http.StatusAccepted}
+				}},
+			},
+		}),
+	)
+	if !assert.NoError(t, err, "expected no error") {
+		return
+	}
+
+	done := make(chan int)
+
+	go func() {
+		res := server.DoRequest(&gotesthttp.Request{URI: "/blocking", Method: http.MethodGet})
+		res.Body.Close()
+		done <- res.StatusCode
+	}()
+
+	assert.Eventually(t, func() bool {
+		return len(server.InFlight()) == 1
+	}, time.Second, 5*time.Millisecond, "expected the blocked request in flight")
+
+	close(release)
+
+	assert.Equal(t, http.StatusAccepted, <-done, "expected the function response")
+	assert.Empty(t, server.InFlight(), "expected no requests in flight")
+
+	requests := server.Requests()
+	if assert.Len(t, requests, 1, "expected the request recorded once") {
+		assert.False(t, requests[0].Responded.IsZero(), "expected the response time")
+	}
+}
+
+// TestAbortedRequests - tests the delay interrupted by the client cancellation
+func TestAbortedRequests(t *testing.T) {
+
+	server, err := gotesthttp.NewServerWithOptions(t,
+		gotesthttp.WithEndpoints("default", gotesthttp.Endpoint{
+			URI: "/slow",
+			Methods: map[string]gotesthttp.Response{
+				http.MethodGet: {Status: http.StatusOK, Wait: time.Minute},
+			},
+		}),
+	)
+	if !assert.NoError(t, err, "expected no error") {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+server.Address()+"/slow", nil)
+	if !assert.NoError(t, err, "expected no error creating the request") {
+		return
+	}
+
+	_, err = http.DefaultClient.Do(req)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "expected the client timeout")
+
+	assert.Eventually(t, func() bool {
+		return len(server.Requests(gotesthttp.ByAborted(true))) == 1
+	}, time.Second, 5*time.Millisecond, "expected the aborted request")
+
+	assert.Empty(t, server.InFlight(), "expected the wait stopped")
+
+	request := server.TakeRequest()
+	if assert.NotNil(t, request, "expected the request") {
+		assert.True(t, request.Responded.IsZero(), "expected no response")
+	}
+}
@@ -0,0 +1,65 @@
+package http
+
+import "context"
+
+/**
+* Functions to simulate outages: pause, restart and graceful shutdown.
+* @author rnojiri
+**/
+
+// Pause - keeps the address bound but holds the new connections and the requests until resumed
+func (hs *Server) Pause() {
+
+	hs.gate.Pause()
+}
+
+// Resume - answers the held connections and requests
+func (hs *Server) Resume() {
+
+	hs.gate.Resume()
+}
+
+// Paused - returns if the server is paused
+func (hs *Server) Paused() bool {
+
+	return hs.gate.Paused()
+}
+
+// Restart - closes all connections and listens again on the same address keeping the endpoints
+// and the journal, also after Close or Shutdown, the requests in flight are aborted and a paused
+// server is resumed
+func (hs *Server) Restart() error {
+
+	server := hs.httpServer()
+	server.CloseClientConnections()
+
+	hs.gate.Resume()
+	server.Close()
+
+	// the webhooks were cancelled by Close
+	hs.configMutex.Lock()
+	if hs.webhooks.ctx.Err() != nil {
+		hs.webhooks = newWebhookSender()
+	}
+	hs.configMutex.Unlock()
+
+	return hs.listen()
+}
+
+// Shutdown - stops accepting connections and waits the requests in flight until the context is done
+// (the remaining ones are aborted), then closes the server like Close, a paused server is resumed
+func (hs *Server) Shutdown(ctx context.Context) error {
+
+	hs.gate.Resume()
+
+	server := hs.httpServer()
+
+	err := server.Config.Shutdown(ctx)
+	if err != nil {
+		server.CloseClientConnections()
+	}
+
+	hs.Close()
+
+	return err
+}
@@ -0,0 +1,189 @@
+package http_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	gotesthttp "github.com/rnojiri/gotest/http"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* The tests for the pause, restart and graceful shutdown.
+* @author rnojiri
+**/
+
+// newSlowServer - creates a server with a fast and a slow endpoint
+func newSlowServer(t *testing.T, wait time.Duration) *gotesthttp.Server {
+
+	server, err := gotesthttp.NewServerWithOptions(t,
+		gotesthttp.WithEndpoints("default",
+			newTextEndpoint("/fast", "ok"),
+			gotesthttp.Endpoint{
+				URI: "/slow",
+				Methods: map[string]gotesthttp.Response{
+					http.MethodGet: {Status: http.StatusOK, Wait: wait},
+				},
+			},
+		),
+	)
+	if err != nil {
+		t.Fatalf("error creating the server: %v", err)
+	}
+
+	return server
+}
+
+// getAsync - does a GET in background returning the status (zero on errors)
+func getAsync(server *gotesthttp.Server, uri string) <-chan int {
+
+	done := make(chan int, 1)
+
+	go func() {
+		res, err := http.Get("http://" + server.Address() + uri)
+		if err != nil {
+			done <- 0
+			return
+		}
+		res.Body.Close()
+		done <- res.StatusCode
+	}()
+
+	return done
+}
+
+// TestPauseResume - tests the requests held while paused
+func TestPauseResume(t *testing.T) {
+
+	server := newSlowServer(t, 0)
+
+	server.Pause()
+	assert.True(t, server.Paused(), "expected the server paused")
+
+	done := getAsync(server, "/fast")
+
+	select {
+	case <-done:
+		assert.Fail(t, "expected no response while paused")
+		return
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	assert.Empty(t, server.Requests(), "expected no recorded requests while paused")
+
+	server.Resume()
+	assert.False(t, server.Paused(), "expected the server resumed")
+
+	assert.Equal(t, http.StatusOK, <-done, "expected the held request answered")
+	assert.Len(t, server.Requests(), 1, "expected the request recorded")
+}
+
+// TestRestart - tests listening again on the same address keeping the endpoints and the journal
+func TestRestart(t *testing.T) {
+
+	server := newSlowServer(t, time.Minute)
+
+	address := server.Address()
+
+	status, _ := doGet(t, server, "/fast")
+	assert.Equal(t, http.StatusOK, status, "expected the response before the restart")
+
+	slow := getAsync(server, "/slow")
+
+	assert.Eventually(t, func() bool {
+		return len(server.InFlight()) == 1
+	}, time.Second, 5*time.Millisecond, "expected the slow request in flight")
+
+	if !assert.NoError(t, server.Restart(), "expected no error restarting") {
+		return
+	}
+
+	assert.Equal(t, 0, <-slow, "expected the slow request interrupted")
+	assert.Equal(t, address, server.Address(), "expected the same address")
+
+	status, _ = doGet(t, server, "/fast")
+	assert.Equal(t, http.StatusOK, status, "expected the response after the restart")
+
+	assert.Eventually(t, func() bool {
+		return len(server.Requests(gotesthttp.ByAborted(true))) == 1
+	}, time.Second, 5*time.Millisecond, "expected the slow request aborted")
+
+	assert.Len(t, server.Requests(), 3, "expected the journal kept")
+}
+
+// TestShutdown - tests waiting the requests in flight
+func TestShutdown(t *testing.T) {
+
+	server := newSlowServer(t, 200*time.Millisecond)
+
+	slow := getAsync(server, "/slow")
+
+	assert.Eventually(t, func() bool {
+		return len(server.InFlight()) == 1
+	}, time.Second, 5*time.Millisecond, "expected the slow request in flight")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	assert.NoError(t, server.Shutdown(ctx), "expected no error shutting down")
+	assert.Equal(t, http.StatusOK, <-slow, "expected the request in flight answered")
+
+	_, err := http.Get("http://" + server.Address() + "/fast")
+	assert.Error(t, err, "expected the server closed")
+}
+
+// TestShutdownTimeout - tests aborting the requests in flight after the context is done
+func TestShutdownTimeout(t *testing.T) {
+
+	server := newSlowServer(t, time.Minute)
+
+	slow := getAsync(server, "/slow")
+
+	assert.Eventually(t, func() bool {
+		return len(server.InFlight()) == 1
+	}, time.Second, 5*time.Millisecond, "expected the slow request in flight")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, server.Shutdown(ctx), context.DeadlineExceeded, "expected the timeout")
+	assert.Equal(t, 0, <-slow, "expected the request interrupted")
+	assert.Len(t, server.Requests(gotesthttp.ByAborted(true)), 1, "expected the request aborted")
+}
+
+// TestRestartAfterClose - tests the webhooks sent again after restarting a closed server
+func TestRestartAfterClose(t *testing.T) {
+
+	receiver := newSlowServer(t, 0)
+
+	sender, err := gotesthttp.NewServerWithOptions(t, gotesthttp.WithEndpoints("default", gotesthttp.Endpoint{
+		URI: "/trigger",
+		Methods: map[string]gotesthttp.Response{
+			http.MethodPost: {
+				Status:   http.StatusAccepted,
+				Webhooks: []gotesthttp.Webhook{{URL: "http://" + receiver.Address() + "/fast", Method: http.MethodGet}},
+			},
+		},
+	}))
+	if err != nil {
+		t.Fatalf("error creating the server: %v", err)
+	}
+
+	sender.Close()
+
+	if !assert.NoError(t, sender.Restart(), "expected no error restarting the closed server") {
+		return
+	}
+
+	res := sender.DoRequest(&gotesthttp.Request{URI: "/trigger", Method: http.MethodPost})
+	res.Body.Close()
+
+	assert.Equal(t, http.StatusAccepted, res.StatusCode, "expected the response after the restart")
+
+	deliveries := sender.WaitForWebhookDeliveries(1, time.Second)
+	if assert.Len(t, deliveries, 1, "expected the delivery") {
+		assert.True(t, deliveries[0].Delivered, "expected the webhook delivered after the restart")
+	}
+}
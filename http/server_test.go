@@ -1,8 +1,11 @@
 package http_test
 
 import (
+	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -316,3 +319,44 @@ func TestURIRegexp(t *testing.T) {
 	serverRequest = gotesthttp.WaitForServerRequest(server, time.Second, 10*time.Second)
 	compareRequests(t, clientRequest2, serverRequest)
 }
+
+// TestUnixSocket - tests the server listening on unix sockets, including the abstract namespace
+func TestUnixSocket(t *testing.T) {
+
+	socketPaths := []string{
+		filepath.Join(t.TempDir(), "http.sock"),
+		fmt.Sprintf("@gotest-http-%d", os.Getpid()),
+	}
+
+	for _, socketPath := range socketPaths {
+
+		endpoint := createDummyEndpoint(http.MethodGet)
+
+		server := gotesthttp.NewServer(&gotesthttp.Configuration{
+			SocketPath: socketPath,
+			T:          t,
+			Responses:  map[string][]gotesthttp.Endpoint{"default": {endpoint}},
+		})
+
+		clientRequest := createRequestFromEndpoint(http.MethodGet, &endpoint)
+
+		serverResponse := server.DoRequest(clientRequest)
+		compareResponses(t, endpoint.Methods[http.MethodGet], serverResponse)
+
+		res, err := gotesthttp.UnixSocketClient(socketPath).Get("http://localhost" + endpoint.URI)
+		if assert.NoError(t, err, "expected no error using the unix client") {
+			res.Body.Close()
+			assert.Equal(t, http.StatusOK, res.StatusCode, "expected ok")
+		}
+
+		assert.Len(t, server.Requests(), 2, "expected the requests")
+		assert.Equal(t, socketPath, server.Address(), "expected the socket path as address")
+
+		server.Close()
+
+		if !strings.HasPrefix(socketPath, "@") {
+			_, err = os.Stat(socketPath)
+			assert.True(t, os.IsNotExist(err), "expected the socket file removed")
+		}
+	}
+}
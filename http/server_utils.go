@@ -2,9 +2,12 @@ package http
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 )
@@ -14,41 +17,100 @@ import (
 * @author rnojiri
 **/
 
+const (
+	clientTimeout  = 10 * time.Second
+	unixSocketHost = "unix"
+)
+
 // DoRequest - does a request
 func (hs *Server) DoRequest(request *Request) *http.Response {
 
-	transportCore := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
+	var client *http.Client
+	var url string
+
+	if hs.configuration.SocketPath != "" {
+		client = UnixSocketClient(hs.configuration.SocketPath)
+		url = fmt.Sprintf("http://%s/%s", unixSocketHost, request.URI)
+	} else {
+		scheme := "http"
+		tlsConfig := &tls.Config{InsecureSkipVerify: true}
+
+		if hs.configuration.TLS {
+			scheme = "https"
+			if request.Host != "" {
+				tlsConfig.ServerName = hostname(request.Host)
+			}
+		}
 
-	client := &http.Client{
-		Transport: transportCore,
-		Timeout:   10 * time.Second,
+		client = &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: tlsConfig,
+			},
+			Timeout: clientTimeout,
+		}
+		url = fmt.Sprintf("%s://%s:%d/%s", scheme, hs.configuration.Host, hs.configuration.Port, request.URI)
 	}
 
-	req, err := http.NewRequest(
-		request.Method,
-		fmt.Sprintf("http://%s:%d/%s", hs.configuration.Host, hs.configuration.Port, request.URI),
-		bytes.NewBuffer(request.Body),
-	)
+	req, err := http.NewRequest(request.Method, url, bytes.NewBuffer(request.Body))
 	if err != nil {
-		hs.configuration.T.Fatalf("error creating a new request: %v", err)
+		hs.clientFail("error creating a new request: %v", err)
+	}
+
+	req.Header = request.Headers.Clone()
+	if req.Header == nil {
+		req.Header = http.Header{}
 	}
 
-	req.Header = request.Headers
+	if request.Host != "" {
+		req.Host = request.Host
+	}
+
+	if request.Mode != "" {
+		req.Header.Set(ModeHeader, request.Mode)
+	}
+
+	if request.Tag != "" {
+		req.Header.Set(TagHeader, request.Tag)
+	}
 
 	res, err := client.Do(req)
 	if err != nil {
-		hs.configuration.T.Fatalf("error executing request: %v", err)
+		hs.clientFail("error executing request: %v", err)
 	}
 
 	return res
 }
 
-// WaitForServerRequest - wait until timeout or for the server sets the request in the channel
-func WaitForServerRequest(server *Server, waitFor, maxRequestTimeout time.Duration) *Request {
+// UnixSocketClient - creates a client sending all requests to the unix socket (any host can be used in the urls)
+func UnixSocketClient(socketPath string) *http.Client {
 
-	r := server.FirstRequest()
+	dialer := &net.Dialer{}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", socketPath)
+			},
+		},
+		Timeout: clientTimeout,
+	}
+}
+
+// clientFail - fails the test or panics when there is no test configured
+func (hs *Server) clientFail(format string, args ...interface{}) {
+
+	if hs.configuration.T != nil {
+		hs.configuration.T.Fatalf(format, args...)
+	}
+
+	panic(fmt.Errorf(format, args...))
+}
+
+// WaitForServerRequest - wait until timeout or for the server sets the request in the channel,
+// only the requests matching all filters are considered
+func WaitForServerRequest(server *Server, waitFor, maxRequestTimeout time.Duration, filters ...RequestFilter) *Request {
+
+	r := server.TakeRequest(filters...)
 
 	if r != nil {
 		return r
@@ -63,7 +125,7 @@ func WaitForServerRequest(server *Server, waitFor, maxRequestTimeout time.Durati
 			break
 		}
 
-		r := server.FirstRequest()
+		r := server.TakeRequest(filters...)
 
 		if r != nil {
 			return r
@@ -73,6 +135,16 @@ func WaitForServerRequest(server *Server, waitFor, maxRequestTimeout time.Durati
 	return nil
 }
 
+// Query - parses the query string of the request uri
+func (r *Request) Query() url.Values {
+
+	_, rawQuery, _ := strings.Cut(r.URI, "?")
+
+	values, _ := url.ParseQuery(rawQuery)
+
+	return values
+}
+
 // AddHeaders - copy all the headers
 func AddHeaders(dest http.Header, source http.Header) {
 
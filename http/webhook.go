@@ -0,0 +1,405 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+/**
+* Outbound webhook callbacks sent after answering a request.
+* @author rnojiri
+**/
+
+// The signature algorithms.
+const (
+	SignatureSHA1   string = "sha1"
+	SignatureSHA256 string = "sha256"
+	SignatureSHA512 string = "sha512"
+)
+
+const (
+	defaultWebhookRetryInterval = 100 * time.Millisecond
+	webhookTimeout              = 10 * time.Second
+)
+
+// Webhook - a callback sent after the response, the URL and a string Body are templates
+// executed with the WebhookTemplateData, like {{.Request.Query.Get "id"}} or {{.JSON.callback_url}}
+type Webhook struct {
+	// URL - the target url template
+	URL string
+	// Method - the http method (POST when empty)
+	Method string
+	// Delay - the time to wait after the response
+	Delay time.Duration
+	// Body - the body, encoded like the response bodies (string bodies are templates)
+	Body interface{}
+	// Headers - the headers sent
+	Headers http.Header
+	// Signature - signs the body using HMAC
+	Signature *WebhookSignature
+	// Retries - the retries after a failed attempt (a transport error or a status that is not 2xx)
+	Retries int
+	// RetryInterval - the time between the attempts (100ms when zero)
+	RetryInterval time.Duration
+}
+
+// WebhookSignature - the HMAC signature of the body
+type WebhookSignature struct {
+	// Header - the header containing the signature
+	Header string
+	// Secret - the HMAC secret
+	Secret string
+	// Algorithm - SignatureSHA1, SignatureSHA256 or SignatureSHA512 (SignatureSHA256 when empty)
+	Algorithm string
+	// Prefix - added before the hex encoded signature, like "sha256="
+	Prefix string
+}
+
+// WebhookTemplateData - the data used by the webhook templates
+type WebhookTemplateData struct {
+	// Request - the request triggering the webhook
+	Request *Request
+	// JSON - the decoded json body of the request (nil when it is not json)
+	JSON interface{}
+}
+
+// WebhookAttempt - the result of an attempt to deliver a webhook
+type WebhookAttempt struct {
+	// Status - the status answered (zero on errors)
+	Status int
+	// Error - the transport error
+	Error string
+	// Time - when the attempt was made
+	Time time.Time
+}
+
+// WebhookDelivery - the delivery of a webhook
+type WebhookDelivery struct {
+	// TriggeredBy - the uri of the request triggering the webhook
+	TriggeredBy string
+	URL         string
+	Method      string
+	Body        []byte
+	Headers     http.Header
+	// Attempts - all attempts in order
+	Attempts []WebhookAttempt
+	// Delivered - the last attempt succeeded
+	Delivered bool
+	// Error - an error preparing the webhook (no attempts are made)
+	Error string
+}
+
+// webhookSender - sends the webhooks in the background
+type webhookSender struct {
+	client    *http.Client
+	ctx       context.Context
+	cancel    context.CancelFunc
+	waitGroup sync.WaitGroup
+}
+
+// newWebhookSender - creates the sender
+func newWebhookSender() *webhookSender {
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &webhookSender{
+		client: &http.Client{Timeout: webhookTimeout},
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// close - cancels the pending webhooks and waits for them
+func (ws *webhookSender) close() {
+
+	ws.cancel()
+	ws.waitGroup.Wait()
+}
+
+// sleep - waits the duration or until the sender is closed
+func (ws *webhookSender) sleep(duration time.Duration) bool {
+
+	if duration <= 0 {
+		return ws.ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ws.ctx.Done():
+		return false
+	}
+}
+
+// triggerWebhooks - sends the webhooks of the response in the background
+func (hs *Server) triggerWebhooks(request *Request, webhooks []Webhook) {
+
+	if len(webhooks) == 0 {
+		return
+	}
+
+	data := &WebhookTemplateData{Request: copyRequest(request)}
+
+	var document interface{}
+	if json.Unmarshal(request.Body, &document) == nil {
+		data.JSON = document
+	}
+
+	sender := hs.webhookSender()
+
+	for i := range webhooks {
+
+		webhook := webhooks[i]
+
+		sender.waitGroup.Add(1)
+
+		go func() {
+			defer sender.waitGroup.Done()
+
+			delivery := hs.deliverWebhook(sender, &webhook, data)
+
+			hs.mutex.Lock()
+			defer hs.mutex.Unlock()
+
+			hs.deliveries = append(hs.deliveries, *delivery)
+		}()
+	}
+}
+
+// deliverWebhook - prepares and sends the webhook retrying on failures
+func (hs *Server) deliverWebhook(sender *webhookSender, webhook *Webhook, data *WebhookTemplateData) *WebhookDelivery {
+
+	delivery := &WebhookDelivery{
+		TriggeredBy: data.Request.URI,
+		Method:      webhook.Method,
+		Headers:     webhook.Headers.Clone(),
+		Attempts:    []WebhookAttempt{},
+	}
+
+	if delivery.Method == "" {
+		delivery.Method = http.MethodPost
+	}
+
+	if delivery.Headers == nil {
+		delivery.Headers = http.Header{}
+	}
+
+	var err error
+
+	delivery.URL, err = executeWebhookTemplate("url", webhook.URL, data)
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+
+	delivery.Body, err = webhookBody(webhook.Body, data, delivery.Headers)
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+
+	if webhook.Signature != nil {
+		signature, err := signWebhook(webhook.Signature, delivery.Body)
+		if err != nil {
+			delivery.Error = err.Error()
+			return delivery
+		}
+
+		delivery.Headers.Set(webhook.Signature.Header, signature)
+	}
+
+	if !sender.sleep(webhook.Delay) {
+		delivery.Error = "the server was closed before the delivery"
+		return delivery
+	}
+
+	interval := webhook.RetryInterval
+	if interval == 0 {
+		interval = defaultWebhookRetryInterval
+	}
+
+	for attempt := 0; attempt <= webhook.Retries; attempt++ {
+
+		if attempt > 0 && !sender.sleep(interval) {
+			break
+		}
+
+		result := sender.send(delivery)
+		delivery.Attempts = append(delivery.Attempts, result)
+
+		if result.Error == "" && result.Status >= 200 && result.Status < 300 {
+			delivery.Delivered = true
+			break
+		}
+	}
+
+	return delivery
+}
+
+// send - makes a single attempt
+func (ws *webhookSender) send(delivery *WebhookDelivery) WebhookAttempt {
+
+	attempt := WebhookAttempt{Time: time.Now()}
+
+	req, err := http.NewRequestWithContext(ws.ctx, delivery.Method, delivery.URL, bytes.NewReader(delivery.Body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+
+	req.Header = delivery.Headers.Clone()
+
+	res, err := ws.client.Do(req)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+
+	res.Body.Close()
+	attempt.Status = res.StatusCode
+
+	return attempt
+}
+
+// executeWebhookTemplate - executes a template with the request data
+func executeWebhookTemplate(name, text string, data *WebhookTemplateData) (string, error) {
+
+	tmpl, err := template.New(name).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid webhook %s template: %w", name, err)
+	}
+
+	buffer := strings.Builder{}
+	if err := tmpl.Execute(&buffer, data); err != nil {
+		return "", fmt.Errorf("error executing the webhook %s template: %w", name, err)
+	}
+
+	return buffer.String(), nil
+}
+
+// webhookBody - encodes the body setting the inferred content type when none is configured
+func webhookBody(body interface{}, data *WebhookTemplateData, headers http.Header) ([]byte, error) {
+
+	if body == nil {
+		return nil, nil
+	}
+
+	if text, ok := body.(string); ok {
+
+		rendered, err := executeWebhookTemplate("body", text, data)
+		if err != nil {
+			return nil, err
+		}
+
+		body = rendered
+	}
+
+	encoded, err := encodeBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := bytes.Buffer{}
+	if err := encoded.write(&buffer); err != nil {
+		return nil, err
+	}
+
+	if encoded.contentType != "" && headers.Get(contentTypeHeader) == "" {
+		headers.Set(contentTypeHeader, encoded.contentType)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// signatureHash - returns the hash function of the algorithm
+func signatureHash(algorithm string) (func() hash.Hash, error) {
+
+	switch algorithm {
+	case SignatureSHA1:
+		return sha1.New, nil
+	case SignatureSHA256, "":
+		return sha256.New, nil
+	case SignatureSHA512:
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("unknown signature algorithm: %s", algorithm)
+	}
+}
+
+// signWebhook - returns the prefixed hex encoded HMAC of the body
+func signWebhook(signature *WebhookSignature, body []byte) (string, error) {
+
+	hashFunc, err := signatureHash(signature.Algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(hashFunc, []byte(signature.Secret))
+	mac.Write(body)
+
+	return signature.Prefix + hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// WebhookDeliveries - returns a copy of the finished webhook deliveries
+func (hs *Server) WebhookDeliveries() []WebhookDelivery {
+
+	hs.mutex.Lock()
+	defer hs.mutex.Unlock()
+
+	return append([]WebhookDelivery{}, hs.deliveries...)
+}
+
+// WaitForWebhookDeliveries - waits until the number of finished deliveries is reached or the timeout expires
+func (hs *Server) WaitForWebhookDeliveries(count int, timeout time.Duration) []WebhookDelivery {
+
+	deadline := time.Now().Add(timeout)
+
+	for {
+		deliveries := hs.WebhookDeliveries()
+		if len(deliveries) >= count || time.Now().After(deadline) {
+			return deliveries
+		}
+
+		<-time.After(10 * time.Millisecond)
+	}
+}
+
+// validateWebhook - checks the webhook url and signature
+func validateWebhook(webhook *Webhook) error {
+
+	if webhook.URL == "" {
+		return fmt.Errorf("empty webhook url")
+	}
+
+	if webhook.Retries < 0 {
+		return fmt.Errorf("invalid webhook retries: %d", webhook.Retries)
+	}
+
+	if webhook.Signature == nil {
+		return nil
+	}
+
+	if webhook.Signature.Header == "" {
+		return fmt.Errorf("empty webhook signature header")
+	}
+
+	_, err := signatureHash(webhook.Signature.Algorithm)
+
+	return err
+}
@@ -0,0 +1,213 @@
+package http_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gotesthttp "github.com/rnojiri/gotest/http"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* The tests for the outbound webhooks.
+* @author rnojiri
+**/
+
+// newWebhookReceiver - creates a server receiving the callbacks, failing the first attempts
+func newWebhookReceiver(t *testing.T, failures int32) *gotesthttp.Server {
+
+	var received int32
+
+	return gotesthttp.NewServer(&gotesthttp.Configuration{
+		Host: "localhost",
+		T:    t,
+		Responses: map[string][]gotesthttp.Endpoint{
+			"default": {
+				{
+					URI: "/callback",
+					Methods: map[string]gotesthttp.Response{
+						http.MethodPost: {
+							Func: func(request *gotesthttp.Request) gotesthttp.Response {
+								if atomic.AddInt32(&received, 1) <= failures {
+									return gotesthttp.Response{Status: http.StatusServiceUnavailable}
+								}
+								return gotesthttp.Response{Status: http.StatusNoContent}
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+}
+
+// TestWebhooks - tests the templated, signed and retried callbacks
+func TestWebhooks(t *testing.T) {
+
+	receiver := newWebhookReceiver(t, 1)
+	defer receiver.Close()
+
+	defaultConf.T = t
+	defaultConf.Responses = map[string][]gotesthttp.Endpoint{
+		"default": {
+			{
+				URI: "/payments",
+				Methods: map[string]gotesthttp.Response{
+					http.MethodPost: {
+						Status: http.StatusAccepted,
+						Webhooks: []gotesthttp.Webhook{
+							{
+								URL:     "{{.JSON.callback}}",
+								Delay:   20 * time.Millisecond,
+								Body:    `{"id":"{{.JSON.id}}","status":"paid"}`,
+								Headers: http.Header{"Content-Type": {"application/json"}},
+								Signature: &gotesthttp.WebhookSignature{
+									Header: "X-Signature",
+									Secret: "secret",
+									Prefix: "sha256=",
+								},
+								Retries:       2,
+								RetryInterval: 10 * time.Millisecond,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	server := gotesthttp.NewServer(&defaultConf)
+	defer server.Close()
+
+	res := server.DoRequest(&gotesthttp.Request{
+		URI:    "/payments",
+		Method: http.MethodPost,
+		Body:   []byte(`{"id":"p1","callback":"http://` + receiver.Address() + `/callback"}`),
+	})
+	res.Body.Close()
+
+	assert.Equal(t, http.StatusAccepted, res.StatusCode, "expected the response before the webhook")
+
+	deliveries := server.WaitForWebhookDeliveries(1, 2*time.Second)
+	if !assert.Len(t, deliveries, 1, "expected the delivery") {
+		return
+	}
+
+	delivery := deliveries[0]
+
+	assert.True(t, delivery.Delivered, "expected the delivery to succeed")
+	assert.Equal(t, "/payments", delivery.TriggeredBy, "expected the triggering request")
+	assert.Equal(t, "http://"+receiver.Address()+"/callback", delivery.URL, "expected the templated url")
+	assert.Equal(t, `{"id":"p1","status":"paid"}`, string(delivery.Body), "expected the templated body")
+
+	if assert.Len(t, delivery.Attempts, 2, "expected a retry") {
+		assert.Equal(t, http.StatusServiceUnavailable, delivery.Attempts[0].Status, "expected the failed attempt")
+		assert.Equal(t, http.StatusNoContent, delivery.Attempts[1].Status, "expected the successful attempt")
+	}
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(delivery.Body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	requests := receiver.Requests()
+	if assert.Len(t, requests, 2, "expected both attempts in the receiver") {
+		assert.Equal(t, signature, requests[1].Headers.Get("X-Signature"), "expected the signature")
+		assert.Equal(t, "application/json", requests[1].Headers.Get("Content-Type"), "expected the header")
+		assert.Equal(t, delivery.Body, requests[1].Body, "expected the body")
+	}
+}
+
+// TestWebhookFailures - tests the deliveries failing after all retries and the invalid templates
+func TestWebhookFailures(t *testing.T) {
+
+	receiver := newWebhookReceiver(t, 10)
+	defer receiver.Close()
+
+	defaultConf.T = t
+	defaultConf.Responses = map[string][]gotesthttp.Endpoint{
+		"default": {
+			{
+				URI: "/orders",
+				Methods: map[string]gotesthttp.Response{
+					http.MethodPost: {
+						Status: http.StatusOK,
+						Webhooks: []gotesthttp.Webhook{
+							{
+								URL:           "http://" + receiver.Address() + "/callback",
+								Body:          map[string]string{"order": "o1"},
+								Retries:       1,
+								RetryInterval: time.Millisecond,
+							},
+							{
+								URL: "{{.Invalid",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	server := gotesthttp.NewServer(&defaultConf)
+	defer server.Close()
+
+	res := server.DoRequest(&gotesthttp.Request{URI: "/orders", Method: http.MethodPost})
+	res.Body.Close()
+
+	deliveries := server.WaitForWebhookDeliveries(2, 2*time.Second)
+	if !assert.Len(t, deliveries, 2, "expected the deliveries") {
+		return
+	}
+
+	for _, delivery := range deliveries {
+
+		assert.False(t, delivery.Delivered, "expected the delivery to fail")
+
+		if delivery.Error != "" {
+			assert.Empty(t, delivery.Attempts, "expected no attempts with an invalid template")
+			continue
+		}
+
+		assert.Len(t, delivery.Attempts, 2, "expected all attempts")
+		assert.Equal(t, `{"order":"o1"}`, string(delivery.Body), "expected the json body")
+		assert.Equal(t, "application/json", delivery.Headers.Get("Content-Type"), "expected the inferred content type")
+	}
+}
+
+// TestWebhookCanceledOnClose - tests the pending webhooks are canceled when the server closes
+func TestWebhookCanceledOnClose(t *testing.T) {
+
+	defaultConf.T = t
+	defaultConf.Responses = map[string][]gotesthttp.Endpoint{
+		"default": {
+			{
+				URI: "/slow",
+				Methods: map[string]gotesthttp.Response{
+					http.MethodGet: {
+						Status:   http.StatusOK,
+						Webhooks: []gotesthttp.Webhook{{URL: "http://localhost:1/never", Delay: time.Hour}},
+					},
+				},
+			},
+		},
+	}
+
+	server := gotesthttp.NewServer(&defaultConf)
+
+	res := server.DoRequest(&gotesthttp.Request{URI: "/slow", Method: http.MethodGet})
+	res.Body.Close()
+
+	start := time.Now()
+	server.Close()
+
+	assert.Less(t, time.Since(start), time.Second, "expected the close to cancel the webhook")
+
+	if deliveries := server.WebhookDeliveries(); assert.Len(t, deliveries, 1, "expected the canceled delivery") {
+		assert.NotEmpty(t, deliveries[0].Error, "expected the cancel error")
+	}
+}
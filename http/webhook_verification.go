@@ -0,0 +1,310 @@
+package http
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+/**
+* Verifies the HMAC signatures of the webhooks received by the endpoints.
+* @author rnojiri
+**/
+
+// The signature header schemes.
+const (
+	// SignatureSchemeHeader - the header has the prefixed signature and an optional timestamp header
+	SignatureSchemeHeader string = ""
+	// SignatureSchemeStripe - the header has the timestamp and the signatures like "t=1700000000,v1=..."
+	SignatureSchemeStripe string = "stripe"
+)
+
+// The signature encodings.
+const (
+	SignatureHex    string = ""
+	SignatureBase64 string = "base64"
+)
+
+const (
+	defaultStripeTolerance        = 5 * time.Minute
+	stripeTimestampKey     string = "t"
+	stripeSignatureKey     string = "v1"
+	signaturePayloadKey    string = "payload"
+)
+
+// SignatureVerification - verifies the HMAC signature of the received requests
+type SignatureVerification struct {
+	// Header - the header containing the signature
+	Header string
+	// Secret - the HMAC secret
+	Secret string
+	// Algorithm - SignatureSHA1, SignatureSHA256 or SignatureSHA512 (SignatureSHA256 when empty)
+	Algorithm string
+	// Prefix - expected before the encoded signature, like "sha256="
+	Prefix string
+	// Encoding - SignatureHex or SignatureBase64 (SignatureHex when empty)
+	Encoding string
+	// Scheme - SignatureSchemeHeader or SignatureSchemeStripe (SignatureSchemeHeader when empty)
+	Scheme string
+	// TimestampHeader - the header containing the unix timestamp (SignatureSchemeHeader only)
+	TimestampHeader string
+	// Tolerance - the maximum difference between the timestamp and the server time (not checked when zero)
+	Tolerance time.Duration
+	// Payload - the template of the signed payload executed with the SignedPayload, like
+	// "{{.Timestamp}}.{{.Body}}" (only the body when empty)
+	Payload string
+	// Reject - answers the invalid signatures with 401 instead of the endpoint response
+	Reject bool
+}
+
+// SignedPayload - the data used by the signed payload template
+type SignedPayload struct {
+	// Timestamp - the received timestamp
+	Timestamp string
+	// Body - the request body
+	Body string
+}
+
+// SignatureCheck - the result of the signature verification of a received request
+type SignatureCheck struct {
+	// Valid - the signature matches
+	Valid bool
+	// Reason - why the signature is invalid
+	Reason string
+	// Timestamp - the received timestamp (zero when not used)
+	Timestamp time.Time
+}
+
+// String - returns the result like "valid" or "invalid: signature mismatch"
+func (sc *SignatureCheck) String() string {
+
+	if sc.Valid {
+		return "valid"
+	}
+
+	return "invalid: " + sc.Reason
+}
+
+// GitHubSignature - verifies the X-Hub-Signature-256 header sent by GitHub
+func GitHubSignature(secret string) *SignatureVerification {
+
+	return &SignatureVerification{
+		Header:    "X-Hub-Signature-256",
+		Secret:    secret,
+		Algorithm: SignatureSHA256,
+		Prefix:    "sha256=",
+	}
+}
+
+// StripeSignature - verifies the Stripe-Signature header sent by Stripe (5 minutes of tolerance)
+func StripeSignature(secret string) *SignatureVerification {
+
+	return &SignatureVerification{
+		Header:    "Stripe-Signature",
+		Secret:    secret,
+		Algorithm: SignatureSHA256,
+		Scheme:    SignatureSchemeStripe,
+		Tolerance: defaultStripeTolerance,
+		Payload:   "{{.Timestamp}}.{{.Body}}",
+	}
+}
+
+// BySignature - selects the verified requests by the signature validity
+func BySignature(valid bool) RequestFilter {
+
+	return func(request *Request) bool {
+		return request.Signature != nil && request.Signature.Valid == valid
+	}
+}
+
+// Sign - returns the signature header value of the body, used to build the requests sent to the
+// endpoints (the timestamp is ignored when the verification does not use it)
+func (sv *SignatureVerification) Sign(body []byte, timestamp time.Time) (string, error) {
+
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+
+	signature, err := sv.compute(body, unix)
+	if err != nil {
+		return "", err
+	}
+
+	if sv.Scheme == SignatureSchemeStripe {
+		return stripeTimestampKey + "=" + unix + "," + stripeSignatureKey + "=" + signature, nil
+	}
+
+	return sv.Prefix + signature, nil
+}
+
+// compute - returns the encoded HMAC of the signed payload
+func (sv *SignatureVerification) compute(body []byte, timestamp string) (string, error) {
+
+	hashFunc, err := signatureHash(sv.Algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	payload := string(body)
+
+	if sv.Payload != "" {
+
+		tmpl, err := template.New(signaturePayloadKey).Parse(sv.Payload)
+		if err != nil {
+			return "", fmt.Errorf("invalid signed payload template: %w", err)
+		}
+
+		buffer := strings.Builder{}
+		if err := tmpl.Execute(&buffer, &SignedPayload{Timestamp: timestamp, Body: payload}); err != nil {
+			return "", fmt.Errorf("error executing the signed payload template: %w", err)
+		}
+
+		payload = buffer.String()
+	}
+
+	mac := hmac.New(hashFunc, []byte(sv.Secret))
+	mac.Write([]byte(payload))
+
+	if sv.Encoding == SignatureBase64 {
+		return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+	}
+
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// verify - checks the signature and the timestamp of the request
+func (sv *SignatureVerification) verify(headers http.Header, body []byte, now time.Time) *SignatureCheck {
+
+	value := headers.Get(sv.Header)
+	if value == "" {
+		return &SignatureCheck{Reason: "missing the " + sv.Header + " header"}
+	}
+
+	var timestamp string
+	var signatures []string
+
+	if sv.Scheme == SignatureSchemeStripe {
+
+		for _, item := range strings.Split(value, ",") {
+
+			key, itemValue, ok := strings.Cut(strings.TrimSpace(item), "=")
+			if !ok {
+				return &SignatureCheck{Reason: "malformed " + sv.Header + " header"}
+			}
+
+			switch key {
+			case stripeTimestampKey:
+				timestamp = itemValue
+			case stripeSignatureKey:
+				signatures = append(signatures, itemValue)
+			}
+		}
+
+		if timestamp == "" || len(signatures) == 0 {
+			return &SignatureCheck{Reason: "malformed " + sv.Header + " header"}
+		}
+
+	} else {
+
+		if !strings.HasPrefix(value, sv.Prefix) {
+			return &SignatureCheck{Reason: "missing the signature prefix " + sv.Prefix}
+		}
+
+		signatures = []string{strings.TrimPrefix(value, sv.Prefix)}
+
+		if sv.TimestampHeader != "" {
+			timestamp = headers.Get(sv.TimestampHeader)
+			if timestamp == "" {
+				return &SignatureCheck{Reason: "missing the " + sv.TimestampHeader + " header"}
+			}
+		}
+	}
+
+	check := &SignatureCheck{}
+
+	if timestamp != "" {
+
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return &SignatureCheck{Reason: "invalid timestamp: " + timestamp}
+		}
+
+		check.Timestamp = time.Unix(unix, 0)
+
+		if sv.Tolerance > 0 {
+
+			difference := now.Sub(check.Timestamp)
+			if difference < 0 {
+				difference = -difference
+			}
+
+			if difference > sv.Tolerance {
+				check.Reason = "timestamp outside the tolerance"
+				return check
+			}
+		}
+	}
+
+	expected, err := sv.compute(body, timestamp)
+	if err != nil {
+		check.Reason = err.Error()
+		return check
+	}
+
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			check.Valid = true
+			return check
+		}
+	}
+
+	check.Reason = "signature mismatch"
+
+	return check
+}
+
+// signatureRejectedResponse - the response of the rejected signatures
+func signatureRejectedResponse(check *SignatureCheck) Response {
+
+	return Response{Status: http.StatusUnauthorized, Body: "signature " + check.String()}
+}
+
+// validateSignatureVerification - checks the verification, nil verifications are valid
+func validateSignatureVerification(verification *SignatureVerification) error {
+
+	if verification == nil {
+		return nil
+	}
+
+	if verification.Header == "" {
+		return fmt.Errorf("empty signature header")
+	}
+
+	if _, err := signatureHash(verification.Algorithm); err != nil {
+		return err
+	}
+
+	if verification.Encoding != SignatureHex && verification.Encoding != SignatureBase64 {
+		return fmt.Errorf("unknown signature encoding: %s", verification.Encoding)
+	}
+
+	if verification.Scheme != SignatureSchemeHeader && verification.Scheme != SignatureSchemeStripe {
+		return fmt.Errorf("unknown signature scheme: %s", verification.Scheme)
+	}
+
+	if verification.Tolerance < 0 {
+		return fmt.Errorf("invalid tolerance: %s", verification.Tolerance)
+	}
+
+	if verification.Payload != "" {
+		if _, err := template.New(signaturePayloadKey).Parse(verification.Payload); err != nil {
+			return fmt.Errorf("invalid signed payload template: %w", err)
+		}
+	}
+
+	return nil
+}
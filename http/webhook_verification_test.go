@@ -0,0 +1,224 @@
+package http_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	gotesthttp "github.com/rnojiri/gotest/http"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* The tests for the signature verification of the received webhooks.
+* @author rnojiri
+**/
+
+// newSignatureServer - creates a server with an endpoint verifying the signatures
+func newSignatureServer(t *testing.T, verification *gotesthttp.SignatureVerification) *gotesthttp.Server {
+
+	server, err := gotesthttp.NewServerWithOptions(t, gotesthttp.WithEndpoints("default", gotesthttp.Endpoint{
+		URI:       "/hooks",
+		Methods:   map[string]gotesthttp.Response{http.MethodPost: {Status: http.StatusOK}},
+		Signature: verification,
+	}))
+	if err != nil {
+		t.Fatalf("error creating the server: %v", err)
+	}
+
+	return server
+}
+
+// sign - signs the body using the verification
+func sign(t *testing.T, verification *gotesthttp.SignatureVerification, body string, timestamp time.Time) string {
+
+	signature, err := verification.Sign([]byte(body), timestamp)
+	if err != nil {
+		t.Fatalf("error signing the body: %v", err)
+	}
+
+	return signature
+}
+
+// TestSignatureVerification - tests the valid and invalid signatures of the built in and custom verifications
+func TestSignatureVerification(t *testing.T) {
+
+	const body string = `{"event":"paid"}`
+
+	now := time.Now()
+
+	github := gotesthttp.GitHubSignature("github-secret")
+	stripe := gotesthttp.StripeSignature("stripe-secret")
+
+	custom := &gotesthttp.SignatureVerification{
+		Header:          "X-Signature",
+		Secret:          "custom-secret",
+		Algorithm:       gotesthttp.SignatureSHA512,
+		Encoding:        gotesthttp.SignatureBase64,
+		TimestampHeader: "X-Timestamp",
+		Tolerance:       time.Minute,
+		Payload:         "v0:{{.Timestamp}}:{{.Body}}",
+	}
+
+	unix := strconv.FormatInt(now.Unix(), 10)
+
+	testCases := []struct {
+		name         string
+		verification *gotesthttp.SignatureVerification
+		headers      http.Header
+		reason       string
+	}{
+		{
+			name:         "github valid",
+			verification: github,
+			headers:      http.Header{"X-Hub-Signature-256": {sign(t, github, body, now)}},
+		},
+		{
+			name:         "github other secret",
+			verification: github,
+			headers:      http.Header{"X-Hub-Signature-256": {sign(t, gotesthttp.GitHubSignature("other"), body, now)}},
+			reason:       "signature mismatch",
+		},
+		{
+			name:         "github missing header",
+			verification: github,
+			headers:      http.Header{},
+			reason:       "missing the X-Hub-Signature-256 header",
+		},
+		{
+			name:         "github missing prefix",
+			verification: github,
+			headers:      http.Header{"X-Hub-Signature-256": {"abc"}},
+			reason:       "missing the signature prefix sha256=",
+		},
+		{
+			name:         "stripe valid",
+			verification: stripe,
+			headers:      http.Header{"Stripe-Signature": {sign(t, stripe, body, now)}},
+		},
+		{
+			name:         "stripe many signatures",
+			verification: stripe,
+			headers:      http.Header{"Stripe-Signature": {sign(t, stripe, body, now) + ",v1=abc,v0=def"}},
+		},
+		{
+			name:         "stripe expired",
+			verification: stripe,
+			headers:      http.Header{"Stripe-Signature": {sign(t, stripe, body, now.Add(-10*time.Minute))}},
+			reason:       "timestamp outside the tolerance",
+		},
+		{
+			name:         "stripe malformed",
+			verification: stripe,
+			headers:      http.Header{"Stripe-Signature": {"v1=abc"}},
+			reason:       "malformed Stripe-Signature header",
+		},
+		{
+			name:         "custom valid",
+			verification: custom,
+			headers:      http.Header{"X-Signature": {sign(t, custom, body, now)}, "X-Timestamp": {unix}},
+		},
+		{
+			name:         "custom other timestamp",
+			verification: custom,
+			headers:      http.Header{"X-Signature": {sign(t, custom, body, now)}, "X-Timestamp": {strconv.FormatInt(now.Unix()-1, 10)}},
+			reason:       "signature mismatch",
+		},
+		{
+			name:         "custom invalid timestamp",
+			verification: custom,
+			headers:      http.Header{"X-Signature": {sign(t, custom, body, now)}, "X-Timestamp": {"yesterday"}},
+			reason:       "invalid timestamp: yesterday",
+		},
+	}
+
+	for _, testCase := range testCases {
+
+		t.Run(testCase.name, func(t *testing.T) {
+
+			server := newSignatureServer(t, testCase.verification)
+
+			res := server.DoRequest(&gotesthttp.Request{URI: "/hooks", Method: http.MethodPost, Body: []byte(body), Headers: testCase.headers})
+			res.Body.Close()
+
+			assert.Equal(t, http.StatusOK, res.StatusCode, "expected the endpoint response")
+
+			requests := server.Requests()
+			if !assert.Len(t, requests, 1, "expected the request recorded") || !assert.NotNil(t, requests[0].Signature, "expected the signature check") {
+				return
+			}
+
+			assert.Equal(t, testCase.reason == "", requests[0].Signature.Valid, "expected the validity")
+			assert.Equal(t, testCase.reason, requests[0].Signature.Reason, "expected the reason")
+		})
+	}
+}
+
+// TestSignatureReject - tests the invalid signatures answered with 401
+func TestSignatureReject(t *testing.T) {
+
+	verification := gotesthttp.GitHubSignature("secret")
+	verification.Reject = true
+
+	server := newSignatureServer(t, verification)
+
+	res := server.DoRequest(&gotesthttp.Request{URI: "/hooks", Method: http.MethodPost, Body: []byte("{}"), Headers: http.Header{"X-Hub-Signature-256": {"sha256=abc"}}})
+	res.Body.Close()
+
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode, "expected the signature rejected")
+
+	res = server.DoRequest(&gotesthttp.Request{URI: "/hooks", Method: http.MethodPost, Body: []byte("{}"), Headers: http.Header{"X-Hub-Signature-256": {sign(t, verification, "{}", time.Now())}}})
+	res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected the signature accepted")
+
+	assert.Len(t, server.Requests(gotesthttp.BySignature(false)), 1, "expected the invalid request recorded")
+	assert.Len(t, server.Requests(gotesthttp.BySignature(true)), 1, "expected the valid request recorded")
+
+	_, err := gotesthttp.NewServerWithOptions(t, gotesthttp.WithEndpoints("default", gotesthttp.Endpoint{
+		URI:       "/hooks",
+		Methods:   map[string]gotesthttp.Response{http.MethodPost: {Status: http.StatusOK}},
+		Signature: &gotesthttp.SignatureVerification{Header: "X-Signature", Algorithm: "md5"},
+	}))
+	assert.Error(t, err, "expected the unknown algorithm")
+}
+
+// TestSignatureOutboundWebhook - tests the verification of the webhooks sent by another server
+func TestSignatureOutboundWebhook(t *testing.T) {
+
+	receiver := newSignatureServer(t, gotesthttp.GitHubSignature("shared"))
+
+	sender, err := gotesthttp.NewServerWithOptions(t, gotesthttp.WithEndpoints("default", gotesthttp.Endpoint{
+		URI: "/trigger",
+		Methods: map[string]gotesthttp.Response{
+			http.MethodPost: {
+				Status: http.StatusAccepted,
+				Webhooks: []gotesthttp.Webhook{
+					{
+						URL:  "http://" + receiver.Address() + "/hooks",
+						Body: `{"status":"done"}`,
+						Signature: &gotesthttp.WebhookSignature{
+							Header: "X-Hub-Signature-256",
+							Secret: "shared",
+							Prefix: "sha256=",
+						},
+					},
+				},
+			},
+		},
+	}))
+	if err != nil {
+		t.Fatalf("error creating the server: %v", err)
+	}
+
+	res := sender.DoRequest(&gotesthttp.Request{URI: "/trigger", Method: http.MethodPost})
+	res.Body.Close()
+
+	deliveries := sender.WaitForWebhookDeliveries(1, time.Second)
+	if assert.Len(t, deliveries, 1, "expected the delivery") {
+		assert.True(t, deliveries[0].Delivered, "expected the webhook delivered")
+	}
+
+	assert.Len(t, receiver.Requests(gotesthttp.BySignature(true)), 1, "expected the valid signature")
+}
@@ -0,0 +1,112 @@
+package lifecycle
+
+import (
+	"net"
+	"sync"
+)
+
+/**
+* Pauses the servers keeping their listeners bound.
+* @author rnojiri
+**/
+
+// Gate - blocks the callers while paused, the zero value is resumed
+type Gate struct {
+	// resumed - closed when resumed, nil when not paused
+	resumed chan struct{}
+	mutex   sync.Mutex
+}
+
+// Pause - blocks the next callers of Wait
+func (g *Gate) Pause() {
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.resumed == nil {
+		g.resumed = make(chan struct{})
+	}
+}
+
+// Resume - releases the blocked callers
+func (g *Gate) Resume() {
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.resumed != nil {
+		close(g.resumed)
+		g.resumed = nil
+	}
+}
+
+// Paused - returns if the gate is paused
+func (g *Gate) Paused() bool {
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	return g.resumed != nil
+}
+
+// Wait - blocks while paused, returns false if done is closed first
+func (g *Gate) Wait(done <-chan struct{}) bool {
+
+	g.mutex.Lock()
+	resumed := g.resumed
+	g.mutex.Unlock()
+
+	if resumed == nil {
+		return true
+	}
+
+	select {
+	case <-resumed:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// Listener - a listener not accepting connections while the gate is paused
+type Listener struct {
+	net.Listener
+	gate   *Gate
+	closed chan struct{}
+	once   sync.Once
+}
+
+// NewListener - wraps the listener
+func NewListener(listener net.Listener, gate *Gate) *Listener {
+
+	return &Listener{
+		Listener: listener,
+		gate:     gate,
+		closed:   make(chan struct{}),
+	}
+}
+
+// Accept - accepts the next connection holding it while the gate is paused, the gate is checked
+// after accepting to also hold the connections arriving to an Accept blocked before a pause
+func (l *Listener) Accept() (net.Conn, error) {
+
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	if !l.gate.Wait(l.closed) {
+		conn.Close()
+		return nil, net.ErrClosed
+	}
+
+	return conn, nil
+}
+
+// Close - closes the listener releasing a blocked Accept
+func (l *Listener) Close() error {
+
+	l.once.Do(func() { close(l.closed) })
+
+	return l.Listener.Close()
+}
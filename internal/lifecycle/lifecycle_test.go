@@ -0,0 +1,181 @@
+package lifecycle_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rnojiri/gotest/internal/lifecycle"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* The tests for the gate and the listener pausing the servers.
+* @author rnojiri
+**/
+
+// waitAsync - calls Wait in background returning its result
+func waitAsync(gate *lifecycle.Gate, done <-chan struct{}) <-chan bool {
+
+	result := make(chan bool, 1)
+
+	go func() {
+		result <- gate.Wait(done)
+	}()
+
+	return result
+}
+
+// TestGate - tests the callers blocked while paused
+func TestGate(t *testing.T) {
+
+	gate := &lifecycle.Gate{}
+
+	assert.False(t, gate.Paused(), "expected the zero value resumed")
+	assert.True(t, gate.Wait(nil), "expected no block when resumed")
+
+	gate.Pause()
+	gate.Pause()
+	assert.True(t, gate.Paused(), "expected the gate paused")
+
+	result := waitAsync(gate, nil)
+
+	select {
+	case <-result:
+		assert.Fail(t, "expected the caller blocked while paused")
+		return
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	gate.Resume()
+	gate.Resume()
+	assert.False(t, gate.Paused(), "expected the gate resumed")
+
+	select {
+	case ok := <-result:
+		assert.True(t, ok, "expected the caller released")
+	case <-time.After(time.Second):
+		assert.Fail(t, "expected the caller released by the resume")
+	}
+
+	gate.Pause()
+
+	done := make(chan struct{})
+	result = waitAsync(gate, done)
+
+	close(done)
+
+	select {
+	case ok := <-result:
+		assert.False(t, ok, "expected the caller released by done")
+	case <-time.After(time.Second):
+		assert.Fail(t, "expected the caller released by done")
+	}
+
+	assert.True(t, gate.Paused(), "expected the gate still paused")
+}
+
+// TestListener - tests the connections accepted only while resumed
+func TestListener(t *testing.T) {
+
+	inner, err := net.Listen("tcp", "localhost:0")
+	if !assert.NoError(t, err, "expected no error listening") {
+		return
+	}
+
+	gate := &lifecycle.Gate{}
+	gate.Pause()
+
+	listener := lifecycle.NewListener(inner, gate)
+
+	accepted := make(chan error, 1)
+
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			conn.Close()
+		}
+		accepted <- err
+	}()
+
+	conn, err := net.Dial("tcp", inner.Addr().String())
+	if !assert.NoError(t, err, "expected the connection queued by the system") {
+		return
+	}
+
+	defer conn.Close()
+
+	select {
+	case <-accepted:
+		assert.Fail(t, "expected no connection accepted while paused")
+		return
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	gate.Resume()
+
+	select {
+	case err := <-accepted:
+		assert.NoError(t, err, "expected the connection accepted after resuming")
+	case <-time.After(time.Second):
+		assert.Fail(t, "expected the connection accepted after resuming")
+		return
+	}
+
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			conn.Close()
+		}
+		accepted <- err
+	}()
+
+	<-time.After(20 * time.Millisecond)
+
+	gate.Pause()
+
+	held, err := net.Dial("tcp", inner.Addr().String())
+	if !assert.NoError(t, err, "expected the connection queued by the system") {
+		return
+	}
+
+	defer held.Close()
+
+	select {
+	case <-accepted:
+		assert.Fail(t, "expected the connection held when paused after the accept was blocked")
+		return
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	gate.Resume()
+
+	select {
+	case err := <-accepted:
+		assert.NoError(t, err, "expected the held connection accepted after resuming")
+	case <-time.After(time.Second):
+		assert.Fail(t, "expected the held connection accepted after resuming")
+		return
+	}
+
+	gate.Pause()
+
+	go func() {
+		_, err := listener.Accept()
+		accepted <- err
+	}()
+
+	<-time.After(20 * time.Millisecond)
+
+	assert.NoError(t, listener.Close(), "expected no error closing")
+
+	select {
+	case err := <-accepted:
+		assert.True(t, errors.Is(err, net.ErrClosed), "expected the blocked accept released by the close")
+	case <-time.After(time.Second):
+		assert.Fail(t, "expected the blocked accept released by the close")
+	}
+
+	assert.True(t, errors.Is(listener.Close(), net.ErrClosed), "expected the second close to fail")
+}
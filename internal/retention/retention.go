@@ -0,0 +1,157 @@
+package retention
+
+import "fmt"
+
+/**
+* Retention policies limiting the entries recorded by the mocks in long running tests.
+* @author rnojiri
+**/
+
+// Policy - limits the recorded entries, the zero value records everything
+type Policy struct {
+	// MaxEntries - keeps only the last entries, the oldest ones are evicted (zero keeps all)
+	MaxEntries int
+	// DropBodies - drops the bodies keeping the metadata of the entries
+	DropBodies bool
+	// SampleRate - records only one of each SampleRate entries (zero or one records all)
+	SampleRate int
+	// MaxErrors - keeps only the last errors, the oldest ones are evicted (zero keeps all)
+	MaxErrors int
+}
+
+// Validate - checks the policy, nil policies are valid
+func (p *Policy) Validate() error {
+
+	if p == nil {
+		return nil
+	}
+
+	if p.MaxEntries < 0 {
+		return fmt.Errorf("invalid maximum entries: %d", p.MaxEntries)
+	}
+
+	if p.SampleRate < 0 {
+		return fmt.Errorf("invalid sample rate: %d", p.SampleRate)
+	}
+
+	if p.MaxErrors < 0 {
+		return fmt.Errorf("invalid maximum errors: %d", p.MaxErrors)
+	}
+
+	return nil
+}
+
+// Stats - the aggregate statistics, counting all entries including the evicted and not sampled ones
+type Stats struct {
+	// Received - all received entries
+	Received uint64
+	// Recorded - the entries added to the journal
+	Recorded uint64
+	// NotSampled - the entries skipped by the sampling
+	NotSampled uint64
+	// Evicted - the entries removed to keep the maximum
+	Evicted uint64
+	// BodiesDropped - the entries recorded without the body
+	BodiesDropped uint64
+	// BodyBytes - the size of all received bodies
+	BodyBytes uint64
+	// Errors - all errors
+	Errors uint64
+	// ErrorsEvicted - the errors removed to keep the maximum
+	ErrorsEvicted uint64
+}
+
+// Counter - applies the policy and counts the entries (not thread safe, use the owner's lock)
+type Counter struct {
+	policy Policy
+	stats  Stats
+}
+
+// NewCounter - creates a counter for the policy (nil records everything)
+func NewCounter(policy *Policy) *Counter {
+
+	c := &Counter{}
+	if policy != nil {
+		c.policy = *policy
+	}
+
+	return c
+}
+
+// Admit - counts a received entry, returns if it must be recorded and if its body must be dropped
+func (c *Counter) Admit(bodySize int) (bool, bool) {
+
+	c.stats.Received++
+	c.stats.BodyBytes += uint64(bodySize)
+
+	if c.policy.SampleRate > 1 && (c.stats.Received-1)%uint64(c.policy.SampleRate) != 0 {
+		c.stats.NotSampled++
+		return false, false
+	}
+
+	c.stats.Recorded++
+
+	if c.policy.DropBodies && bodySize > 0 {
+		c.stats.BodiesDropped++
+		return true, true
+	}
+
+	return true, false
+}
+
+// Overflow - returns how many of the oldest entries must be evicted from a journal with the length
+func (c *Counter) Overflow(length int) int {
+
+	if c.policy.MaxEntries <= 0 || length <= c.policy.MaxEntries {
+		return 0
+	}
+
+	overflow := length - c.policy.MaxEntries
+	c.stats.Evicted += uint64(overflow)
+
+	return overflow
+}
+
+// AddError - counts an error, returns how many of the oldest errors must be evicted from a list with the length
+func (c *Counter) AddError(length int) int {
+
+	c.stats.Errors++
+
+	if c.policy.MaxErrors <= 0 || length <= c.policy.MaxErrors {
+		return 0
+	}
+
+	overflow := length - c.policy.MaxErrors
+	c.stats.ErrorsEvicted += uint64(overflow)
+
+	return overflow
+}
+
+// Evicted - counts entries evicted by the owner (like a full channel)
+func (c *Counter) Evicted(count int) {
+
+	c.stats.Evicted += uint64(count)
+}
+
+// Stats - returns a copy of the statistics
+func (c *Counter) Stats() Stats {
+
+	return c.stats
+}
+
+// Policy - returns the policy
+func (c *Counter) Policy() Policy {
+
+	return c.policy
+}
+
+// Evict - removes the oldest entries clearing them so the memory can be released
+func Evict[T any](entries []T, count int) []T {
+
+	var zero T
+	for i := 0; i < count; i++ {
+		entries[i] = zero
+	}
+
+	return entries[count:]
+}
@@ -0,0 +1,211 @@
+package retention_test
+
+import (
+	"testing"
+
+	"github.com/rnojiri/gotest/internal/retention"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+* The tests for the retention policies.
+* @author rnojiri
+**/
+
+// TestAdmit - tests the sampling and the dropped bodies of the received entries
+func TestAdmit(t *testing.T) {
+
+	testCases := []struct {
+		name     string
+		policy   *retention.Policy
+		sizes    []int
+		recorded []bool
+		dropped  []bool
+		stats    retention.Stats
+	}{
+		{
+			name:     "nil policy",
+			policy:   nil,
+			sizes:    []int{1, 2, 3},
+			recorded: []bool{true, true, true},
+			dropped:  []bool{false, false, false},
+			stats:    retention.Stats{Received: 3, Recorded: 3, BodyBytes: 6},
+		},
+		{
+			name:     "sample rate one",
+			policy:   &retention.Policy{SampleRate: 1},
+			sizes:    []int{0, 0, 0},
+			recorded: []bool{true, true, true},
+			dropped:  []bool{false, false, false},
+			stats:    retention.Stats{Received: 3, Recorded: 3},
+		},
+		{
+			name:     "sample rate three",
+			policy:   &retention.Policy{SampleRate: 3},
+			sizes:    []int{1, 1, 1, 1, 1},
+			recorded: []bool{true, false, false, true, false},
+			dropped:  []bool{false, false, false, false, false},
+			stats:    retention.Stats{Received: 5, Recorded: 2, NotSampled: 3, BodyBytes: 5},
+		},
+		{
+			name:     "drop bodies",
+			policy:   &retention.Policy{DropBodies: true},
+			sizes:    []int{4, 0},
+			recorded: []bool{true, true},
+			dropped:  []bool{true, false},
+			stats:    retention.Stats{Received: 2, Recorded: 2, BodiesDropped: 1, BodyBytes: 4},
+		},
+		{
+			name:     "drop sampled bodies",
+			policy:   &retention.Policy{DropBodies: true, SampleRate: 2},
+			sizes:    []int{2, 2, 2},
+			recorded: []bool{true, false, true},
+			dropped:  []bool{true, false, true},
+			stats:    retention.Stats{Received: 3, Recorded: 2, NotSampled: 1, BodiesDropped: 2, BodyBytes: 6},
+		},
+	}
+
+	for _, testCase := range testCases {
+
+		t.Run(testCase.name, func(t *testing.T) {
+
+			counter := retention.NewCounter(testCase.policy)
+
+			for i, size := range testCase.sizes {
+				recorded, dropped := counter.Admit(size)
+				assert.Equal(t, testCase.recorded[i], recorded, "expected the entry %d recorded", i)
+				assert.Equal(t, testCase.dropped[i], dropped, "expected the body %d dropped", i)
+			}
+
+			assert.Equal(t, testCase.stats, counter.Stats(), "expected the statistics")
+		})
+	}
+}
+
+// TestOverflow - tests the entries evicted to keep the maximum
+func TestOverflow(t *testing.T) {
+
+	testCases := []struct {
+		name       string
+		maxEntries int
+		length     int
+		overflow   int
+	}{
+		{name: "no maximum", maxEntries: 0, length: 100, overflow: 0},
+		{name: "below the maximum", maxEntries: 3, length: 2, overflow: 0},
+		{name: "at the maximum", maxEntries: 3, length: 3, overflow: 0},
+		{name: "one above the maximum", maxEntries: 3, length: 4, overflow: 1},
+		{name: "many above the maximum", maxEntries: 3, length: 10, overflow: 7},
+		{name: "maximum of one", maxEntries: 1, length: 2, overflow: 1},
+	}
+
+	for _, testCase := range testCases {
+
+		t.Run(testCase.name, func(t *testing.T) {
+
+			counter := retention.NewCounter(&retention.Policy{MaxEntries: testCase.maxEntries})
+
+			assert.Equal(t, testCase.overflow, counter.Overflow(testCase.length), "expected the overflow")
+			assert.Equal(t, uint64(testCase.overflow), counter.Stats().Evicted, "expected the evicted entries")
+		})
+	}
+
+	counter := retention.NewCounter(nil)
+	counter.Evicted(2)
+	counter.Evicted(3)
+
+	assert.Equal(t, uint64(5), counter.Stats().Evicted, "expected the entries evicted by the owner")
+}
+
+// TestAddError - tests the errors evicted to keep the maximum
+func TestAddError(t *testing.T) {
+
+	testCases := []struct {
+		name      string
+		maxErrors int
+		lengths   []int
+		overflows []int
+		stats     retention.Stats
+	}{
+		{
+			name:      "no maximum",
+			maxErrors: 0,
+			lengths:   []int{1, 2, 3},
+			overflows: []int{0, 0, 0},
+			stats:     retention.Stats{Errors: 3},
+		},
+		{
+			name:      "at the maximum",
+			maxErrors: 2,
+			lengths:   []int{1, 2},
+			overflows: []int{0, 0},
+			stats:     retention.Stats{Errors: 2},
+		},
+		{
+			name:      "above the maximum",
+			maxErrors: 2,
+			lengths:   []int{1, 2, 3, 3},
+			overflows: []int{0, 0, 1, 1},
+			stats:     retention.Stats{Errors: 4, ErrorsEvicted: 2},
+		},
+	}
+
+	for _, testCase := range testCases {
+
+		t.Run(testCase.name, func(t *testing.T) {
+
+			counter := retention.NewCounter(&retention.Policy{MaxErrors: testCase.maxErrors})
+
+			for i, length := range testCase.lengths {
+				assert.Equal(t, testCase.overflows[i], counter.AddError(length), "expected the overflow of the error %d", i)
+			}
+
+			assert.Equal(t, testCase.stats, counter.Stats(), "expected the statistics")
+		})
+	}
+}
+
+// TestEvict - tests removing the oldest entries
+func TestEvict(t *testing.T) {
+
+	testCases := []struct {
+		name     string
+		entries  []*string
+		count    int
+		expected int
+	}{
+		{name: "none", entries: []*string{new(string), new(string)}, count: 0, expected: 2},
+		{name: "one", entries: []*string{new(string), new(string)}, count: 1, expected: 1},
+		{name: "all", entries: []*string{new(string), new(string)}, count: 2, expected: 0},
+	}
+
+	for _, testCase := range testCases {
+
+		t.Run(testCase.name, func(t *testing.T) {
+
+			entries := testCase.entries
+			kept := retention.Evict(entries, testCase.count)
+
+			if assert.Len(t, kept, testCase.expected, "expected the kept entries") && testCase.expected > 0 {
+				assert.Same(t, entries[len(entries)-1], kept[len(kept)-1], "expected the newest entries kept")
+			}
+
+			for i := 0; i < testCase.count; i++ {
+				assert.Nil(t, entries[i], "expected the evicted entry %d cleared", i)
+			}
+		})
+	}
+}
+
+// TestValidate - tests the invalid policies
+func TestValidate(t *testing.T) {
+
+	var nilPolicy *retention.Policy
+
+	assert.NoError(t, nilPolicy.Validate(), "expected the nil policy valid")
+	assert.NoError(t, (&retention.Policy{MaxEntries: 1, SampleRate: 1, MaxErrors: 1}).Validate(), "expected a valid policy")
+
+	for _, policy := range []*retention.Policy{{MaxEntries: -1}, {SampleRate: -1}, {MaxErrors: -1}} {
+		assert.Error(t, policy.Validate(), "expected an invalid policy: %+v", *policy)
+	}
+}
@@ -1,13 +1,31 @@
 package tcpudp
 
-import "time"
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/rnojiri/gotest/internal/retention"
+	utils "github.com/rnojiri/gotest/utils"
+)
 
 //
 // Commons artifacts for the servers.
 // author: rnojiri
 //
 
-const listenRetries int = 10
+const (
+	listenRetries int = 10
+	maxPort       int = 65535
+)
+
+// Retention - limits the received messages of long running tests: the message channel becomes
+// a ring of MaxEntries messages, the messages can be dropped keeping the metadata or sampled
+type Retention = retention.Policy
+
+// RetentionStats - the counters of the received, recorded, not sampled and evicted messages
+type RetentionStats = retention.Stats
 
 // MessageData - the message data received
 type MessageData struct {
@@ -15,13 +33,106 @@ type MessageData struct {
 	Date    time.Time
 	Host    string
 	Port    int
+	// Size - the message size in bytes (kept when the message is dropped)
+	Size int
 }
 
 // ServerConfiguration - common configuration
 type ServerConfiguration struct {
-	Host               string
+	Host string
+	// Port - a fixed port to listen, when zero a random one is generated
+	Port int
+	// SocketPath - listens on this unix socket instead of the host and port (a path starting
+	// with @ is an abstract socket on linux), the socket file is removed on stop
+	SocketPath         string
 	MessageChannelSize int
 	ReadBufferSize     int
+	// Retention - limits the messages and the errors, the oldest messages are evicted
+	// instead of blocking when the channel is full (nil keeps all)
+	Retention *Retention
+}
+
+// listenPort - returns the port to listen in the current try and if another try is allowed
+func listenPort(configuration *ServerConfiguration) (int, bool) {
+
+	if configuration.Port > 0 {
+		return configuration.Port, false
+	}
+
+	return utils.GeneratePort(), true
+}
+
+// Validate - checks the configuration returning the first problem found
+func (configuration *ServerConfiguration) Validate() error {
+
+	if configuration.Port < 0 || configuration.Port > maxPort {
+		return fmt.Errorf("invalid port %d, expected a value between 0 and %d", configuration.Port, maxPort)
+	}
+
+	if configuration.SocketPath != "" && configuration.Port != 0 {
+		return fmt.Errorf("the port %d can not be used with the socket path %q", configuration.Port, configuration.SocketPath)
+	}
+
+	if configuration.MessageChannelSize < 0 {
+		return fmt.Errorf("invalid message channel size: %d", configuration.MessageChannelSize)
+	}
+
+	if configuration.ReadBufferSize <= 0 {
+		return fmt.Errorf("expected a positive read buffer size, found %d", configuration.ReadBufferSize)
+	}
+
+	if err := configuration.Retention.Validate(); err != nil {
+		return fmt.Errorf("invalid retention: %w", err)
+	}
+
+	return nil
+}
+
+// channelSize - returns the message channel size, the maximum entries of the retention has precedence
+func (configuration *ServerConfiguration) channelSize() int {
+
+	if configuration.Retention != nil && configuration.Retention.MaxEntries > 0 {
+		return configuration.Retention.MaxEntries
+	}
+
+	return configuration.MessageChannelSize
+}
+
+// address - returns the unix socket path or the host
+func (configuration *ServerConfiguration) address() string {
+
+	if configuration.SocketPath != "" {
+		return configuration.SocketPath
+	}
+
+	return configuration.Host
+}
+
+// packetListener - the udp or unix datagram connection
+type packetListener interface {
+	Read(b []byte) (int, error)
+	SetReadBuffer(bytes int) error
+	Close() error
+}
+
+// listenUnix - listens on the unix stream socket removing a stale socket file
+func listenUnix(path string) (net.Listener, error) {
+
+	if err := utils.RemoveSocketFile(path); err != nil {
+		return nil, err
+	}
+
+	return net.Listen("unix", path)
+}
+
+// listenUnixgram - listens on the unix datagram socket removing a stale socket file
+func listenUnixgram(path string) (packetListener, error) {
+
+	if err := utils.RemoveSocketFile(path); err != nil {
+		return nil, err
+	}
+
+	return net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
 }
 
 // server - core
@@ -30,4 +141,96 @@ type server struct {
 	messageChannel chan MessageData
 	port           int
 	started        bool
+	// retention - applies the retention policy and counts the messages, protected by the mutex
+	retention *retention.Counter
+	mutex     sync.Mutex
+}
+
+// newServer - creates the core
+func newServer(configuration *ServerConfiguration, port int) *server {
+
+	return &server{
+		messageChannel: make(chan MessageData, configuration.channelSize()),
+		port:           port,
+		retention:      retention.NewCounter(configuration.Retention),
+	}
+}
+
+// publish - sends the message to the channel applying the retention policy, without a
+// maximum of entries it blocks until the channel has space
+func (s *server) publish(message MessageData) {
+
+	s.mutex.Lock()
+
+	keep, dropBody := s.retention.Admit(message.Size)
+	if !keep {
+		s.mutex.Unlock()
+		return
+	}
+
+	if dropBody {
+		message.Message = ""
+	}
+
+	if s.retention.Policy().MaxEntries <= 0 {
+		s.mutex.Unlock()
+		s.messageChannel <- message
+		return
+	}
+
+	defer s.mutex.Unlock()
+
+	for {
+		select {
+		case s.messageChannel <- message:
+			return
+		default:
+		}
+
+		select {
+		case <-s.messageChannel:
+			s.retention.Evicted(1)
+		default:
+		}
+	}
+}
+
+// addError - stores an asynchronous error
+func (s *server) addError(err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.errors = append(s.errors, err)
+	s.errors = retention.Evict(s.errors, s.retention.AddError(len(s.errors)))
+}
+
+// GetErrors - get asynchronous errors
+func (s *server) GetErrors() []error {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]error{}, s.errors...)
+}
+
+// MessageChannel - reads from the message channel
+func (s *server) MessageChannel() <-chan MessageData {
+
+	return s.messageChannel
+}
+
+// Stats - returns the statistics of all received messages, including the evicted and not sampled ones
+func (s *server) Stats() RetentionStats {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.retention.Stats()
+}
+
+// Port - returns the listened port (zero for unix sockets)
+func (s *server) Port() int {
+
+	return s.port
 }
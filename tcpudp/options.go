@@ -0,0 +1,181 @@
+package tcpudp
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+//
+// Functional options to create the servers.
+// author: rnojiri
+//
+
+const (
+	defaultOptionsHost               string = "localhost"
+	defaultOptionsMessageChannelSize int    = 100
+	defaultOptionsReadBufferSize     int    = 1024
+	defaultOptionsTimeout                   = time.Second
+)
+
+// serverOptions - the configuration built by the options
+type serverOptions struct {
+	tcp           bool
+	configuration TCPConfiguration
+}
+
+// Option - changes the configuration of the servers created by NewTCPServerWithOptions and NewUDPServerWithOptions
+type Option func(options *serverOptions) error
+
+// WithHost - the host to listen
+func WithHost(host string) Option {
+
+	return func(options *serverOptions) error {
+		options.configuration.Host = host
+		return nil
+	}
+}
+
+// WithPort - the port to listen (0 chooses a random one)
+func WithPort(port int) Option {
+
+	return func(options *serverOptions) error {
+		if port < 0 || port > maxPort {
+			return fmt.Errorf("invalid port %d, expected a value between 0 and %d", port, maxPort)
+		}
+		options.configuration.Port = port
+		return nil
+	}
+}
+
+// WithSocketPath - listens on the unix socket instead of the host and port
+func WithSocketPath(socketPath string) Option {
+
+	return func(options *serverOptions) error {
+		if socketPath == "" {
+			return fmt.Errorf("empty socket path")
+		}
+		options.configuration.SocketPath = socketPath
+		return nil
+	}
+}
+
+// WithMessageChannelSize - the size of the received messages channel
+func WithMessageChannelSize(size int) Option {
+
+	return func(options *serverOptions) error {
+		if size < 0 {
+			return fmt.Errorf("invalid message channel size: %d", size)
+		}
+		options.configuration.MessageChannelSize = size
+		return nil
+	}
+}
+
+// WithReadBufferSize - the size of the read buffer
+func WithReadBufferSize(size int) Option {
+
+	return func(options *serverOptions) error {
+		if size <= 0 {
+			return fmt.Errorf("expected a positive read buffer size, found %d", size)
+		}
+		options.configuration.ReadBufferSize = size
+		return nil
+	}
+}
+
+// WithReadTimeout - the time to wait for the tcp client data (tcp only)
+func WithReadTimeout(timeout time.Duration) Option {
+
+	return func(options *serverOptions) error {
+		if !options.tcp {
+			return fmt.Errorf("the read timeout is only supported by the tcp server")
+		}
+		if timeout <= 0 {
+			return fmt.Errorf("expected a positive read timeout, found %s", timeout)
+		}
+		options.configuration.ReadTimeout = timeout
+		return nil
+	}
+}
+
+// WithResponse - the response written to the tcp clients and its write timeout (tcp only)
+func WithResponse(response string, writeTimeout time.Duration) Option {
+
+	return func(options *serverOptions) error {
+		if !options.tcp {
+			return fmt.Errorf("the response is only supported by the tcp server")
+		}
+		if writeTimeout <= 0 {
+			return fmt.Errorf("expected a positive write timeout, found %s", writeTimeout)
+		}
+		options.configuration.ResponseString = response
+		options.configuration.WriteTimeout = writeTimeout
+		return nil
+	}
+}
+
+// WithRetention - limits the messages and the errors of long running tests
+func WithRetention(policy *Retention) Option {
+
+	return func(options *serverOptions) error {
+		if err := policy.Validate(); err != nil {
+			return fmt.Errorf("invalid retention: %w", err)
+		}
+		options.configuration.Retention = policy
+		return nil
+	}
+}
+
+// applyOptions - builds the configuration with the defaults and the options
+func applyOptions(tcp bool, options []Option) (*TCPConfiguration, error) {
+
+	built := &serverOptions{
+		tcp: tcp,
+		configuration: TCPConfiguration{
+			ReadTimeout:  defaultOptionsTimeout,
+			WriteTimeout: defaultOptionsTimeout,
+			ServerConfiguration: ServerConfiguration{
+				Host:               defaultOptionsHost,
+				MessageChannelSize: defaultOptionsMessageChannelSize,
+				ReadBufferSize:     defaultOptionsReadBufferSize,
+			},
+		},
+	}
+
+	for _, option := range options {
+		if err := option(built); err != nil {
+			return nil, err
+		}
+	}
+
+	return &built.configuration, nil
+}
+
+// NewTCPServerWithOptions - creates and starts a tcp server on localhost at a random port by default,
+// it is stopped by the cleanup of the test, benchmark or fuzz test (when not nil)
+func NewTCPServerWithOptions(tb testing.TB, options ...Option) (*TCPServer, error) {
+
+	configuration, err := applyOptions(true, options)
+	if err != nil {
+		return nil, err
+	}
+
+	server, _, err := NewTCPServerE(tb, configuration, true)
+
+	return server, err
+}
+
+// NewUDPServerWithOptions - creates and starts an udp server on localhost at a random port by default,
+// it is stopped by the cleanup of the test, benchmark or fuzz test (when not nil)
+func NewUDPServerWithOptions(tb testing.TB, options ...Option) (*UDPServer, error) {
+
+	configuration, err := applyOptions(false, options)
+	if err != nil {
+		return nil, err
+	}
+
+	server, _, err := NewUDPServerE(tb, &configuration.ServerConfiguration, true)
+
+	return server, err
+}
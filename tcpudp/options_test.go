@@ -0,0 +1,130 @@
+package tcpudp_test
+
+import (
+	"testing"
+	"time"
+
+	tcpudp "github.com/rnojiri/gotest/tcpudp"
+	"github.com/stretchr/testify/assert"
+)
+
+//
+// Tests for the functional options.
+// author: rnojiri
+//
+
+// TestTCPServerWithOptions - tests creating a tcp server using options stopped by the test cleanup
+func TestTCPServerWithOptions(t *testing.T) {
+
+	var s *tcpudp.TCPServer
+
+	t.Run("create", func(t *testing.T) {
+
+		var err error
+
+		s, err = tcpudp.NewTCPServerWithOptions(t, tcpudp.WithResponse("pong", time.Second))
+		if !assert.NoError(t, err, "expected no error") {
+			return
+		}
+
+		conn, err := tcpudp.ConnectTCP(testHost, s.Port(), 3*time.Second)
+		if !assert.NoError(t, err, "expected no error connecting") {
+			return
+		}
+
+		defer conn.Close()
+
+		assert.NoError(t, tcpudp.WriteTCP(conn, "ping", false), "expected no error writing")
+
+		response, err := tcpudp.ReadTCP(conn, bufferSize)
+		assert.NoError(t, err, "expected no error reading")
+		assert.Equal(t, "pong", response, "expected the response")
+
+		message := <-s.MessageChannel()
+		assert.Equal(t, "ping", message.Message, "expected the message")
+	})
+
+	if s == nil {
+		return
+	}
+
+	_, err := tcpudp.ConnectTCP(testHost, s.Port(), time.Second)
+	assert.Error(t, err, "expected the server stopped by the cleanup")
+}
+
+// TestUDPServerWithOptions - tests creating an udp server using options
+func TestUDPServerWithOptions(t *testing.T) {
+
+	s, err := tcpudp.NewUDPServerWithOptions(t, tcpudp.WithReadBufferSize(64))
+	if !assert.NoError(t, err, "expected no error") {
+		return
+	}
+
+	conn, err := tcpudp.ConnectUDP(testHost, s.Port(), time.Second)
+	if !assert.NoError(t, err, "expected no error connecting") {
+		return
+	}
+
+	defer conn.Close()
+
+	assert.NoError(t, tcpudp.WriteUDP(conn, "datagram"), "expected no error writing")
+
+	message := <-s.MessageChannel()
+	assert.Equal(t, "datagram", message.Message, "expected the message")
+	assert.Empty(t, s.GetErrors(), "expected no errors")
+}
+
+// TestOptionErrors - tests the descriptive errors instead of panics
+func TestOptionErrors(t *testing.T) {
+
+	_, err := tcpudp.NewTCPServerWithOptions(t, tcpudp.WithPort(-1))
+	assert.ErrorContains(t, err, "invalid port -1", "expected the port error")
+
+	_, err = tcpudp.NewTCPServerWithOptions(t, tcpudp.WithReadBufferSize(0))
+	assert.ErrorContains(t, err, "expected a positive read buffer size", "expected the buffer error")
+
+	_, err = tcpudp.NewUDPServerWithOptions(t, tcpudp.WithReadTimeout(time.Second))
+	assert.ErrorContains(t, err, "only supported by the tcp server", "expected the tcp only error")
+
+	_, err = tcpudp.NewTCPServerWithOptions(t, tcpudp.WithSocketPath("/tmp/gotest.sock"), tcpudp.WithPort(1000))
+	assert.ErrorContains(t, err, "can not be used with the socket path", "expected the conflict error")
+
+	_, _, err = tcpudp.NewTCPServerE(t, &tcpudp.TCPConfiguration{
+		ResponseString:      "response",
+		ServerConfiguration: tcpudp.ServerConfiguration{Host: testHost, ReadBufferSize: bufferSize},
+	}, false)
+	assert.ErrorContains(t, err, "expected a write timeout", "expected the write timeout error")
+
+	_, _, err = tcpudp.NewUDPServerE(t, nil, false)
+	assert.Error(t, err, "expected an error for a null configuration")
+
+	assert.Panics(t, func() {
+		tcpudp.NewUDPServer(nil, false)
+	}, "expected the old constructor to panic")
+}
+
+// TestOldConstructorsNotValidated - tests the old constructors accepting the configurations without validation
+func TestOldConstructorsNotValidated(t *testing.T) {
+
+	var tcpServer *tcpudp.TCPServer
+	var udpServer *tcpudp.UDPServer
+
+	assert.NotPanics(t, func() {
+		tcpServer, _ = tcpudp.NewTCPServer(&tcpudp.TCPConfiguration{
+			ResponseString:      "response",
+			ServerConfiguration: tcpudp.ServerConfiguration{Host: testHost},
+		}, false)
+	}, "expected no validation of the tcp configuration")
+
+	assert.NotPanics(t, func() {
+		udpServer, _ = tcpudp.NewUDPServer(&tcpudp.ServerConfiguration{Host: testHost}, false)
+	}, "expected no validation of the udp configuration")
+
+	if tcpServer != nil {
+		tcpServer.Stop()
+	}
+
+	if udpServer != nil {
+		udpServer.Stop()
+	}
+}
@@ -2,13 +2,16 @@ package tcpudp
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"strings"
+	"testing"
 	"time"
 
 	"github.com/jinzhu/copier"
+	"github.com/rnojiri/gotest/internal/lifecycle"
 	utils "github.com/rnojiri/gotest/utils"
 )
 
@@ -21,7 +24,13 @@ import (
 type TCPServer struct {
 	listener      net.Listener
 	configuration *TCPConfiguration
-	server
+	*server
+	// gate - holds the new connections while paused
+	gate lifecycle.Gate
+	// loopDone - closed when the listening loop returns
+	loopDone chan struct{}
+	// active - the connection being handled, protected by the mutex
+	active net.Conn
 }
 
 // TCPConfiguration - the tcp server configuration
@@ -32,55 +41,122 @@ type TCPConfiguration struct {
 	ServerConfiguration
 }
 
-// NewTCPServer - creates a new telnet server on a random port
+// NewTCPServer - creates a new telnet server on a random port without validating the configuration,
+// panics on errors (see NewTCPServerE)
 func NewTCPServer(configuration *TCPConfiguration, start bool) (*TCPServer, int) {
 
+	if configuration == nil {
+		panic(fmt.Errorf("null configuration"))
+	}
+
+	server, port, err := newTCPServer(configuration, start)
+	if err != nil {
+		panic(err)
+	}
+
+	return server, port
+}
+
+// NewTCPServerE - creates a new telnet server on a random port validating the configuration,
+// it is stopped by the cleanup of the test, benchmark or fuzz test (when not nil)
+func NewTCPServerE(tb testing.TB, configuration *TCPConfiguration, start bool) (*TCPServer, int, error) {
+
+	if configuration == nil {
+		return nil, 0, fmt.Errorf("null configuration")
+	}
+
+	if err := configuration.Validate(); err != nil {
+		return nil, 0, err
+	}
+
+	server, port, err := newTCPServer(configuration, start)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if tb != nil {
+		tb.Cleanup(func() { server.Stop() })
+	}
+
+	return server, port, nil
+}
+
+// newTCPServer - listens on the configured address
+func newTCPServer(configuration *TCPConfiguration, start bool) (*TCPServer, int, error) {
+
 	var listener net.Listener
 	var port int
 	var err error
 
-	for i := 0; i < listenRetries; i++ {
+	for i := 0; configuration.SocketPath == "" && i < listenRetries; i++ {
+
+		var retry bool
+		var address *net.TCPAddr
 
-		port = utils.GeneratePort()
-		address, err := net.ResolveTCPAddr("tcp", fmt.Sprintf("%s:%d", configuration.Host, port))
+		port, retry = listenPort(&configuration.ServerConfiguration)
+		address, err = net.ResolveTCPAddr("tcp", fmt.Sprintf("%s:%d", configuration.Host, port))
 		if err != nil {
-			panic(err)
+			return nil, 0, err
 		}
 
 		listener, err = net.ListenTCP("tcp", address)
 		if err != nil {
-			if strings.Contains(err.Error(), "address already in use") {
+			if retry && strings.Contains(err.Error(), "address already in use") {
 				<-time.After(time.Second)
 				fmt.Println("port already in use, trying another...")
 			} else {
-				panic(err)
+				return nil, 0, err
 			}
 		} else {
 			break
 		}
 	}
 
+	if configuration.SocketPath != "" {
+		listener, err = listenUnix(configuration.SocketPath)
+	}
+
 	if err != nil {
-		panic(err)
+		return nil, 0, err
 	}
 
 	confCopy := TCPConfiguration{}
 	copier.Copy(&confCopy, configuration)
 
 	server := &TCPServer{
-		server: server{
-			messageChannel: make(chan MessageData, configuration.MessageChannelSize),
-			port:           port,
-		},
-		listener:      listener,
+		server:        newServer(&configuration.ServerConfiguration, port),
 		configuration: &confCopy,
 	}
 
+	server.listener = lifecycle.NewListener(listener, &server.gate)
+
 	if start {
 		server.Start()
 	}
 
-	return server, port
+	return server, port, nil
+}
+
+// Validate - checks the configuration returning the first problem found
+func (configuration *TCPConfiguration) Validate() error {
+
+	if err := configuration.ServerConfiguration.Validate(); err != nil {
+		return err
+	}
+
+	if configuration.ReadTimeout < 0 {
+		return fmt.Errorf("invalid read timeout: %s", configuration.ReadTimeout)
+	}
+
+	if configuration.WriteTimeout < 0 {
+		return fmt.Errorf("invalid write timeout: %s", configuration.WriteTimeout)
+	}
+
+	if configuration.ResponseString != "" && configuration.WriteTimeout == 0 {
+		return fmt.Errorf("expected a write timeout to send the response string")
+	}
+
+	return nil
 }
 
 // Start - starts the server to receive connections
@@ -91,27 +167,38 @@ func (ts *TCPServer) Start() {
 	}
 
 	ts.started = true
+	ts.loopDone = make(chan struct{})
 
-	go ts.startListeningLoop()
+	go ts.startListeningLoop(ts.listener, ts.loopDone)
 }
 
-func (ts *TCPServer) startListeningLoop() {
+func (ts *TCPServer) startListeningLoop(listener net.Listener, done chan struct{}) {
+
+	defer close(done)
 
 	for {
-		conn, err := ts.listener.Accept()
+		conn, err := listener.Accept()
 		if err != nil {
-			ts.errors = append(ts.errors, err)
+			if !errors.Is(err, net.ErrClosed) {
+				ts.addError(err)
+			}
 			return
 		}
 
+		ts.setActive(conn)
 		ts.handleConnection(conn)
+		ts.setActive(nil)
 	}
 }
 
-// Stop - stops the server
+// Stop - stops the server, the unix socket file is removed
 func (ts *TCPServer) Stop() error {
 
-	return ts.listener.Close()
+	if err := ts.listener.Close(); err != nil {
+		return err
+	}
+
+	return utils.RemoveSocketFile(ts.configuration.SocketPath)
 }
 
 // handleConnection - handles the current connection
@@ -122,7 +209,7 @@ func (ts *TCPServer) handleConnection(conn net.Conn) {
 	for {
 		err := conn.SetReadDeadline(time.Now().Add(ts.configuration.ReadTimeout))
 		if err != nil {
-			ts.errors = append(ts.errors, err)
+			ts.addError(err)
 			return
 		}
 
@@ -139,7 +226,12 @@ func (ts *TCPServer) handleConnection(conn net.Conn) {
 				break
 			}
 
-			ts.errors = append(ts.errors, err)
+			// closed by the shutdown
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+
+			ts.addError(err)
 			return
 		}
 
@@ -155,34 +247,23 @@ func (ts *TCPServer) handleConnection(conn net.Conn) {
 
 	err := conn.SetWriteDeadline(time.Now().Add(ts.configuration.WriteTimeout))
 	if err != nil {
-		ts.errors = append(ts.errors, err)
+		ts.addError(err)
 		return
 	}
 
 	if len(ts.configuration.ResponseString) > 0 {
 		_, err := conn.Write(([]byte)(ts.configuration.ResponseString))
 		if err != nil {
-			ts.errors = append(ts.errors, err)
+			ts.addError(err)
 			return
 		}
 	}
 
-	ts.messageChannel <- MessageData{
+	ts.publish(MessageData{
 		Message: buffer.String(),
 		Date:    time.Now(),
-		Host:    ts.configuration.Host,
+		Host:    ts.configuration.address(),
 		Port:    ts.port,
-	}
-}
-
-// MessageChannel - reads from the message channel
-func (ts *TCPServer) MessageChannel() <-chan MessageData {
-
-	return ts.messageChannel
-}
-
-// GetErrors - get asynchronous errors
-func (ts *TCPServer) GetErrors() []error {
-
-	return ts.errors
+		Size:    buffer.Len(),
+	})
 }
@@ -0,0 +1,129 @@
+package tcpudp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/rnojiri/gotest/internal/lifecycle"
+	utils "github.com/rnojiri/gotest/utils"
+)
+
+//
+// Simulates outages of the tcp server: pause, restart and graceful shutdown.
+// author: rnojiri
+//
+
+// restartTimeout - the time waiting the connection being handled when restarting
+const restartTimeout = 5 * time.Second
+
+// Pause - keeps the address bound but does not accept new connections until resumed
+func (ts *TCPServer) Pause() {
+
+	ts.gate.Pause()
+}
+
+// Resume - accepts the held connections
+func (ts *TCPServer) Resume() {
+
+	ts.gate.Resume()
+}
+
+// Paused - returns if the server is paused
+func (ts *TCPServer) Paused() bool {
+
+	return ts.gate.Paused()
+}
+
+// Restart - closes the listener and listens again on the same address keeping the messages
+// and the errors, also after Stop or Shutdown, the connection being handled is finished first
+// (closed after the restart timeout) and a paused server is resumed
+func (ts *TCPServer) Restart() error {
+
+	if err := ts.listener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+		return err
+	}
+
+	ts.gate.Resume()
+
+	if ts.started {
+		select {
+		case <-ts.loopDone:
+		case <-time.After(restartTimeout):
+			ts.closeActive()
+			return fmt.Errorf("timeout waiting the connection being handled")
+		}
+	}
+
+	var listener net.Listener
+	var err error
+
+	if ts.configuration.SocketPath != "" {
+		listener, err = listenUnix(ts.configuration.SocketPath)
+	} else {
+		listener, err = net.Listen("tcp", fmt.Sprintf("%s:%d", ts.configuration.Host, ts.port))
+	}
+
+	if err != nil {
+		return fmt.Errorf("error listening again: %w", err)
+	}
+
+	ts.listener = lifecycle.NewListener(listener, &ts.gate)
+
+	if ts.started {
+		ts.started = false
+		ts.Start()
+	}
+
+	return nil
+}
+
+// Shutdown - stops accepting connections and waits the connection being handled until the
+// context is done (then it is closed), the unix socket file is removed
+func (ts *TCPServer) Shutdown(ctx context.Context) error {
+
+	if err := ts.listener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+		return err
+	}
+
+	ts.gate.Resume()
+
+	var err error
+
+	if ts.started {
+		select {
+		case <-ts.loopDone:
+		case <-ctx.Done():
+			ts.closeActive()
+			err = ctx.Err()
+		}
+	}
+
+	if removeErr := utils.RemoveSocketFile(ts.configuration.SocketPath); removeErr != nil {
+		return removeErr
+	}
+
+	return err
+}
+
+// setActive - sets the connection being handled (nil when none)
+func (ts *TCPServer) setActive(conn net.Conn) {
+
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	ts.active = conn
+}
+
+// closeActive - closes the connection being handled
+func (ts *TCPServer) closeActive() {
+
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	if ts.active != nil {
+		ts.active.Close()
+	}
+}
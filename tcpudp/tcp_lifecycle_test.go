@@ -0,0 +1,244 @@
+package tcpudp_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	tcpudp "github.com/rnojiri/gotest/tcpudp"
+	"github.com/stretchr/testify/assert"
+)
+
+//
+// Tests for the pause, restart and graceful shutdown of the tcp server.
+// author: rnojiri
+//
+
+// sendTCP - connects, writes the message and closes the connection
+func sendTCP(t *testing.T, port int, message string) bool {
+
+	conn, err := tcpudp.ConnectTCP(testHost, port, time.Second)
+	if !assert.NoError(t, err, "expected no error connecting") {
+		return false
+	}
+
+	defer conn.Close()
+
+	return assert.NoError(t, tcpudp.WriteTCP(conn, message, false), "expected no error writing")
+}
+
+// TestTCPPauseResume - tests the connections held while paused
+func TestTCPPauseResume(t *testing.T) {
+
+	s, err := tcpudp.NewTCPServerWithOptions(t, tcpudp.WithReadTimeout(100*time.Millisecond))
+	if !assert.NoError(t, err, "expected no error") {
+		return
+	}
+
+	s.Pause()
+	assert.True(t, s.Paused(), "expected the server paused")
+
+	if !sendTCP(t, s.Port(), "held") {
+		return
+	}
+
+	select {
+	case <-s.MessageChannel():
+		assert.Fail(t, "expected no message while paused")
+		return
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	s.Resume()
+
+	select {
+	case message := <-s.MessageChannel():
+		assert.Equal(t, "held", message.Message, "expected the held message")
+	case <-time.After(2 * time.Second):
+		assert.Fail(t, "expected the message after resuming")
+	}
+}
+
+// TestTCPPauseBlockedAccept - tests the connections held when paused after the accept loop is blocked
+func TestTCPPauseBlockedAccept(t *testing.T) {
+
+	s, err := tcpudp.NewTCPServerWithOptions(t, tcpudp.WithReadTimeout(100*time.Millisecond))
+	if !assert.NoError(t, err, "expected no error") {
+		return
+	}
+
+	if !sendTCP(t, s.Port(), "before") {
+		return
+	}
+
+	select {
+	case message := <-s.MessageChannel():
+		assert.Equal(t, "before", message.Message, "expected the first message")
+	case <-time.After(2 * time.Second):
+		assert.Fail(t, "expected the first message")
+		return
+	}
+
+	<-time.After(100 * time.Millisecond)
+
+	s.Pause()
+
+	if !sendTCP(t, s.Port(), "held") {
+		return
+	}
+
+	select {
+	case <-s.MessageChannel():
+		assert.Fail(t, "expected no message while paused")
+		return
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	s.Resume()
+
+	select {
+	case message := <-s.MessageChannel():
+		assert.Equal(t, "held", message.Message, "expected the held message")
+	case <-time.After(2 * time.Second):
+		assert.Fail(t, "expected the message after resuming")
+	}
+}
+
+// TestTCPRestart - tests listening again on the same port keeping the messages
+func TestTCPRestart(t *testing.T) {
+
+	s, err := tcpudp.NewTCPServerWithOptions(t, tcpudp.WithReadTimeout(100*time.Millisecond))
+	if !assert.NoError(t, err, "expected no error") {
+		return
+	}
+
+	port := s.Port()
+
+	if !sendTCP(t, port, "before") {
+		return
+	}
+
+	assert.Eventually(t, func() bool {
+		return s.Stats().Received == 1
+	}, 2*time.Second, 10*time.Millisecond, "expected the first message")
+
+	if !assert.NoError(t, s.Restart(), "expected no error restarting") {
+		return
+	}
+
+	assert.Equal(t, port, s.Port(), "expected the same port")
+
+	if !sendTCP(t, port, "after") {
+		return
+	}
+
+	for _, expected := range []string{"before", "after"} {
+		select {
+		case message := <-s.MessageChannel():
+			assert.Equal(t, expected, message.Message, "expected the message")
+		case <-time.After(2 * time.Second):
+			assert.Fail(t, "expected the message: "+expected)
+		}
+	}
+
+	assert.Empty(t, s.GetErrors(), "expected no errors")
+}
+
+// TestTCPShutdown - tests waiting the connection being handled
+func TestTCPShutdown(t *testing.T) {
+
+	s, err := tcpudp.NewTCPServerWithOptions(t, tcpudp.WithReadTimeout(200*time.Millisecond))
+	if !assert.NoError(t, err, "expected no error") {
+		return
+	}
+
+	conn, err := tcpudp.ConnectTCP(testHost, s.Port(), time.Second)
+	if !assert.NoError(t, err, "expected no error connecting") {
+		return
+	}
+
+	defer conn.Close()
+
+	assert.NoError(t, tcpudp.WriteTCP(conn, "last", false), "expected no error writing")
+
+	<-time.After(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	assert.NoError(t, s.Shutdown(ctx), "expected no error shutting down")
+	assert.Len(t, s.MessageChannel(), 1, "expected the connection handled before the shutdown")
+
+	_, err = tcpudp.ConnectTCP(testHost, s.Port(), time.Second)
+	assert.Error(t, err, "expected the server closed")
+}
+
+// TestTCPRestartAfterStop - tests restarting the stopped and the shut down servers
+func TestTCPRestartAfterStop(t *testing.T) {
+
+	s, err := tcpudp.NewTCPServerWithOptions(t, tcpudp.WithReadTimeout(100*time.Millisecond))
+	if !assert.NoError(t, err, "expected no error") {
+		return
+	}
+
+	if !assert.NoError(t, s.Stop(), "expected no error stopping") || !assert.NoError(t, s.Restart(), "expected no error restarting the stopped server") {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if !assert.NoError(t, s.Shutdown(ctx), "expected no error shutting down") || !assert.NoError(t, s.Restart(), "expected no error restarting the shut down server") {
+		return
+	}
+
+	if !sendTCP(t, s.Port(), "restarted") {
+		return
+	}
+
+	select {
+	case message := <-s.MessageChannel():
+		assert.Equal(t, "restarted", message.Message, "expected the message")
+	case <-time.After(2 * time.Second):
+		assert.Fail(t, "expected the message after restarting")
+	}
+}
+
+// TestTCPShutdownTimeout - tests closing the connection being handled when the context is done
+func TestTCPShutdownTimeout(t *testing.T) {
+
+	socketPath := filepath.Join(t.TempDir(), "shutdown.sock")
+
+	s, err := tcpudp.NewTCPServerWithOptions(t, tcpudp.WithSocketPath(socketPath), tcpudp.WithReadTimeout(5*time.Second))
+	if !assert.NoError(t, err, "expected no error") {
+		return
+	}
+
+	conn, err := net.Dial("unix", socketPath)
+	if !assert.NoError(t, err, "expected no error connecting") {
+		return
+	}
+
+	defer conn.Close()
+
+	<-time.After(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, s.Shutdown(ctx), context.DeadlineExceeded, "expected the context error")
+
+	_, err = os.Stat(socketPath)
+	assert.True(t, os.IsNotExist(err), "expected the socket file removed")
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	_, err = conn.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF, "expected the connection closed by the server")
+
+	assert.Empty(t, s.GetErrors(), "expected no errors")
+}
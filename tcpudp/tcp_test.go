@@ -2,6 +2,8 @@ package tcpudp_test
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -256,3 +258,49 @@ func TestTCPServerResponse(t *testing.T) {
 	assert.Len(t, s.GetErrors(), 0, "expected no errors")
 
 }
+
+// TestTCPUnixSocket - tests the server listening on unix sockets, including the abstract namespace
+func TestTCPUnixSocket(t *testing.T) {
+
+	socketPaths := []string{
+		filepath.Join(t.TempDir(), "tcp.sock"),
+		fmt.Sprintf("@gotest-tcp-%d", os.Getpid()),
+	}
+
+	for _, socketPath := range socketPaths {
+
+		unixConf := defaultTCPConf
+		unixConf.SocketPath = socketPath
+		unixConf.ResponseString = "response"
+		unixConf.WriteTimeout = time.Second
+
+		s, port := tcpudp.NewTCPServer(&unixConf, true)
+		assert.Zero(t, port, "expected no port")
+
+		conn, err := tcpudp.ConnectUnix(socketPath, 3*time.Second)
+		if !assert.NoError(t, err, "expected no error connecting to: %s", socketPath) {
+			s.Stop()
+			return
+		}
+
+		err = tcpudp.WriteTCP(conn, "request", false)
+		assert.NoError(t, err, "expected no error writing")
+
+		response, err := tcpudp.ReadTCP(conn, bufferSize)
+		assert.NoError(t, err, "expected no error reading")
+		assert.Equal(t, unixConf.ResponseString, response, "expected the configured response")
+
+		conn.Close()
+
+		message := <-s.MessageChannel()
+		assert.Equal(t, "request", message.Message, "expected the message")
+		assert.Equal(t, socketPath, message.Host, "expected the socket path as host")
+
+		assert.NoError(t, s.Stop(), "expected no error stopping")
+
+		if !strings.HasPrefix(socketPath, "@") {
+			_, err = os.Stat(socketPath)
+			assert.True(t, os.IsNotExist(err), "expected the socket file removed")
+		}
+	}
+}
@@ -38,8 +38,25 @@ func ConnectTCP(host string, port int, deadline time.Duration) (*net.TCPConn, er
 	return connection, nil
 }
 
-// WriteTCP - writes to the connection
-func WriteTCP(connection *net.TCPConn, payload string, endAfter bool) error {
+// ConnectUnix - connects to the unix stream socket (a path starting with @ is an abstract socket)
+func ConnectUnix(socketPath string, deadline time.Duration) (*net.UnixConn, error) {
+
+	connection, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: socketPath, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+
+	err = connection.SetDeadline(time.Now().Add(deadline))
+	if err != nil {
+		connection.Close()
+		return nil, err
+	}
+
+	return connection, nil
+}
+
+// WriteTCP - writes to the connection (tcp or unix)
+func WriteTCP(connection net.Conn, payload string, endAfter bool) error {
 
 	_, err := connection.Write(([]byte)(payload))
 	if err != nil {
@@ -55,8 +72,8 @@ func WriteTCP(connection *net.TCPConn, payload string, endAfter bool) error {
 	return nil
 }
 
-// ReadTCP - read from the connection
-func ReadTCP(connection *net.TCPConn, bufferSize int) (string, error) {
+// ReadTCP - read from the connection (tcp or unix)
+func ReadTCP(connection net.Conn, bufferSize int) (string, error) {
 
 	readBuffer := make([]byte, bufferSize)
 
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 	"strings"
+	"testing"
 	"time"
 
 	"github.com/jinzhu/copier"
@@ -17,74 +18,139 @@ import (
 
 // UDPServer - the udp server
 type UDPServer struct {
-	listener      *net.UDPConn
+	listener      packetListener
 	configuration *ServerConfiguration
-	server
+	*server
 }
 
-// NewUDPServer - creates a new udp server on a random port
+// NewUDPServer - creates a new udp server on a random port without validating the configuration,
+// panics on errors (see NewUDPServerE)
 func NewUDPServer(configuration *ServerConfiguration, start bool) (*UDPServer, int) {
 
-	var listener *net.UDPConn
+	if configuration == nil {
+		panic(fmt.Errorf("null configuration"))
+	}
+
+	server, port, err := newUDPServer(configuration, start)
+	if err != nil {
+		panic(err)
+	}
+
+	return server, port
+}
+
+// NewUDPServerE - creates a new udp server on a random port validating the configuration,
+// it is stopped by the cleanup of the test, benchmark or fuzz test (when not nil)
+func NewUDPServerE(tb testing.TB, configuration *ServerConfiguration, start bool) (*UDPServer, int, error) {
+
+	if configuration == nil {
+		return nil, 0, fmt.Errorf("null configuration")
+	}
+
+	if err := configuration.Validate(); err != nil {
+		return nil, 0, err
+	}
+
+	server, port, err := newUDPServer(configuration, start)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if tb != nil {
+		tb.Cleanup(func() { server.Stop() })
+	}
+
+	return server, port, nil
+}
+
+// newUDPServer - listens on the configured address
+func newUDPServer(configuration *ServerConfiguration, start bool) (*UDPServer, int, error) {
+
+	var listener packetListener
 	var port int
 	var err error
 
-	for i := 0; i < listenRetries; i++ {
+	for i := 0; configuration.SocketPath == "" && i < listenRetries; i++ {
+
+		var retry bool
+		var address *net.UDPAddr
 
-		port = utils.GeneratePort()
-		address, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", configuration.Host, port))
+		port, retry = listenPort(configuration)
+		address, err = net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", configuration.Host, port))
 		if err != nil {
-			panic(err)
+			return nil, 0, err
 		}
 
 		listener, err = net.ListenUDP("udp", address)
 		if err != nil {
-			if strings.Contains(err.Error(), "address already in use") {
+			if retry && strings.Contains(err.Error(), "address already in use") {
 				<-time.After(time.Second)
 				fmt.Println("port already in use, trying another...")
 			} else {
-				panic(err)
+				return nil, 0, err
 			}
 		} else {
 			break
 		}
 	}
 
+	if configuration.SocketPath != "" {
+		listener, err = listenUnixgram(configuration.SocketPath)
+	}
+
 	if err != nil {
-		panic(err)
+		return nil, 0, err
 	}
 
 	confCopy := ServerConfiguration{}
 	copier.Copy(&confCopy, configuration)
 
 	server := &UDPServer{
-		server: server{
-			messageChannel: make(chan MessageData, configuration.MessageChannelSize),
-			port:           port,
-		},
+		server:        newServer(configuration, port),
 		listener:      listener,
 		configuration: &confCopy,
 	}
 
 	if start {
-		server.Start()
+		if err := server.start(); err != nil {
+			server.Stop()
+			return nil, 0, err
+		}
 	}
 
-	return server, port
+	return server, port, nil
 }
 
-// Start - starts the server to receive connections
+// Start - starts the server to receive connections, an error setting the read buffer is
+// stored (see GetErrors) and the system default buffer is used
 func (us *UDPServer) Start() {
 
+	if err := us.start(); err != nil {
+		us.addError(err)
+		us.listen()
+	}
+}
+
+// start - sets the read buffer and starts the server, it is not started when the
+// read buffer can not be set
+func (us *UDPServer) start() error {
+
 	if us.started {
-		return
+		return nil
 	}
 
-	err := us.listener.SetReadBuffer(us.configuration.ReadBufferSize)
-	if err != nil {
-		panic(err)
+	if err := us.listener.SetReadBuffer(us.configuration.ReadBufferSize); err != nil {
+		return fmt.Errorf("error setting the read buffer: %w", err)
 	}
 
+	us.listen()
+
+	return nil
+}
+
+// listen - starts the listening loop
+func (us *UDPServer) listen() {
+
 	us.started = true
 
 	go us.startListeningLoop()
@@ -102,38 +168,32 @@ func (us *UDPServer) startListeningLoop() {
 				return
 			}
 
-			panic(err)
+			us.addError(err)
+			return
 		}
 
 		us.handlePacket(buffer[0:rlen])
 	}
 }
 
-// Stop - stops the server
+// Stop - stops the server, the unix socket file is removed
 func (us *UDPServer) Stop() error {
 
-	return us.listener.Close()
+	if err := us.listener.Close(); err != nil {
+		return err
+	}
+
+	return utils.RemoveSocketFile(us.configuration.SocketPath)
 }
 
 // handlePacket - handles the current connection
 func (us *UDPServer) handlePacket(buffer []byte) {
 
-	us.messageChannel <- MessageData{
+	us.publish(MessageData{
 		Message: string(buffer),
 		Date:    time.Now(),
-		Host:    us.configuration.Host,
+		Host:    us.configuration.address(),
 		Port:    us.port,
-	}
-}
-
-// MessageChannel - reads from the message channel
-func (us *UDPServer) MessageChannel() <-chan MessageData {
-
-	return us.messageChannel
-}
-
-// GetErrors - get asynchronous errors
-func (us *UDPServer) GetErrors() []error {
-
-	return us.errors
+		Size:    len(buffer),
+	})
 }
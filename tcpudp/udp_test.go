@@ -2,6 +2,9 @@ package tcpudp_test
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -134,3 +137,84 @@ func TestUDPMultipleMessages(t *testing.T) {
 
 	assert.Len(t, s.GetErrors(), 0, "expected no errors")
 }
+
+// TestUDPUnixSocket - tests the server listening on unix datagram sockets, including the abstract namespace
+func TestUDPUnixSocket(t *testing.T) {
+
+	socketPaths := []string{
+		filepath.Join(t.TempDir(), "udp.sock"),
+		fmt.Sprintf("@gotest-udp-%d", os.Getpid()),
+	}
+
+	for _, socketPath := range socketPaths {
+
+		unixConf := defaultUDPConf
+		unixConf.SocketPath = socketPath
+
+		s, port := tcpudp.NewUDPServer(&unixConf, true)
+		assert.Zero(t, port, "expected no port")
+
+		conn, err := tcpudp.ConnectUnixgram(socketPath, time.Second)
+		if !assert.NoError(t, err, "expected no error connecting to: %s", socketPath) {
+			s.Stop()
+			return
+		}
+
+		err = tcpudp.WriteUDP(conn, "datagram")
+		assert.NoError(t, err, "expected no error writing")
+
+		conn.Close()
+
+		message := <-s.MessageChannel()
+		assert.Equal(t, "datagram", message.Message, "expected the message")
+		assert.Equal(t, socketPath, message.Host, "expected the socket path as host")
+
+		assert.NoError(t, s.Stop(), "expected no error stopping")
+
+		if !strings.HasPrefix(socketPath, "@") {
+			_, err = os.Stat(socketPath)
+			assert.True(t, os.IsNotExist(err), "expected the socket file removed")
+		}
+	}
+}
+
+// TestUDPRetention - tests the message channel ring evicting the oldest messages instead of blocking
+func TestUDPRetention(t *testing.T) {
+
+	retentionConf := defaultUDPConf
+	retentionConf.Retention = &tcpudp.Retention{MaxEntries: 2, DropBodies: true}
+
+	s, port := tcpudp.NewUDPServer(&retentionConf, true)
+	defer s.Stop()
+
+	conn, err := tcpudp.ConnectUDP(testHost, port, time.Second)
+	if !assert.NoError(t, err, "expected no error connecting") {
+		return
+	}
+
+	defer conn.Close()
+
+	numMessages := 5
+
+	for i := 0; i < numMessages; i++ {
+		err = tcpudp.WriteUDP(conn, fmt.Sprintf("message%d", i))
+		if !assert.NoError(t, err, "expected no error writing") {
+			return
+		}
+	}
+
+	assert.Eventually(t, func() bool {
+		return s.Stats().Received == uint64(numMessages)
+	}, time.Second, 10*time.Millisecond, "expected all messages received")
+
+	stats := s.Stats()
+	assert.Equal(t, uint64(3), stats.Evicted, "expected the oldest messages evicted")
+	assert.Equal(t, uint64(numMessages), stats.BodiesDropped, "expected the bodies dropped")
+	assert.Equal(t, uint64(numMessages*len("message0")), stats.BodyBytes, "expected all bytes counted")
+	assert.Len(t, s.MessageChannel(), 2, "expected only the last messages")
+
+	message := <-s.MessageChannel()
+	assert.Empty(t, message.Message, "expected the body dropped")
+	assert.Equal(t, len("message0"), message.Size, "expected the size kept")
+	assert.Equal(t, port, message.Port, "expected the metadata kept")
+}
@@ -35,8 +35,25 @@ func ConnectUDP(host string, port int, deadline time.Duration) (*net.UDPConn, er
 	return connection, nil
 }
 
-// WriteUDP - writes to the connection
-func WriteUDP(connection *net.UDPConn, payload string) error {
+// ConnectUnixgram - connects to the unix datagram socket (a path starting with @ is an abstract socket)
+func ConnectUnixgram(socketPath string, deadline time.Duration) (*net.UnixConn, error) {
+
+	connection, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+
+	err = connection.SetDeadline(time.Now().Add(deadline))
+	if err != nil {
+		connection.Close()
+		return nil, err
+	}
+
+	return connection, nil
+}
+
+// WriteUDP - writes to the connection (udp or unix datagram)
+func WriteUDP(connection net.Conn, payload string) error {
 
 	_, err := fmt.Fprint(connection, payload)
 	if err != nil {
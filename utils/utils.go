@@ -3,7 +3,9 @@ package gotest
 import (
 	"fmt"
 	"math/rand"
+	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -49,3 +51,31 @@ func MustParseDuration(value string) time.Duration {
 
 	return d
 }
+
+// IsAbstractSocket - checks if the unix socket path is in the linux abstract namespace (starts with @)
+func IsAbstractSocket(path string) bool {
+
+	return strings.HasPrefix(path, "@")
+}
+
+// RemoveSocketFile - removes the unix socket file, abstract sockets and missing files are ignored
+func RemoveSocketFile(path string) error {
+
+	if path == "" || IsAbstractSocket(path) {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("not a socket file: %s", path)
+	}
+
+	return os.Remove(path)
+}